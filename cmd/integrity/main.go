@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/tree"
+)
+
+// integrity reports cycles in the category and collection trees, which the
+// API rejects but older data may still contain. It exits with status 1 when
+// it finds any.
+//
+// The database is chosen like the server does: STORAGE_BACKEND selects sqlite
+// (default, SQLITE_PATH) or postgres (DATABASE_URL).
+func main() {
+	client, err := db.ConnectFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	var categoryParents, collectionParents func() (tree.Parents, error)
+	if client.Dialect() == db.DialectPostgres {
+		categoryParents = categories.NewPostgresRepository(client).GetCategoryParents
+		collectionParents = collections.NewPostgresRepository(client).GetCollectionParents
+	} else {
+		categoryParents = categories.NewSQLiteRepository(client).GetCategoryParents
+		collectionParents = collections.NewSQLiteRepository(client).GetCollectionParents
+	}
+
+	found := 0
+	for _, check := range []struct {
+		name    string
+		parents func() (tree.Parents, error)
+	}{
+		{"categories", categoryParents},
+		{"collections", collectionParents},
+	} {
+		parents, err := check.parents()
+		if err != nil {
+			log.Fatalf("Failed to load %s: %v", check.name, err)
+		}
+		for _, cycle := range parents.Cycles() {
+			fmt.Printf("%s: cycle through IDs %v\n", check.name, cycle)
+			found++
+		}
+	}
+
+	if found > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("no cycles found")
+}
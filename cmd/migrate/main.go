@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"categories-test/internal/platform/db"
+)
+
+const usage = `usage: migrate [--dry-run] <command>
+
+commands:
+  status    list migrations and whether they are applied
+  up        apply all pending migrations
+  down [N]  revert the N most recently applied migrations (default 1)
+  redo      revert and re-apply the most recent migration
+
+The database is chosen like the server does: STORAGE_BACKEND selects sqlite
+(default, SQLITE_PATH) or postgres (DATABASE_URL). PostgreSQL databases need
+the pg_trgm extension, created beforehand by a superuser or the database owner.
+`
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "print the SQL that would run without executing it")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client, err := db.ConnectFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	migrator, err := db.NewMigrator(client)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	migrator.DryRun = *dryRun
+	migrator.Out = os.Stdout
+
+	if err := run(migrator, flag.Arg(0), flag.Args()[1:]); err != nil {
+		log.Fatalf("%s failed: %v", flag.Arg(0), err)
+	}
+}
+
+func run(migrator *db.Migrator, command string, args []string) error {
+	switch command {
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", status.Version, status.State, status.AppliedAt)
+		}
+		return w.Flush()
+	case "up":
+		if err := migrator.Verify(); err != nil {
+			return err
+		}
+		versions, err := migrator.Up()
+		report("applied", versions, migrator.DryRun)
+		return err
+	case "down":
+		n := 1
+		if len(args) > 0 {
+			parsed, err := strconv.Atoi(args[0])
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid count %q", args[0])
+			}
+			n = parsed
+		}
+		versions, err := migrator.Down(n)
+		report("reverted", versions, migrator.DryRun)
+		return err
+	case "redo":
+		version, err := migrator.Redo()
+		if err != nil {
+			return err
+		}
+		report("redone", []string{version}, migrator.DryRun)
+		return nil
+	default:
+		flag.Usage()
+		os.Exit(2)
+		return nil
+	}
+}
+
+func report(verb string, versions []string, dryRun bool) {
+	prefix := ""
+	if dryRun {
+		prefix = "would have "
+	}
+	if len(versions) == 0 {
+		fmt.Printf("nothing %s%s\n", prefix, verb)
+		return
+	}
+	for _, version := range versions {
+		fmt.Printf("%s%s %s\n", prefix, verb, version)
+	}
+}
@@ -3,14 +3,19 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"categories-test/internal/server"
 )
 
 func main() {
-	addr := getAddr()
-	dbPath := getDBPath()
-	s, err := server.New(addr, dbPath)
+	s, err := server.New(server.Config{
+		Addr:         getAddr(),
+		Backend:      getBackend(),
+		SQLitePath:   getDBPath(),
+		PostgresURL:  os.Getenv("DATABASE_URL"),
+		MaxTreeDepth: getMaxTreeDepth(),
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize server: %v", err)
 		os.Exit(1)
@@ -34,3 +39,18 @@ func getDBPath() string {
 	}
 	return "categories.db"
 }
+
+func getBackend() string {
+	if backend := os.Getenv("STORAGE_BACKEND"); backend != "" {
+		return backend
+	}
+	return server.BackendSQLite
+}
+
+func getMaxTreeDepth() int {
+	depth, err := strconv.Atoi(os.Getenv("MAX_TREE_DEPTH"))
+	if err != nil {
+		return 0
+	}
+	return depth
+}
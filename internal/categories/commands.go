@@ -1,21 +1,78 @@
 package categories
 
+import (
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/platform/validate"
+)
+
 type Commands struct {
-	repo CommandRepository
+	repo      CommandRepository
+	placement tree.Placement
+}
+
+// NewPlacement returns the rules that keep the category tree free of cycles and
+// no deeper than configured.
+func NewPlacement(opts ...tree.Option) tree.Placement {
+	return tree.NewPlacement(ErrCycle, ErrTooDeep, opts...)
 }
 
-func NewCommands(repo CommandRepository) *Commands {
-	return &Commands{repo: repo}
+func NewCommands(repo CommandRepository, opts ...tree.Option) *Commands {
+	return &Commands{repo: repo, placement: NewPlacement(opts...)}
 }
 
 func (c *Commands) Create(category *Category) (*Category, error) {
-	return c.repo.CreateCategory(category)
+	if err := c.validate(category); err != nil {
+		return nil, err
+	}
+	return c.repo.CreateCategory(category, c.placement)
 }
 
 func (c *Commands) Update(category *Category) (*Category, error) {
-	return c.repo.UpdateCategory(category)
+	if err := c.validate(category); err != nil {
+		return nil, err
+	}
+	return c.repo.UpdateCategory(category, c.placement)
+}
+
+// Move places a category among the children of a new parent, or among the
+// roots, applying the same placement rules as Update.
+func (c *Commands) Move(move Move) (*Category, error) {
+	var errs validate.Errors
+	if err := errs.Move(move, c.repo.MissingCategoryIDs); err != nil {
+		return nil, err
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return c.repo.MoveCategory(move, c.placement)
+}
+
+func (c *Commands) Delete(id int, opts DeleteOptions) (*DeleteResult, error) {
+	if err := c.validateDelete(opts); err != nil {
+		return nil, err
+	}
+	return c.repo.DeleteCategory(id, opts)
+}
+
+// validateDelete checks that a category to reassign products to is given
+// with, and only with, the reassign-products strategy. The repository checks
+// inside its transaction that the category exists and survives the delete.
+func (c *Commands) validateDelete(opts DeleteOptions) error {
+	var errs validate.Errors
+	switch {
+	case opts.Strategy == DeleteReassignProducts && opts.ReassignTo == nil:
+		errs.Add("to", "required", "must name the category that receives the products")
+	case opts.Strategy != DeleteReassignProducts && opts.ReassignTo != nil:
+		errs.Add("to", "unexpected", "is only used by the reassign-products strategy")
+	}
+	return errs.Err()
 }
 
-func (c *Commands) Delete(id int) error {
-	return c.repo.DeleteCategory(id)
+func (c *Commands) validate(category *Category) error {
+	var errs validate.Errors
+	errs.Name("name", category.Name)
+	if err := errs.Parent("parentId", category.ID, category.ParentID, c.repo.MissingCategoryIDs); err != nil {
+		return err
+	}
+	return errs.Err()
 }
@@ -0,0 +1,128 @@
+package categories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/platform/validate"
+)
+
+func TestCommandsValidate(t *testing.T) {
+	commands := NewCommands(NewMemoryRepository(memory.NewStore()))
+	root, err := commands.Create(&Category{Name: "Clothing"})
+	if err != nil {
+		t.Fatalf("Create root: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		category Category
+		update   bool
+		want     []string
+	}{
+		{"valid", Category{Name: "Shirts", ParentID: &root.ID}, false, nil},
+		{"blank name", Category{Name: ""}, false, []string{"name:required"}},
+		{"unknown parent", Category{Name: "Shirts", ParentID: intPtr(404)}, false, []string{"parentId:not_found"}},
+		{"own parent", Category{ID: root.ID, Name: "Clothing", ParentID: &root.ID}, true, []string{"parentId:self_reference"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			category := tt.category
+			if tt.update {
+				_, err = commands.Update(&category)
+			} else {
+				_, err = commands.Create(&category)
+			}
+			if got := fieldCodes(err); !slices.Equal(got, tt.want) {
+				t.Errorf("error = %v, want fields %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandsPlacement(t *testing.T) {
+	commands := NewCommands(NewMemoryRepository(memory.NewStore()), tree.WithMaxDepth(3))
+	create := func(name string, parentID *int) *Category {
+		t.Helper()
+		category, err := commands.Create(&Category{Name: name, ParentID: parentID})
+		if err != nil {
+			t.Fatalf("Create %s: %v", name, err)
+		}
+		return category
+	}
+	root := create("Clothing", nil)
+	child := create("Shirts", &root.ID)
+	leaf := create("Polos", &child.ID)
+	sale := create("Sale", nil)
+	clearance := create("Clearance", &sale.ID)
+
+	tests := []struct {
+		name     string
+		category Category
+		update   bool
+		want     error
+	}{
+		{"under own descendant", Category{ID: root.ID, Name: "Clothing", ParentID: &leaf.ID}, true, ErrCycle},
+		{"below max depth", Category{Name: "Extra", ParentID: &leaf.ID}, false, ErrTooDeep},
+		{"subtree below max depth", Category{ID: child.ID, Name: "Shirts", ParentID: &clearance.ID}, true, ErrTooDeep},
+		{"subtree within max depth", Category{ID: child.ID, Name: "Shirts", ParentID: &sale.ID}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			category := tt.category
+			var err error
+			if tt.update {
+				_, err = commands.Update(&category)
+			} else {
+				_, err = commands.Create(&category)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandsValidateDelete(t *testing.T) {
+	commands := NewCommands(NewMemoryRepository(memory.NewStore()))
+	root, _ := commands.Create(&Category{Name: "Clothing"})
+	child, _ := commands.Create(&Category{Name: "Shirts", ParentID: &root.ID})
+
+	tests := []struct {
+		name string
+		opts DeleteOptions
+		want []string
+	}{
+		{"reassign without target", DeleteOptions{Strategy: DeleteReassignProducts}, []string{"to:required"}},
+		{"target with other strategy", DeleteOptions{Strategy: DeleteDetachProducts, ReassignTo: &child.ID}, []string{"to:unexpected"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := commands.Delete(root.ID, tt.opts)
+			if got := fieldCodes(err); !slices.Equal(got, tt.want) {
+				t.Errorf("error = %v, want fields %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// fieldCodes lists the "field:code" pairs of a validation error in order, or
+// nil when err is not one.
+func fieldCodes(err error) []string {
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	codes := make([]string, 0, len(invalid.Fields))
+	for _, field := range invalid.Fields {
+		codes = append(codes, field.Field+":"+field.Code)
+	}
+	return codes
+}
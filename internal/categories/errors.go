@@ -3,7 +3,14 @@ package categories
 import "errors"
 
 var (
-	ErrNotFound      = errors.New("category not found")
-	ErrCategoryInUse = errors.New("category in use by products")
-	ErrChildInUse    = errors.New("child category in use by products")
+	ErrNotFound        = errors.New("category not found")
+	ErrCategoryInUse   = errors.New("category in use by products")
+	ErrChildInUse      = errors.New("child category in use by products")
+	ErrInvalidParent   = errors.New("parent category not found")
+	ErrCycle           = errors.New("category would become its own ancestor")
+	ErrTooDeep         = errors.New("category tree too deep")
+	ErrInvalidSibling  = errors.New("sibling category not found under parent")
+	ErrInvalidTarget   = errors.New("category to reassign products to not found")
+	ErrTargetDeleted   = errors.New("category to reassign products to is deleted too")
+	ErrVersionMismatch = errors.New("category was modified since it was read")
 )
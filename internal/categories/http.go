@@ -1,8 +1,9 @@
 package categories
 
 import (
-	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"categories-test/internal/platform/httpx"
 )
@@ -16,14 +17,104 @@ func NewHTTPHandler(commands *Commands, queries *Queries) *HTTPHandler {
 	return &HTTPHandler{commands: commands, queries: queries}
 }
 
+// errorMappings maps category errors to problem responses.
+var errorMappings = []httpx.ErrorMapping{
+	{Err: ErrNotFound, Status: http.StatusNotFound, Code: "category_not_found"},
+	{Err: ErrInvalidParent, Status: http.StatusUnprocessableEntity, Code: "unknown_parent", Field: "parentId"},
+	{Err: ErrCycle, Status: http.StatusUnprocessableEntity, Code: "parent_cycle", Field: "parentId"},
+	{Err: ErrTooDeep, Status: http.StatusUnprocessableEntity, Code: "too_deep", Field: "parentId"},
+	{Err: ErrInvalidSibling, Status: http.StatusUnprocessableEntity, Code: "unknown_sibling"},
+	{Err: ErrInvalidTarget, Status: http.StatusUnprocessableEntity, Code: "unknown_target", Field: "to"},
+	{Err: ErrTargetDeleted, Status: http.StatusUnprocessableEntity, Code: "deleted_target", Field: "to"},
+	{Err: ErrCategoryInUse, Status: http.StatusConflict, Code: "category_in_use"},
+	{Err: ErrChildInUse, Status: http.StatusConflict, Code: "child_category_in_use"},
+	{Err: ErrVersionMismatch, Status: http.StatusPreconditionFailed, Code: "version_mismatch"},
+}
+
+// categoryDTO is read back on create and update, where position and version
+// are ignored; categories are reordered with a move, and updates are checked
+// against If-Match.
 type categoryDTO struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
 	ParentID *int   `json:"parentId"`
+	Position int    `json:"position"`
+	Version  int    `json:"version"`
 }
 
 func toCategoryDTO(c *Category) categoryDTO {
-	return categoryDTO{ID: c.ID, Name: c.Name, ParentID: c.ParentID}
+	return categoryDTO{ID: c.ID, Name: c.Name, ParentID: c.ParentID, Position: c.Position, Version: c.Version}
+}
+
+type deleteResultDTO struct {
+	Strategy              DeleteStrategy `json:"strategy"`
+	DeletedCategoryIDs    []int          `json:"deletedCategoryIds"`
+	ReparentedCategoryIDs []int          `json:"reparentedCategoryIds"`
+	ReassignedProductIDs  []int          `json:"reassignedProductIds"`
+	DetachedProductIDs    []int          `json:"detachedProductIds"`
+}
+
+func toDeleteResultDTO(result *DeleteResult) deleteResultDTO {
+	return deleteResultDTO{
+		Strategy:              result.Strategy,
+		DeletedCategoryIDs:    result.DeletedCategoryIDs,
+		ReparentedCategoryIDs: result.ReparentedCategoryIDs,
+		ReassignedProductIDs:  result.ReassignedProductIDs,
+		DetachedProductIDs:    result.DetachedProductIDs,
+	}
+}
+
+type moveDTO struct {
+	ParentID *int `json:"parentId"`
+	BeforeID *int `json:"beforeId"`
+	AfterID  *int `json:"afterId"`
+}
+
+// categoryDetailDTO embeds the relations requested with ?expand. Relations
+// that were not requested are left out.
+type categoryDetailDTO struct {
+	categoryDTO
+	Categories []refDTO `json:"categories,omitzero"`
+}
+
+type refDTO struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func toRefDTOs(refs []Ref) []refDTO {
+	if refs == nil {
+		return nil
+	}
+	dtos := make([]refDTO, 0, len(refs))
+	for _, ref := range refs {
+		dtos = append(dtos, refDTO{ID: ref.ID, Name: ref.Name})
+	}
+	return dtos
+}
+
+type treeNodeDTO struct {
+	categoryDTO
+	Depth        int           `json:"depth"`
+	Path         []int         `json:"path"`
+	ChildCount   int           `json:"childCount"`
+	ProductCount int           `json:"productCount"`
+	Children     []treeNodeDTO `json:"children"`
+}
+
+func toTreeNodeDTOs(nodes []*TreeNode) []treeNodeDTO {
+	dtos := make([]treeNodeDTO, 0, len(nodes))
+	for _, node := range nodes {
+		dtos = append(dtos, treeNodeDTO{
+			categoryDTO:  toCategoryDTO(node.Category),
+			Depth:        node.Depth,
+			Path:         node.Path,
+			ChildCount:   len(node.Children),
+			ProductCount: node.ProductCount,
+			Children:     toTreeNodeDTOs(node.Children),
+		})
+	}
+	return dtos
 }
 
 func fromCategoryDTO(dto categoryDTO) Category {
@@ -31,6 +122,11 @@ func fromCategoryDTO(dto categoryDTO) Category {
 }
 
 func (h *HTTPHandler) List(w http.ResponseWriter, r *http.Request) {
+	if httpx.WantsCursor(r.URL.Query()) {
+		h.listAfter(w, r)
+		return
+	}
+
 	categories := h.queries.List()
 	response := make([]categoryDTO, 0, len(categories))
 	for _, category := range categories {
@@ -39,20 +135,86 @@ func (h *HTTPHandler) List(w http.ResponseWriter, r *http.Request) {
 	httpx.WriteJSON(w, response)
 }
 
+func (h *HTTPHandler) listAfter(w http.ResponseWriter, r *http.Request) {
+	cursor, err := httpx.ParseCursor(r.URL.Query())
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	categories, hasMore := h.queries.ListAfter(cursor.After, cursor.Limit)
+	response := httpx.CursorList[categoryDTO]{Items: make([]categoryDTO, 0, len(categories))}
+	for _, category := range categories {
+		response.Items = append(response.Items, toCategoryDTO(category))
+	}
+	if len(categories) > 0 {
+		response.NextCursor = httpx.NextCursor(categories[len(categories)-1].ID, hasMore)
+	}
+	httpx.WriteJSON(w, response)
+}
+
+func (h *HTTPHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	expand, err := httpx.ParseExpand(r.URL.Query(), "categories")
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	category, relations, err := h.queries.Get(id, Expand{Categories: expand["categories"]})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, category.Version)
+	httpx.WriteJSON(w, categoryDetailDTO{
+		categoryDTO: toCategoryDTO(category),
+		Categories:  toRefDTOs(relations.Categories),
+	})
+}
+
+// Tree returns the nested category hierarchy, or the subtree of ?root.
+func (h *HTTPHandler) Tree(w http.ResponseWriter, r *http.Request) {
+	var rootID *int
+	if value := r.URL.Query().Get("root"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			httpx.WriteError(w, r, httpx.InvalidParam("root", "must be a category ID"), errorMappings)
+			return
+		}
+		rootID = &id
+	}
+
+	nodes, err := h.queries.Tree(rootID)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.WriteJSON(w, toTreeNodeDTOs(nodes))
+}
+
 func (h *HTTPHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var payload categoryDTO
 	if err := httpx.ReadJSON(r, &payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	category := fromCategoryDTO(payload)
 	created, err := h.commands.Create(&category)
 	if err != nil {
-		http.Error(w, "Failed to persist category", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, created.Version)
 	w.WriteHeader(http.StatusCreated)
 	httpx.WriteJSON(w, toCategoryDTO(created))
 }
@@ -60,46 +222,158 @@ func (h *HTTPHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	var payload categoryDTO
 	if err := httpx.ReadJSON(r, &payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	category := fromCategoryDTO(payload)
 	category.ID = id
+	category.Version = version
 	updated, err := h.commands.Update(&category)
 	if err != nil {
-		http.Error(w, "Failed to persist category", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, updated.Version)
 	httpx.WriteJSON(w, toCategoryDTO(updated))
 }
 
+// Patch applies a JSON merge patch to the category, so fields the patch
+// leaves out keep their values.
+func (h *HTTPHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	current, _, err := h.queries.Get(id, Expand{})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	payload := toCategoryDTO(current)
+	if err := httpx.ReadMergePatch(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	category := fromCategoryDTO(payload)
+	category.ID = id
+	category.Version = httpx.PatchVersion(version, current.Version)
+	updated, err := h.commands.Update(&category)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, updated.Version)
+	httpx.WriteJSON(w, toCategoryDTO(updated))
+}
+
+// Move places the category under parentId, before or after one of its new
+// siblings.
+func (h *HTTPHandler) Move(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	var payload moveDTO
+	if err := httpx.ReadJSON(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	moved, err := h.commands.Move(Move{ID: id, ParentID: payload.ParentID, BeforeID: payload.BeforeID, AfterID: payload.AfterID, Version: version})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, moved.Version)
+	httpx.WriteJSON(w, toCategoryDTO(moved))
+}
+
+// Delete removes a category as chosen by ?strategy, rejecting categories in
+// use by default, and reports what it changed.
 func (h *HTTPHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
-	if err := h.commands.Delete(id); err != nil {
-		if errors.Is(err, ErrCategoryInUse) || errors.Is(err, ErrChildInUse) {
-			http.Error(w, "Category is in use by products", http.StatusConflict)
-			return
-		}
-		if errors.Is(err, ErrNotFound) {
-			http.Error(w, "Category not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to persist category", http.StatusInternalServerError)
+	opts, err := parseDeleteOptions(r.URL.Query())
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	opts.Version = version
+
+	result, err := h.commands.Delete(id, opts)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	httpx.WriteJSON(w, toDeleteResultDTO(result))
+}
+
+func parseDeleteOptions(query url.Values) (DeleteOptions, error) {
+	opts := DeleteOptions{Strategy: DeleteStrategy(query.Get("strategy"))}
+	if opts.Strategy == "" {
+		opts.Strategy = DeleteReject
+	}
+	if !opts.Strategy.Valid() {
+		return DeleteOptions{}, httpx.InvalidParam("strategy", "must be reject, reparent-children, reassign-products or detach-products")
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := strconv.Atoi(value)
+		if err != nil {
+			return DeleteOptions{}, httpx.InvalidParam("to", "must be a category ID")
+		}
+		opts.ReassignTo = &to
+	}
+	return opts, nil
+}
+
+// currentVersion reads the category's version for an If-Match header listing
+// several entity tags.
+func (h *HTTPHandler) currentVersion(id int) func() (int, error) {
+	return func() (int, error) {
+		c, _, err := h.queries.Get(id, Expand{})
+		if err != nil {
+			return 0, err
+		}
+		return c.Version, nil
+	}
 }
@@ -0,0 +1,298 @@
+package categories
+
+import (
+	"slices"
+	"sort"
+
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/tree"
+)
+
+type MemoryRepository struct {
+	store *memory.Store
+}
+
+func NewMemoryRepository(store *memory.Store) *MemoryRepository {
+	return &MemoryRepository{store: store}
+}
+
+func (r *MemoryRepository) GetCategories() []*Category {
+	var items []*Category
+	r.store.Read(func(t *memory.Tables) {
+		items = categoriesFromTables(t)
+	})
+	return items
+}
+
+func (r *MemoryRepository) GetCategoriesAfter(after, limit int) []*Category {
+	return memory.PageAfter(r.GetCategories(), func(c *Category) int { return c.ID }, after, limit)
+}
+
+func (r *MemoryRepository) GetCategory(id int) (*Category, error) {
+	var category *Category
+	r.store.Read(func(t *memory.Tables) {
+		if row, ok := t.Categories[id]; ok {
+			category = toCategory(row)
+		}
+	})
+	if category == nil {
+		return nil, ErrNotFound
+	}
+	return category, nil
+}
+
+func (r *MemoryRepository) GetCategoryRelations(id int, expand Expand) (*Relations, error) {
+	relations := &Relations{}
+	var err error
+	r.store.Read(func(t *memory.Tables) {
+		if _, ok := t.Categories[id]; !ok {
+			err = ErrNotFound
+			return
+		}
+		if expand.Categories {
+			relations.Categories = make([]Ref, 0)
+			for _, childID := range memory.SiblingIDs(t.Categories, categoryPlace, &id) {
+				relations.Categories = append(relations.Categories, Ref{ID: childID, Name: t.Categories[childID].Name})
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
+
+func (r *MemoryRepository) GetCategoryProductCounts() (map[int]int, error) {
+	counts := make(map[int]int)
+	r.store.Read(func(t *memory.Tables) {
+		parents := make(tree.Parents, len(t.Categories))
+		for id, row := range t.Categories {
+			parents[id] = row.ParentID
+		}
+		for _, p := range t.Products {
+			counted := make(map[int]bool)
+			for _, categoryID := range p.CategoryIDs {
+				for _, id := range parents.Path(categoryID) {
+					if !counted[id] {
+						counted[id] = true
+						counts[id]++
+					}
+				}
+			}
+		}
+	})
+	return counts, nil
+}
+
+func (r *MemoryRepository) CreateCategory(c *Category, placement tree.Placement) (*Category, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		if c.ParentID != nil {
+			if _, ok := t.Categories[*c.ParentID]; !ok {
+				return ErrInvalidParent
+			}
+		}
+		if err := placement.Check(memory.Parents(t.Categories, categoryPlace), 0, c.ParentID); err != nil {
+			return err
+		}
+		c.ID = t.NextID("categories")
+		c.Position = memory.NextPosition(t.Categories, categoryPlace, c.ParentID)
+		c.Version = 1
+		t.Categories[c.ID] = fromCategory(c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *MemoryRepository) UpdateCategory(c *Category, placement tree.Placement) (*Category, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		current, ok := t.Categories[c.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(current.Version, c.Version) {
+			return ErrVersionMismatch
+		}
+		if c.ParentID != nil {
+			if _, ok := t.Categories[*c.ParentID]; !ok {
+				return ErrInvalidParent
+			}
+		}
+		if err := placement.Check(memory.Parents(t.Categories, categoryPlace), c.ID, c.ParentID); err != nil {
+			return err
+		}
+		c.Position = current.Position
+		if !tree.SameParent(current.ParentID, c.ParentID) {
+			c.Position = memory.NextPosition(t.Categories, categoryPlace, c.ParentID)
+		}
+		c.Version = current.Version + 1
+		t.Categories[c.ID] = fromCategory(c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *MemoryRepository) MoveCategory(m Move, placement tree.Placement) (*Category, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Categories[m.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, m.Version) {
+			return ErrVersionMismatch
+		}
+		if m.ParentID != nil {
+			if _, ok := t.Categories[*m.ParentID]; !ok {
+				return ErrInvalidParent
+			}
+		}
+		if err := placement.Check(memory.Parents(t.Categories, categoryPlace), m.ID, m.ParentID); err != nil {
+			return err
+		}
+		ordered, ok := tree.Place(memory.SiblingIDs(t.Categories, categoryPlace, m.ParentID), m.ID, m.BeforeID, m.AfterID)
+		if !ok {
+			return ErrInvalidSibling
+		}
+		row.ParentID = memory.CopyIntPtr(m.ParentID)
+		row.Version++
+		for position, id := range ordered {
+			t.Categories[id].Position = position
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCategory(m.ID)
+}
+
+func (r *MemoryRepository) DeleteCategory(id int, opts DeleteOptions) (*DeleteResult, error) {
+	result := newDeleteResult(opts.Strategy)
+	err := r.store.Write(func(t *memory.Tables) error {
+		target, ok := t.Categories[id]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(target.Version, opts.Version) {
+			return ErrVersionMismatch
+		}
+		ownProductIDs := linkedProducts(t, []int{id})
+
+		if opts.Strategy == DeleteReparentChildren {
+			if len(ownProductIDs) > 0 {
+				return ErrCategoryInUse
+			}
+			children := memory.SiblingIDs(t.Categories, categoryPlace, &id)
+			siblings := memory.SiblingIDs(t.Categories, categoryPlace, target.ParentID)
+			for _, childID := range children {
+				t.Categories[childID].ParentID = memory.CopyIntPtr(target.ParentID)
+				t.Categories[childID].Version++
+			}
+			for position, siblingID := range tree.Promote(siblings, id, children) {
+				t.Categories[siblingID].Position = position
+			}
+			delete(t.Categories, id)
+			result.DeletedCategoryIDs = []int{id}
+			result.ReparentedCategoryIDs = children
+			return nil
+		}
+
+		allIDs := append([]int{id}, memory.Parents(t.Categories, categoryPlace).Descendants(id)...)
+		productIDs := linkedProducts(t, allIDs)
+		switch opts.Strategy {
+		case DeleteReassignProducts:
+			if slices.Contains(allIDs, *opts.ReassignTo) {
+				return ErrTargetDeleted
+			}
+			if _, ok := t.Categories[*opts.ReassignTo]; !ok {
+				return ErrInvalidTarget
+			}
+			for _, productID := range productIDs {
+				if p := t.Products[productID]; !memory.ContainsInt(p.CategoryIDs, *opts.ReassignTo) {
+					p.CategoryIDs = append(p.CategoryIDs, *opts.ReassignTo)
+				}
+			}
+			result.ReassignedProductIDs = productIDs
+		case DeleteDetachProducts:
+			result.DetachedProductIDs = productIDs
+		default:
+			if len(ownProductIDs) > 0 {
+				return ErrCategoryInUse
+			}
+			if len(productIDs) > 0 {
+				return ErrChildInUse
+			}
+		}
+
+		for _, productID := range productIDs {
+			p := t.Products[productID]
+			p.CategoryIDs = slices.DeleteFunc(p.CategoryIDs, func(c int) bool { return slices.Contains(allIDs, c) })
+		}
+		for _, cid := range allIDs {
+			delete(t.Categories, cid)
+		}
+		result.DeletedCategoryIDs = allIDs
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// linkedProducts lists the products linked to any of categoryIDs, in ID order.
+func linkedProducts(t *memory.Tables, categoryIDs []int) []int {
+	ids := make([]int, 0)
+	for _, p := range t.Products {
+		for _, categoryID := range categoryIDs {
+			if memory.ContainsInt(p.CategoryIDs, categoryID) {
+				ids = append(ids, p.ID)
+				break
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (r *MemoryRepository) MissingCategoryIDs(ids []int) ([]int, error) {
+	var missing []int
+	r.store.Read(func(t *memory.Tables) {
+		missing = memory.MissingIDs(t.Categories, ids)
+	})
+	return missing, nil
+}
+
+func (r *MemoryRepository) GetCategoryParents() (tree.Parents, error) {
+	var parents tree.Parents
+	r.store.Read(func(t *memory.Tables) {
+		parents = memory.Parents(t.Categories, categoryPlace)
+	})
+	return parents, nil
+}
+
+func categoriesFromTables(t *memory.Tables) []*Category {
+	items := make([]*Category, 0, len(t.Categories))
+	for _, row := range t.Categories {
+		items = append(items, toCategory(row))
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func toCategory(row *memory.CategoryRow) *Category {
+	return &Category{ID: row.ID, Name: row.Name, ParentID: memory.CopyIntPtr(row.ParentID), Position: row.Position, Version: row.Version}
+}
+
+func fromCategory(c *Category) *memory.CategoryRow {
+	return &memory.CategoryRow{ID: c.ID, Name: c.Name, ParentID: memory.CopyIntPtr(c.ParentID), Position: c.Position, Version: c.Version}
+}
+
+func categoryPlace(row *memory.CategoryRow) (*int, int) {
+	return row.ParentID, row.Position
+}
@@ -1,7 +1,104 @@
 package categories
 
+import "categories-test/internal/platform/tree"
+
+// Category is a node of the category tree. Position orders it among its
+// siblings and is only changed by moving the category. Version counts its
+// updates; when updating, it holds the version the caller read, or zero to
+// skip the check.
 type Category struct {
 	ID       int
 	Name     string
 	ParentID *int
+	Position int
+	Version  int
+}
+
+// Move places a category among its new siblings.
+type Move = tree.Move
+
+// DeleteStrategy decides what happens to the products and subcategories of a
+// deleted category.
+type DeleteStrategy string
+
+const (
+	// DeleteReject deletes the category and its descendants only when no
+	// product uses any of them.
+	DeleteReject DeleteStrategy = "reject"
+	// DeleteReparentChildren deletes only the category, which must not have
+	// products, and puts its children in its place.
+	DeleteReparentChildren DeleteStrategy = "reparent-children"
+	// DeleteReassignProducts deletes the category and its descendants and
+	// links their products to DeleteOptions.ReassignTo instead.
+	DeleteReassignProducts DeleteStrategy = "reassign-products"
+	// DeleteDetachProducts deletes the category and its descendants and
+	// unlinks their products.
+	DeleteDetachProducts DeleteStrategy = "detach-products"
+)
+
+func (s DeleteStrategy) Valid() bool {
+	switch s {
+	case DeleteReject, DeleteReparentChildren, DeleteReassignProducts, DeleteDetachProducts:
+		return true
+	}
+	return false
+}
+
+// DeleteOptions configure a delete. The zero value rejects categories in use.
+// A non-zero Version must match the category's.
+type DeleteOptions struct {
+	Strategy   DeleteStrategy
+	ReassignTo *int
+	Version    int
+}
+
+// DeleteResult reports what a delete changed.
+type DeleteResult struct {
+	Strategy              DeleteStrategy
+	DeletedCategoryIDs    []int
+	ReparentedCategoryIDs []int
+	ReassignedProductIDs  []int
+	DetachedProductIDs    []int
+}
+
+func newDeleteResult(strategy DeleteStrategy) *DeleteResult {
+	if strategy == "" {
+		strategy = DeleteReject
+	}
+	return &DeleteResult{
+		Strategy:              strategy,
+		DeletedCategoryIDs:    []int{},
+		ReparentedCategoryIDs: []int{},
+		ReassignedProductIDs:  []int{},
+		DetachedProductIDs:    []int{},
+	}
+}
+
+// Expand selects the relations embedded in a single category. Categories
+// embeds its direct subcategories.
+type Expand struct {
+	Categories bool
+}
+
+// Relations are the expanded relations of a category. Relations that were not
+// requested are nil.
+type Relations struct {
+	Categories []Ref
+}
+
+// TreeNode places a category in the hierarchy. Path lists the category IDs
+// from the root down to the category itself, and ProductCount counts the
+// distinct products in the category or any of its descendants.
+type TreeNode struct {
+	Category     *Category
+	Depth        int
+	Path         []int
+	ProductCount int
+	Children     []*TreeNode
+}
+
+// Ref names a related resource.
+type Ref struct {
+	ID   int
+	Name string
 }
@@ -1,5 +1,12 @@
 package categories
 
+import (
+	"cmp"
+	"slices"
+
+	"categories-test/internal/platform/tree"
+)
+
 type Queries struct {
 	repo QueryRepository
 }
@@ -11,3 +18,87 @@ func NewQueries(repo QueryRepository) *Queries {
 func (q *Queries) List() []*Category {
 	return q.repo.GetCategories()
 }
+
+// ListAfter returns up to limit categories following the given ID and whether
+// more categories follow them.
+func (q *Queries) ListAfter(after, limit int) ([]*Category, bool) {
+	items := q.repo.GetCategoriesAfter(after, limit+1)
+	if len(items) > limit {
+		return items[:limit], true
+	}
+	return items, false
+}
+
+// Get returns a category with the requested relations.
+func (q *Queries) Get(id int, expand Expand) (*Category, *Relations, error) {
+	category, err := q.repo.GetCategory(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	relations, err := q.repo.GetCategoryRelations(id, expand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return category, relations, nil
+}
+
+// Tree returns the category hierarchy, or only the subtree of rootID when it is
+// set. Siblings are ordered by position. Categories caught in a parent cycle
+// are left out of the full hierarchy; a subtree lists each category once, so
+// one rooted in a cycle stops where the cycle closes.
+func (q *Queries) Tree(rootID *int) ([]*TreeNode, error) {
+	categories := q.repo.GetCategories()
+	slices.SortStableFunc(categories, func(a, b *Category) int { return cmp.Compare(a.Position, b.Position) })
+	counts, err := q.repo.GetCategoryProductCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	parents := make(tree.Parents, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentID
+	}
+
+	nodes := make(map[int]*TreeNode, len(categories))
+	children := make(map[int][]*TreeNode)
+	roots := make([]*TreeNode, 0)
+	for _, c := range categories {
+		path := parents.Path(c.ID)
+		node := &TreeNode{Category: c, Depth: len(path), Path: path, ProductCount: counts[c.ID]}
+		nodes[c.ID] = node
+		if c.ParentID == nil {
+			roots = append(roots, node)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], node)
+		}
+	}
+
+	result := roots
+	if rootID != nil {
+		node, ok := nodes[*rootID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		result = []*TreeNode{node}
+	}
+
+	// Link children breadth-first from the result, attaching each node once,
+	// so it stays a tree even when the data holds a cycle.
+	linked := make(map[int]bool, len(nodes))
+	for _, node := range result {
+		linked[node.Category.ID] = true
+	}
+	queue := append([]*TreeNode{}, result...)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, child := range children[node.Category.ID] {
+			if !linked[child.Category.ID] {
+				linked[child.Category.ID] = true
+				node.Children = append(node.Children, child)
+			}
+		}
+		queue = append(queue, node.Children...)
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package categories
+
+import (
+	"testing"
+
+	"categories-test/internal/platform/memory"
+)
+
+func TestTreeRootedInCycle(t *testing.T) {
+	// 1 ─ 2, and 3 → 4 → 5 → 3 is a cycle with 6 hanging off 4.
+	store := memory.NewStore()
+	store.Write(func(t *memory.Tables) error {
+		t.Categories[1] = &memory.CategoryRow{ID: 1, Name: "One"}
+		t.Categories[2] = &memory.CategoryRow{ID: 2, Name: "Two", ParentID: intPtr(1)}
+		t.Categories[3] = &memory.CategoryRow{ID: 3, Name: "Three", ParentID: intPtr(5)}
+		t.Categories[4] = &memory.CategoryRow{ID: 4, Name: "Four", ParentID: intPtr(3)}
+		t.Categories[5] = &memory.CategoryRow{ID: 5, Name: "Five", ParentID: intPtr(4)}
+		t.Categories[6] = &memory.CategoryRow{ID: 6, Name: "Six", ParentID: intPtr(4), Position: 1}
+		return nil
+	})
+	queries := NewQueries(NewMemoryRepository(store))
+
+	roots, err := queries.Tree(nil)
+	if err != nil {
+		t.Fatalf("Tree: %v", err)
+	}
+	if len(roots) != 1 || roots[0].Category.ID != 1 || len(roots[0].Children) != 1 {
+		t.Errorf("Tree() = %+v, want only 1 ─ 2", roots)
+	}
+
+	subtree, err := queries.Tree(intPtr(3))
+	if err != nil {
+		t.Fatalf("Tree(3): %v", err)
+	}
+	three := subtree[0]
+	if len(three.Children) != 1 || three.Children[0].Category.ID != 4 {
+		t.Fatalf("children of 3 = %+v, want [4]", three.Children)
+	}
+	four := three.Children[0]
+	if len(four.Children) != 2 || four.Children[0].Category.ID != 5 || four.Children[1].Category.ID != 6 {
+		t.Fatalf("children of 4 = %+v, want [5 6]", four.Children)
+	}
+	if len(four.Children[0].Children) != 0 {
+		t.Errorf("5 links back to 3: %+v", four.Children[0].Children)
+	}
+}
@@ -1,11 +1,23 @@
 package categories
 
+import "categories-test/internal/platform/tree"
+
 type CommandRepository interface {
-	CreateCategory(c *Category) (*Category, error)
-	UpdateCategory(c *Category) (*Category, error)
-	DeleteCategory(id int) error
+	// CreateCategory, UpdateCategory and MoveCategory check placement in the
+	// transaction that writes the category.
+	CreateCategory(c *Category, placement tree.Placement) (*Category, error)
+	UpdateCategory(c *Category, placement tree.Placement) (*Category, error)
+	MoveCategory(m Move, placement tree.Placement) (*Category, error)
+	// DeleteCategory checks the category products are reassigned to in its
+	// transaction too.
+	DeleteCategory(id int, opts DeleteOptions) (*DeleteResult, error)
+	MissingCategoryIDs(ids []int) ([]int, error)
 }
 
 type QueryRepository interface {
 	GetCategories() []*Category
+	GetCategoriesAfter(after, limit int) []*Category
+	GetCategory(id int) (*Category, error)
+	GetCategoryRelations(id int, expand Expand) (*Relations, error)
+	GetCategoryProductCounts() (map[int]int, error)
 }
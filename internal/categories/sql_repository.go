@@ -0,0 +1,379 @@
+package categories
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/tree"
+)
+
+var versionErrors = db.VersionErrors{NotFound: ErrNotFound, Mismatch: ErrVersionMismatch}
+
+type SQLRepository struct {
+	db *db.Client
+}
+
+func (r *SQLRepository) GetCategories() []*Category {
+	return r.queryCategories(`SELECT id, name, parent_id, position, version FROM categories ORDER BY id;`)
+}
+
+func (r *SQLRepository) GetCategoriesAfter(after, limit int) []*Category {
+	return r.queryCategories(`SELECT id, name, parent_id, position, version FROM categories WHERE id > ? ORDER BY id LIMIT ?;`, after, limit)
+}
+
+func (r *SQLRepository) GetCategory(id int) (*Category, error) {
+	c := &Category{}
+	var parentID sql.NullInt64
+	err := r.db.QueryRow(`SELECT id, name, parent_id, position, version FROM categories WHERE id = ?;`, id).
+		Scan(&c.ID, &c.Name, &parentID, &c.Position, &c.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.ParentID = db.IntPtr(parentID)
+	return c, nil
+}
+
+func (r *SQLRepository) GetCategoryRelations(id int, expand Expand) (*Relations, error) {
+	relations := &Relations{}
+	if !expand.Categories {
+		return relations, nil
+	}
+
+	rows, err := r.db.Query(`SELECT id, name FROM categories WHERE parent_id = ? ORDER BY position, id;`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relations.Categories = make([]Ref, 0)
+	for rows.Next() {
+		var ref Ref
+		if err := rows.Scan(&ref.ID, &ref.Name); err != nil {
+			return nil, err
+		}
+		relations.Categories = append(relations.Categories, ref)
+	}
+	return relations, rows.Err()
+}
+
+// GetCategoryProductCounts counts the distinct products of each category and
+// its descendants. Categories without products are left out.
+func (r *SQLRepository) GetCategoryProductCounts() (map[int]int, error) {
+	rows, err := r.db.Query(
+		`WITH RECURSIVE subtree(category_id, descendant_id) AS (
+			SELECT id, id FROM categories
+			UNION
+			SELECT s.category_id, c.id FROM categories c
+			JOIN subtree s ON c.parent_id = s.descendant_id
+		)
+		SELECT s.category_id, COUNT(DISTINCT pc.product_id)
+		FROM subtree s
+		JOIN product_categories pc ON pc.category_id = s.descendant_id
+		GROUP BY s.category_id;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *SQLRepository) queryCategories(query string, args ...any) []*Category {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return []*Category{}
+	}
+	defer rows.Close()
+
+	items := make([]*Category, 0)
+	for rows.Next() {
+		c := &Category{}
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Name, &parentID, &c.Position, &c.Version); err != nil {
+			return []*Category{}
+		}
+		c.ParentID = db.IntPtr(parentID)
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		return []*Category{}
+	}
+	return items
+}
+
+// CreateCategory places the category after its last sibling.
+func (r *SQLRepository) CreateCategory(c *Category, placement tree.Placement) (*Category, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := tx.CheckPlacement("categories", placement, 0, c.ParentID); err != nil {
+			return err
+		}
+		position, err := tx.NextPosition("categories", c.ParentID)
+		if err != nil {
+			return err
+		}
+		c.Position = position
+		return tx.QueryRow(
+			`INSERT INTO categories(name, parent_id, position) VALUES (?, ?, ?) RETURNING id, version;`,
+			c.Name, db.NullableInt(c.ParentID), c.Position,
+		).Scan(&c.ID, &c.Version)
+	})
+	if err != nil {
+		if db.IsForeignKeyViolation(err) {
+			return nil, ErrInvalidParent
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// UpdateCategory keeps the category's position, unless it changes parent and
+// goes after its new siblings.
+func (r *SQLRepository) UpdateCategory(c *Category, placement tree.Placement) (*Category, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := tx.CheckPlacement("categories", placement, c.ID, c.ParentID); err != nil {
+			return err
+		}
+		version, err := tx.BumpVersion("categories", c.ID, c.Version, versionErrors)
+		if err != nil {
+			return err
+		}
+		current, err := (&SQLRepository{db: tx}).GetCategory(c.ID)
+		if err != nil {
+			return err
+		}
+		c.Version = version
+		c.Position = current.Position
+		if !tree.SameParent(current.ParentID, c.ParentID) {
+			if c.Position, err = tx.NextPosition("categories", c.ParentID); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(
+			`UPDATE categories SET name = ?, parent_id = ?, position = ? WHERE id = ?;`,
+			c.Name, db.NullableInt(c.ParentID), c.Position, c.ID,
+		)
+		return err
+	})
+	if err != nil {
+		if db.IsForeignKeyViolation(err) {
+			return nil, ErrInvalidParent
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// MoveCategory reparents the category and renumbers its new siblings in one
+// transaction.
+func (r *SQLRepository) MoveCategory(m Move, placement tree.Placement) (*Category, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := tx.CheckPlacement("categories", placement, m.ID, m.ParentID); err != nil {
+			return err
+		}
+		if _, err := tx.BumpVersion("categories", m.ID, m.Version, versionErrors); err != nil {
+			return err
+		}
+		siblings, err := tx.SiblingIDs("categories", m.ParentID)
+		if err != nil {
+			return err
+		}
+		ordered, ok := tree.Place(siblings, m.ID, m.BeforeID, m.AfterID)
+		if !ok {
+			return ErrInvalidSibling
+		}
+		if _, err := tx.Exec(`UPDATE categories SET parent_id = ? WHERE id = ?;`, db.NullableInt(m.ParentID), m.ID); err != nil {
+			return err
+		}
+		return tx.SetPositions("categories", ordered)
+	})
+	if err != nil {
+		if db.IsForeignKeyViolation(err) {
+			return nil, ErrInvalidParent
+		}
+		return nil, err
+	}
+	return r.GetCategory(m.ID)
+}
+
+// DeleteCategory applies the delete strategy in a single transaction.
+func (r *SQLRepository) DeleteCategory(id int, opts DeleteOptions) (*DeleteResult, error) {
+	result := newDeleteResult(opts.Strategy)
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if _, err := tx.BumpVersion("categories", id, opts.Version, versionErrors); err != nil {
+			return err
+		}
+		repo := &SQLRepository{db: tx}
+		target, err := repo.GetCategory(id)
+		if err != nil {
+			return err
+		}
+		ownProductIDs, err := linkedProductIDs(tx, []int{id})
+		if err != nil {
+			return err
+		}
+
+		if opts.Strategy == DeleteReparentChildren {
+			if len(ownProductIDs) > 0 {
+				return ErrCategoryInUse
+			}
+			children, err := tx.SiblingIDs("categories", &id)
+			if err != nil {
+				return err
+			}
+			siblings, err := tx.SiblingIDs("categories", target.ParentID)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE categories SET parent_id = ?, version = version + 1 WHERE parent_id = ?;`, db.NullableInt(target.ParentID), id); err != nil {
+				return err
+			}
+			if err := tx.SetPositions("categories", tree.Promote(siblings, id, children)); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM categories WHERE id = ?;`, id); err != nil {
+				return err
+			}
+			result.DeletedCategoryIDs = []int{id}
+			result.ReparentedCategoryIDs = children
+			return nil
+		}
+
+		descendantIDs, err := descendantIDs(tx, id)
+		if err != nil {
+			return err
+		}
+		allIDs := append([]int{id}, descendantIDs...)
+		productIDs, err := linkedProductIDs(tx, allIDs)
+		if err != nil {
+			return err
+		}
+		in, args := db.Placeholders(allIDs)
+		switch opts.Strategy {
+		case DeleteReassignProducts:
+			if slices.Contains(allIDs, *opts.ReassignTo) {
+				return ErrTargetDeleted
+			}
+			missing, err := tx.MissingIDs("categories", []int{*opts.ReassignTo})
+			if err != nil {
+				return err
+			}
+			if len(missing) > 0 {
+				return ErrInvalidTarget
+			}
+			if _, err := tx.ExecDynamic(
+				`INSERT INTO product_categories(product_id, category_id)
+				SELECT DISTINCT product_id, CAST(? AS INTEGER) FROM product_categories WHERE category_id IN (`+in+`)
+				ON CONFLICT DO NOTHING;`,
+				append([]any{*opts.ReassignTo}, args...)...,
+			); err != nil {
+				if db.IsForeignKeyViolation(err) {
+					return ErrInvalidTarget
+				}
+				return err
+			}
+			result.ReassignedProductIDs = productIDs
+		case DeleteDetachProducts:
+			result.DetachedProductIDs = productIDs
+		default:
+			if len(ownProductIDs) > 0 {
+				return ErrCategoryInUse
+			}
+			if len(productIDs) > 0 {
+				return ErrChildInUse
+			}
+		}
+		if _, err := tx.ExecDynamic(`DELETE FROM product_categories WHERE category_id IN (`+in+`);`, args...); err != nil {
+			return err
+		}
+
+		// Children reference their parent, so delete the deepest categories first.
+		for i := len(allIDs) - 1; i >= 0; i-- {
+			if _, err := tx.Exec(`DELETE FROM categories WHERE id = ?;`, allIDs[i]); err != nil {
+				return err
+			}
+		}
+		result.DeletedCategoryIDs = allIDs
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// linkedProductIDs lists the distinct products linked to any of categoryIDs.
+func linkedProductIDs(tx *db.Client, categoryIDs []int) ([]int, error) {
+	in, args := db.Placeholders(categoryIDs)
+	rows, err := tx.QueryDynamic(
+		`SELECT DISTINCT product_id FROM product_categories WHERE category_id IN (`+in+`) ORDER BY product_id;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *SQLRepository) MissingCategoryIDs(ids []int) ([]int, error) {
+	return r.db.MissingIDs("categories", ids)
+}
+
+func (r *SQLRepository) GetCategoryParents() (tree.Parents, error) {
+	return r.db.Parents("categories")
+}
+
+// descendantIDs lists the categories below id, every parent before its
+// children. UNION keeps the walk finite even if the hierarchy has a cycle.
+func descendantIDs(tx *db.Client, id int) ([]int, error) {
+	rows, err := tx.Query(
+		`WITH RECURSIVE subtree(id, parent_id) AS (
+			SELECT id, parent_id FROM categories WHERE parent_id = ?
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT id, parent_id FROM subtree;`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := make(tree.Parents)
+	for rows.Next() {
+		var childID int
+		var parentID sql.NullInt64
+		if err := rows.Scan(&childID, &parentID); err != nil {
+			return nil, err
+		}
+		parents[childID] = db.IntPtr(parentID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return parents.Descendants(id), nil
+}
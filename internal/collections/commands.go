@@ -1,21 +1,80 @@
 package collections
 
+import (
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/platform/validate"
+)
+
 type Commands struct {
-	repo CommandRepository
+	repo      CommandRepository
+	placement tree.Placement
+}
+
+// NewPlacement returns the rules that keep the collection tree free of cycles
+// and no deeper than configured.
+func NewPlacement(opts ...tree.Option) tree.Placement {
+	return tree.NewPlacement(ErrCycle, ErrTooDeep, opts...)
 }
 
-func NewCommands(repo CommandRepository) *Commands {
-	return &Commands{repo: repo}
+func NewCommands(repo CommandRepository, opts ...tree.Option) *Commands {
+	return &Commands{repo: repo, placement: NewPlacement(opts...)}
 }
 
 func (c *Commands) Create(collection *Collection) (*Collection, error) {
-	return c.repo.CreateCollection(collection)
+	if err := c.validate(collection); err != nil {
+		return nil, err
+	}
+	return c.repo.CreateCollection(collection, c.placement)
 }
 
 func (c *Commands) Update(collection *Collection) (*Collection, error) {
-	return c.repo.UpdateCollection(collection)
+	if err := c.validate(collection); err != nil {
+		return nil, err
+	}
+	return c.repo.UpdateCollection(collection, c.placement)
+}
+
+// Move places a collection among the children of a new parent, or among the
+// roots, applying the same placement rules as Update.
+func (c *Commands) Move(move Move) (*Collection, error) {
+	var errs validate.Errors
+	if err := errs.Move(move, c.repo.MissingCollectionIDs); err != nil {
+		return nil, err
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return c.repo.MoveCollection(move, c.placement)
+}
+
+func (c *Commands) Delete(id int, opts DeleteOptions) (*DeleteResult, error) {
+	return c.repo.DeleteCollection(id, opts)
+}
+
+// AddProducts adds products to the collection, keeping those it holds.
+func (c *Commands) AddProducts(id, version int, productIDs []int) (*Collection, error) {
+	var errs validate.Errors
+	if err := errs.Links("productIds", productIDs, c.repo.MissingProductIDs); err != nil {
+		return nil, err
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return c.repo.UpdateCollectionProducts(ProductLinks{CollectionID: id, Add: productIDs, Version: version})
+}
+
+func (c *Commands) RemoveProduct(id, version, productID int) (*Collection, error) {
+	return c.repo.UpdateCollectionProducts(ProductLinks{CollectionID: id, Remove: []int{productID}, Version: version})
 }
 
-func (c *Commands) Delete(id int) error {
-	return c.repo.DeleteCollection(id)
+func (c *Commands) validate(collection *Collection) error {
+	var errs validate.Errors
+	errs.Name("name", collection.Name)
+	if err := errs.Parent("parentId", collection.ID, collection.ParentID, c.repo.MissingCollectionIDs); err != nil {
+		return err
+	}
+	if err := errs.References("productIds", collection.ProductIDs, c.repo.MissingProductIDs); err != nil {
+		return err
+	}
+	return errs.Err()
 }
@@ -0,0 +1,112 @@
+package collections
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/platform/validate"
+)
+
+func TestCommandsValidate(t *testing.T) {
+	store := memory.NewStore()
+	store.Write(func(t *memory.Tables) error {
+		t.Products[1] = &memory.ProductRow{ID: 1, Name: "Tee"}
+		return nil
+	})
+	commands := NewCommands(NewMemoryRepository(store))
+	summer, err := commands.Create(&Collection{Name: "Summer"})
+	if err != nil {
+		t.Fatalf("Create summer: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		collection Collection
+		update     bool
+		want       []string
+	}{
+		{"valid", Collection{Name: "Beach", ParentID: &summer.ID, ProductIDs: []int{1}}, false, nil},
+		{"blank name", Collection{Name: "\t"}, false, []string{"name:required"}},
+		{"unknown parent", Collection{Name: "Beach", ParentID: intPtr(404)}, false, []string{"parentId:not_found"}},
+		{"own parent", Collection{ID: summer.ID, Name: "Summer", ParentID: &summer.ID}, true, []string{"parentId:self_reference"}},
+		{"unknown product", Collection{Name: "Beach", ProductIDs: []int{404}}, false, []string{"productIds:not_found"}},
+		{"repeated product", Collection{Name: "Beach", ProductIDs: []int{1, 1}}, false, []string{"productIds:duplicate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := tt.collection
+			if tt.update {
+				_, err = commands.Update(&collection)
+			} else {
+				_, err = commands.Create(&collection)
+			}
+			if got := fieldCodes(err); !slices.Equal(got, tt.want) {
+				t.Errorf("error = %v, want fields %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandsPlacement(t *testing.T) {
+	commands := NewCommands(NewMemoryRepository(memory.NewStore()), tree.WithMaxDepth(3))
+	create := func(name string, parentID *int) *Collection {
+		t.Helper()
+		collection, err := commands.Create(&Collection{Name: name, ParentID: parentID})
+		if err != nil {
+			t.Fatalf("Create %s: %v", name, err)
+		}
+		return collection
+	}
+	root := create("Summer", nil)
+	child := create("Beach", &root.ID)
+	leaf := create("Towels", &child.ID)
+	sale := create("Sale", nil)
+	clearance := create("Clearance", &sale.ID)
+
+	tests := []struct {
+		name       string
+		collection Collection
+		update     bool
+		want       error
+	}{
+		{"under own descendant", Collection{ID: root.ID, Name: "Summer", ParentID: &leaf.ID}, true, ErrCycle},
+		{"below max depth", Collection{Name: "Extra", ParentID: &leaf.ID}, false, ErrTooDeep},
+		{"subtree below max depth", Collection{ID: child.ID, Name: "Beach", ParentID: &clearance.ID}, true, ErrTooDeep},
+		{"subtree within max depth", Collection{ID: child.ID, Name: "Beach", ParentID: &sale.ID}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := tt.collection
+			var err error
+			if tt.update {
+				_, err = commands.Update(&collection)
+			} else {
+				_, err = commands.Create(&collection)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// fieldCodes lists the "field:code" pairs of a validation error in order, or
+// nil when err is not one.
+func fieldCodes(err error) []string {
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	codes := make([]string, 0, len(invalid.Fields))
+	for _, field := range invalid.Fields {
+		codes = append(codes, field.Field+":"+field.Code)
+	}
+	return codes
+}
@@ -2,4 +2,14 @@ package collections
 
 import "errors"
 
-var ErrNotFound = errors.New("collection not found")
+var (
+	ErrNotFound         = errors.New("collection not found")
+	ErrInvalidParent    = errors.New("parent collection not found")
+	ErrCycle            = errors.New("collection would become its own ancestor")
+	ErrTooDeep          = errors.New("collection tree too deep")
+	ErrInvalidProduct   = errors.New("collection references unknown product")
+	ErrHasChildren      = errors.New("collection has child collections")
+	ErrDuplicateProduct = errors.New("collection lists a product more than once")
+	ErrInvalidSibling   = errors.New("sibling collection not found under parent")
+	ErrVersionMismatch  = errors.New("collection was modified since it was read")
+)
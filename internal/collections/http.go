@@ -1,8 +1,8 @@
 package collections
 
 import (
-	"errors"
 	"net/http"
+	"strconv"
 
 	"categories-test/internal/platform/httpx"
 )
@@ -16,15 +16,109 @@ func NewHTTPHandler(commands *Commands, queries *Queries) *HTTPHandler {
 	return &HTTPHandler{commands: commands, queries: queries}
 }
 
+// errorMappings maps collection errors to problem responses.
+var errorMappings = []httpx.ErrorMapping{
+	{Err: ErrNotFound, Status: http.StatusNotFound, Code: "collection_not_found"},
+	{Err: ErrInvalidParent, Status: http.StatusUnprocessableEntity, Code: "unknown_parent", Field: "parentId"},
+	{Err: ErrCycle, Status: http.StatusUnprocessableEntity, Code: "parent_cycle", Field: "parentId"},
+	{Err: ErrTooDeep, Status: http.StatusUnprocessableEntity, Code: "too_deep", Field: "parentId"},
+	{Err: ErrInvalidSibling, Status: http.StatusUnprocessableEntity, Code: "unknown_sibling"},
+	{Err: ErrInvalidProduct, Status: http.StatusUnprocessableEntity, Code: "unknown_product", Field: "productIds"},
+	{Err: ErrDuplicateProduct, Status: http.StatusUnprocessableEntity, Code: "duplicate_product", Field: "productIds"},
+	{Err: ErrHasChildren, Status: http.StatusConflict, Code: "collection_has_children"},
+	{Err: ErrVersionMismatch, Status: http.StatusPreconditionFailed, Code: "version_mismatch"},
+}
+
+// collectionDTO is read back on create and update, where position and
+// version are ignored; collections are reordered with a move, and updates are
+// checked against If-Match.
 type collectionDTO struct {
 	ID         int    `json:"id"`
 	Name       string `json:"name"`
 	ParentID   *int   `json:"parentId"`
+	Position   int    `json:"position"`
 	ProductIDs []int  `json:"productIds"`
+	Version    int    `json:"version"`
 }
 
 func toCollectionDTO(c *Collection) collectionDTO {
-	return collectionDTO{ID: c.ID, Name: c.Name, ParentID: c.ParentID, ProductIDs: c.ProductIDs}
+	return collectionDTO{ID: c.ID, Name: c.Name, ParentID: c.ParentID, Position: c.Position, ProductIDs: c.ProductIDs, Version: c.Version}
+}
+
+type productLinksDTO struct {
+	ProductIDs []int `json:"productIds"`
+}
+
+type deleteResultDTO struct {
+	Mode                    DeleteMode `json:"mode"`
+	DeletedCollectionIDs    []int      `json:"deletedCollectionIds"`
+	ReparentedCollectionIDs []int      `json:"reparentedCollectionIds"`
+	AffectedShops           []refDTO   `json:"affectedShops"`
+	ShopsNowExposingAll     []refDTO   `json:"shopsNowExposingAll"`
+}
+
+func toDeleteResultDTO(result *DeleteResult) deleteResultDTO {
+	return deleteResultDTO{
+		Mode:                    result.Mode,
+		DeletedCollectionIDs:    result.DeletedCollectionIDs,
+		ReparentedCollectionIDs: result.ReparentedCollectionIDs,
+		AffectedShops:           toRefDTOs(result.AffectedShops),
+		ShopsNowExposingAll:     toRefDTOs(result.ShopsNowExposingAll),
+	}
+}
+
+type moveDTO struct {
+	ParentID *int `json:"parentId"`
+	BeforeID *int `json:"beforeId"`
+	AfterID  *int `json:"afterId"`
+}
+
+// collectionDetailDTO embeds the relations requested with ?expand. Relations
+// that were not requested are left out.
+type collectionDetailDTO struct {
+	collectionDTO
+	Collections []refDTO `json:"collections,omitzero"`
+	Shops       []refDTO `json:"shops,omitzero"`
+}
+
+type refDTO struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func toRefDTOs(refs []Ref) []refDTO {
+	if refs == nil {
+		return nil
+	}
+	dtos := make([]refDTO, 0, len(refs))
+	for _, ref := range refs {
+		dtos = append(dtos, refDTO{ID: ref.ID, Name: ref.Name})
+	}
+	return dtos
+}
+
+type treeNodeDTO struct {
+	collectionDTO
+	Depth        int           `json:"depth"`
+	Path         []int         `json:"path"`
+	ChildCount   int           `json:"childCount"`
+	ProductCount int           `json:"productCount"`
+	Children     []treeNodeDTO `json:"children"`
+}
+
+func toTreeNodeDTOs(nodes []*TreeNode) []treeNodeDTO {
+	dtos := make([]treeNodeDTO, 0, len(nodes))
+	for _, node := range nodes {
+		dtos = append(dtos, treeNodeDTO{
+			collectionDTO: toCollectionDTO(node.Collection),
+			Depth:         node.Depth,
+			Path:          node.Path,
+			ChildCount:    len(node.Children),
+			ProductCount:  node.ProductCount,
+			Children:      toTreeNodeDTOs(node.Children),
+		})
+	}
+	return dtos
 }
 
 func fromCollectionDTO(dto collectionDTO) Collection {
@@ -32,6 +126,11 @@ func fromCollectionDTO(dto collectionDTO) Collection {
 }
 
 func (h *HTTPHandler) List(w http.ResponseWriter, r *http.Request) {
+	if httpx.WantsCursor(r.URL.Query()) {
+		h.listAfter(w, r)
+		return
+	}
+
 	collections := h.queries.List()
 	response := make([]collectionDTO, 0, len(collections))
 	for _, collection := range collections {
@@ -40,20 +139,90 @@ func (h *HTTPHandler) List(w http.ResponseWriter, r *http.Request) {
 	httpx.WriteJSON(w, response)
 }
 
+func (h *HTTPHandler) listAfter(w http.ResponseWriter, r *http.Request) {
+	cursor, err := httpx.ParseCursor(r.URL.Query())
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	collections, hasMore := h.queries.ListAfter(cursor.After, cursor.Limit)
+	response := httpx.CursorList[collectionDTO]{Items: make([]collectionDTO, 0, len(collections))}
+	for _, collection := range collections {
+		response.Items = append(response.Items, toCollectionDTO(collection))
+	}
+	if len(collections) > 0 {
+		response.NextCursor = httpx.NextCursor(collections[len(collections)-1].ID, hasMore)
+	}
+	httpx.WriteJSON(w, response)
+}
+
+func (h *HTTPHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	expand, err := httpx.ParseExpand(r.URL.Query(), "collections", "shops")
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	collection, relations, err := h.queries.Get(id, Expand{
+		Collections: expand["collections"],
+		Shops:       expand["shops"],
+	})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, collection.Version)
+	httpx.WriteJSON(w, collectionDetailDTO{
+		collectionDTO: toCollectionDTO(collection),
+		Collections:   toRefDTOs(relations.Collections),
+		Shops:         toRefDTOs(relations.Shops),
+	})
+}
+
+// Tree returns the nested collection hierarchy, or the subtree of ?root.
+func (h *HTTPHandler) Tree(w http.ResponseWriter, r *http.Request) {
+	var rootID *int
+	if value := r.URL.Query().Get("root"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			httpx.WriteError(w, r, httpx.InvalidParam("root", "must be a collection ID"), errorMappings)
+			return
+		}
+		rootID = &id
+	}
+
+	nodes, err := h.queries.Tree(rootID)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.WriteJSON(w, toTreeNodeDTOs(nodes))
+}
+
 func (h *HTTPHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var payload collectionDTO
 	if err := httpx.ReadJSON(r, &payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	collection := fromCollectionDTO(payload)
 	created, err := h.commands.Create(&collection)
 	if err != nil {
-		http.Error(w, "Failed to persist collection", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, created.Version)
 	w.WriteHeader(http.StatusCreated)
 	httpx.WriteJSON(w, toCollectionDTO(created))
 }
@@ -61,42 +230,198 @@ func (h *HTTPHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	var payload collectionDTO
 	if err := httpx.ReadJSON(r, &payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	collection := fromCollectionDTO(payload)
+	collection.ID = id
+	collection.Version = version
+	updated, err := h.commands.Update(&collection)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, updated.Version)
+	httpx.WriteJSON(w, toCollectionDTO(updated))
+}
+
+// Patch applies a JSON merge patch to the collection, so fields the patch
+// leaves out keep their values.
+func (h *HTTPHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	current, _, err := h.queries.Get(id, Expand{})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	payload := toCollectionDTO(current)
+	if err := httpx.ReadMergePatch(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	collection := fromCollectionDTO(payload)
 	collection.ID = id
+	collection.Version = httpx.PatchVersion(version, current.Version)
 	updated, err := h.commands.Update(&collection)
 	if err != nil {
-		http.Error(w, "Failed to persist collection", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, updated.Version)
 	httpx.WriteJSON(w, toCollectionDTO(updated))
 }
 
+// AddProducts adds products to the collection, keeping the ones it holds.
+func (h *HTTPHandler) AddProducts(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.OptionalIfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	var payload productLinksDTO
+	if err := httpx.ReadJSON(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	collection, err := h.commands.AddProducts(id, version, payload.ProductIDs)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, collection.Version)
+	httpx.WriteJSON(w, toCollectionDTO(collection))
+}
+
+func (h *HTTPHandler) RemoveProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	productID, err := httpx.ParseLinkedID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.OptionalIfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	collection, err := h.commands.RemoveProduct(id, version, productID)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, collection.Version)
+	httpx.WriteJSON(w, toCollectionDTO(collection))
+}
+
+// Move places the collection under parentId, before or after one of its new
+// siblings.
+func (h *HTTPHandler) Move(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	var payload moveDTO
+	if err := httpx.ReadJSON(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	moved, err := h.commands.Move(Move{ID: id, ParentID: payload.ParentID, BeforeID: payload.BeforeID, AfterID: payload.AfterID, Version: version})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, moved.Version)
+	httpx.WriteJSON(w, toCollectionDTO(moved))
+}
+
+// Delete removes a collection as chosen by ?mode, rejecting collections with
+// children by default, and reports the shops whose navigation changed.
 func (h *HTTPHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
-	if err := h.commands.Delete(id); err != nil {
-		if errors.Is(err, ErrNotFound) {
-			http.Error(w, "Collection not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to persist collection", http.StatusInternalServerError)
+	mode := DeleteMode(r.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = DeleteReject
+	}
+	if !mode.Valid() {
+		httpx.WriteError(w, r, httpx.InvalidParam("mode", "must be reject, cascade or reparent"), errorMappings)
+		return
+	}
+
+	result, err := h.commands.Delete(id, DeleteOptions{Mode: mode, Version: version})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	httpx.WriteJSON(w, toDeleteResultDTO(result))
+}
+
+// currentVersion reads the collection's version for an If-Match header listing
+// several entity tags.
+func (h *HTTPHandler) currentVersion(id int) func() (int, error) {
+	return func() (int, error) {
+		c, _, err := h.queries.Get(id, Expand{})
+		if err != nil {
+			return 0, err
+		}
+		return c.Version, nil
+	}
 }
@@ -0,0 +1,336 @@
+package collections
+
+import (
+	"sort"
+
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/tree"
+)
+
+type MemoryRepository struct {
+	store *memory.Store
+}
+
+func NewMemoryRepository(store *memory.Store) *MemoryRepository {
+	return &MemoryRepository{store: store}
+}
+
+func (r *MemoryRepository) GetCollections() []*Collection {
+	var items []*Collection
+	r.store.Read(func(t *memory.Tables) {
+		items = make([]*Collection, 0, len(t.Collections))
+		for _, row := range t.Collections {
+			items = append(items, toCollection(row))
+		}
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func (r *MemoryRepository) GetCollectionsAfter(after, limit int) []*Collection {
+	return memory.PageAfter(r.GetCollections(), func(c *Collection) int { return c.ID }, after, limit)
+}
+
+func (r *MemoryRepository) GetCollection(id int) (*Collection, error) {
+	var collection *Collection
+	r.store.Read(func(t *memory.Tables) {
+		if row, ok := t.Collections[id]; ok {
+			collection = toCollection(row)
+		}
+	})
+	if collection == nil {
+		return nil, ErrNotFound
+	}
+	return collection, nil
+}
+
+func (r *MemoryRepository) GetCollectionRelations(id int, expand Expand) (*Relations, error) {
+	relations := &Relations{}
+	var err error
+	r.store.Read(func(t *memory.Tables) {
+		if _, ok := t.Collections[id]; !ok {
+			err = ErrNotFound
+			return
+		}
+		if expand.Collections {
+			relations.Collections = make([]Ref, 0)
+			for _, childID := range memory.SiblingIDs(t.Collections, collectionPlace, &id) {
+				relations.Collections = append(relations.Collections, Ref{ID: childID, Name: t.Collections[childID].Name})
+			}
+		}
+		if expand.Shops {
+			ancestors := []int{id}
+			for parent := t.Collections[id].ParentID; parent != nil; parent = t.Collections[*parent].ParentID {
+				ancestors = append(ancestors, *parent)
+			}
+			relations.Shops = make([]Ref, 0)
+			for _, s := range t.Shops {
+				exposed := len(s.CollectionIDs) == 0
+				for _, collectionID := range s.CollectionIDs {
+					if memory.ContainsInt(ancestors, collectionID) {
+						exposed = true
+						break
+					}
+				}
+				if exposed {
+					relations.Shops = append(relations.Shops, Ref{ID: s.ID, Name: s.Name})
+				}
+			}
+			sortRefs(relations.Shops)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
+
+func sortRefs(refs []Ref) {
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ID < refs[j].ID })
+}
+
+func (r *MemoryRepository) CreateCollection(c *Collection, placement tree.Placement) (*Collection, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		if err := validateCollection(t, c); err != nil {
+			return err
+		}
+		if err := placement.Check(memory.Parents(t.Collections, collectionPlace), 0, c.ParentID); err != nil {
+			return err
+		}
+		c.ID = t.NextID("collections")
+		c.Position = memory.NextPosition(t.Collections, collectionPlace, c.ParentID)
+		c.Version = 1
+		t.Collections[c.ID] = fromCollection(c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *MemoryRepository) UpdateCollection(c *Collection, placement tree.Placement) (*Collection, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		current, ok := t.Collections[c.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(current.Version, c.Version) {
+			return ErrVersionMismatch
+		}
+		if err := validateCollection(t, c); err != nil {
+			return err
+		}
+		if err := placement.Check(memory.Parents(t.Collections, collectionPlace), c.ID, c.ParentID); err != nil {
+			return err
+		}
+		c.Position = current.Position
+		if !tree.SameParent(current.ParentID, c.ParentID) {
+			c.Position = memory.NextPosition(t.Collections, collectionPlace, c.ParentID)
+		}
+		c.Version = current.Version + 1
+		t.Collections[c.ID] = fromCollection(c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *MemoryRepository) MoveCollection(m Move, placement tree.Placement) (*Collection, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Collections[m.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, m.Version) {
+			return ErrVersionMismatch
+		}
+		if m.ParentID != nil {
+			if _, ok := t.Collections[*m.ParentID]; !ok {
+				return ErrInvalidParent
+			}
+		}
+		if err := placement.Check(memory.Parents(t.Collections, collectionPlace), m.ID, m.ParentID); err != nil {
+			return err
+		}
+		ordered, ok := tree.Place(memory.SiblingIDs(t.Collections, collectionPlace, m.ParentID), m.ID, m.BeforeID, m.AfterID)
+		if !ok {
+			return ErrInvalidSibling
+		}
+		row.ParentID = memory.CopyIntPtr(m.ParentID)
+		row.Version++
+		for position, id := range ordered {
+			t.Collections[id].Position = position
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCollection(m.ID)
+}
+
+func (r *MemoryRepository) DeleteCollection(id int, opts DeleteOptions) (*DeleteResult, error) {
+	result := newDeleteResult(opts.Mode)
+	err := r.store.Write(func(t *memory.Tables) error {
+		target, ok := t.Collections[id]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(target.Version, opts.Version) {
+			return ErrVersionMismatch
+		}
+		children := memory.SiblingIDs(t.Collections, collectionPlace, &id)
+
+		deleted := []int{id}
+		switch opts.Mode {
+		case DeleteCascade:
+			parents := make(tree.Parents, len(t.Collections))
+			for collectionID, row := range t.Collections {
+				parents[collectionID] = row.ParentID
+			}
+			deleted = append(deleted, parents.Descendants(id)...)
+		case DeleteReparent:
+			siblings := memory.SiblingIDs(t.Collections, collectionPlace, target.ParentID)
+			for _, childID := range children {
+				t.Collections[childID].ParentID = memory.CopyIntPtr(target.ParentID)
+				t.Collections[childID].Version++
+			}
+			for position, siblingID := range tree.Promote(siblings, id, children) {
+				t.Collections[siblingID].Position = position
+			}
+			result.ReparentedCollectionIDs = children
+		default:
+			if len(children) > 0 {
+				return ErrHasChildren
+			}
+		}
+
+		for _, s := range t.Shops {
+			linked := false
+			for _, collectionID := range deleted {
+				if memory.ContainsInt(s.CollectionIDs, collectionID) {
+					linked = true
+					s.CollectionIDs = memory.RemoveInt(s.CollectionIDs, collectionID)
+				}
+			}
+			if linked {
+				result.AffectedShops = append(result.AffectedShops, Ref{ID: s.ID, Name: s.Name})
+				if len(s.CollectionIDs) == 0 {
+					result.ShopsNowExposingAll = append(result.ShopsNowExposingAll, Ref{ID: s.ID, Name: s.Name})
+				}
+			}
+		}
+		sortRefs(result.AffectedShops)
+		sortRefs(result.ShopsNowExposingAll)
+		for _, collectionID := range deleted {
+			delete(t.Collections, collectionID)
+		}
+		result.DeletedCollectionIDs = deleted
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *MemoryRepository) UpdateCollectionProducts(links ProductLinks) (*Collection, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Collections[links.CollectionID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, links.Version) {
+			return ErrVersionMismatch
+		}
+		for _, productID := range links.Add {
+			if _, ok := t.Products[productID]; !ok {
+				return ErrInvalidProduct
+			}
+		}
+		for _, productID := range links.Add {
+			if !memory.ContainsInt(row.ProductIDs, productID) {
+				row.ProductIDs = append(row.ProductIDs, productID)
+			}
+		}
+		for _, productID := range links.Remove {
+			row.ProductIDs = memory.RemoveInt(row.ProductIDs, productID)
+		}
+		row.Version++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCollection(links.CollectionID)
+}
+
+func (r *MemoryRepository) MissingCollectionIDs(ids []int) ([]int, error) {
+	var missing []int
+	r.store.Read(func(t *memory.Tables) {
+		missing = memory.MissingIDs(t.Collections, ids)
+	})
+	return missing, nil
+}
+
+func (r *MemoryRepository) GetCollectionParents() (tree.Parents, error) {
+	var parents tree.Parents
+	r.store.Read(func(t *memory.Tables) {
+		parents = memory.Parents(t.Collections, collectionPlace)
+	})
+	return parents, nil
+}
+
+func (r *MemoryRepository) MissingProductIDs(ids []int) ([]int, error) {
+	var missing []int
+	r.store.Read(func(t *memory.Tables) {
+		missing = memory.MissingIDs(t.Products, ids)
+	})
+	return missing, nil
+}
+
+func validateCollection(t *memory.Tables, c *Collection) error {
+	if c.ParentID != nil {
+		if _, ok := t.Collections[*c.ParentID]; !ok {
+			return ErrInvalidParent
+		}
+	}
+	if memory.HasDuplicates(c.ProductIDs) {
+		return ErrDuplicateProduct
+	}
+	for _, pid := range c.ProductIDs {
+		if _, ok := t.Products[pid]; !ok {
+			return ErrInvalidProduct
+		}
+	}
+	return nil
+}
+
+func toCollection(row *memory.CollectionRow) *Collection {
+	return &Collection{
+		ID:         row.ID,
+		Name:       row.Name,
+		ParentID:   memory.CopyIntPtr(row.ParentID),
+		Position:   row.Position,
+		ProductIDs: memory.SortedInts(row.ProductIDs),
+		Version:    row.Version,
+	}
+}
+
+func fromCollection(c *Collection) *memory.CollectionRow {
+	return &memory.CollectionRow{
+		ID:         c.ID,
+		Name:       c.Name,
+		ParentID:   memory.CopyIntPtr(c.ParentID),
+		Position:   c.Position,
+		ProductIDs: append([]int{}, c.ProductIDs...),
+		Version:    c.Version,
+	}
+}
+
+func collectionPlace(row *memory.CollectionRow) (*int, int) {
+	return row.ParentID, row.Position
+}
@@ -1,8 +1,114 @@
 package collections
 
+import "categories-test/internal/platform/tree"
+
+// Collection is a node of the collection tree. Position orders it among its
+// siblings and is only changed by moving the collection. Version counts its
+// updates; when updating, it holds the version the caller read, or zero to
+// skip the check.
 type Collection struct {
 	ID         int
 	Name       string
 	ParentID   *int
+	Position   int
 	ProductIDs []int
+	Version    int
+}
+
+// ProductLinks adds products to a collection and removes others from it,
+// leaving its remaining products alone. A non-zero Version must match the
+// collection's.
+type ProductLinks struct {
+	CollectionID int
+	Add          []int
+	Remove       []int
+	Version      int
+}
+
+// Move places a collection among its new siblings.
+type Move = tree.Move
+
+// DeleteMode decides what happens to the subcollections of a deleted
+// collection. Whatever the mode, deleted collections are detached from every
+// shop that linked them.
+type DeleteMode string
+
+const (
+	// DeleteReject refuses to delete a collection that has children.
+	DeleteReject DeleteMode = "reject"
+	// DeleteCascade deletes the collection along with its descendants.
+	DeleteCascade DeleteMode = "cascade"
+	// DeleteReparent deletes only the collection and puts its children in its
+	// place.
+	DeleteReparent DeleteMode = "reparent"
+)
+
+func (m DeleteMode) Valid() bool {
+	switch m {
+	case DeleteReject, DeleteCascade, DeleteReparent:
+		return true
+	}
+	return false
+}
+
+// DeleteOptions configure a delete. The zero value rejects collections with
+// children. A non-zero Version must match the collection's.
+type DeleteOptions struct {
+	Mode    DeleteMode
+	Version int
+}
+
+// DeleteResult reports what a delete changed. AffectedShops are the shops
+// that linked a deleted collection. ShopsNowExposingAll are those of them
+// left with no collection, which makes them expose every product.
+type DeleteResult struct {
+	Mode                    DeleteMode
+	DeletedCollectionIDs    []int
+	ReparentedCollectionIDs []int
+	AffectedShops           []Ref
+	ShopsNowExposingAll     []Ref
+}
+
+func newDeleteResult(mode DeleteMode) *DeleteResult {
+	if mode == "" {
+		mode = DeleteReject
+	}
+	return &DeleteResult{
+		Mode:                    mode,
+		DeletedCollectionIDs:    []int{},
+		ReparentedCollectionIDs: []int{},
+		AffectedShops:           []Ref{},
+		ShopsNowExposingAll:     []Ref{},
+	}
+}
+
+// Expand selects the relations embedded in a single collection. Collections
+// embeds its direct subcollections.
+type Expand struct {
+	Collections bool
+	Shops       bool
+}
+
+// Relations are the expanded relations of a collection. Relations that were
+// not requested are nil.
+type Relations struct {
+	Collections []Ref
+	Shops       []Ref
+}
+
+// TreeNode places a collection in the hierarchy. Path lists the collection IDs
+// from the root down to the collection itself. ProductCount counts only the
+// collection's own products, as shops do not expose those of subcollections.
+type TreeNode struct {
+	Collection   *Collection
+	Depth        int
+	Path         []int
+	ProductCount int
+	Children     []*TreeNode
+}
+
+// Ref names a related resource.
+type Ref struct {
+	ID   int
+	Name string
 }
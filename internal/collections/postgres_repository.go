@@ -0,0 +1,12 @@
+package collections
+
+import "categories-test/internal/platform/db"
+
+// PostgresRepository stores collections in PostgreSQL.
+type PostgresRepository struct {
+	SQLRepository
+}
+
+func NewPostgresRepository(client *db.Client) *PostgresRepository {
+	return &PostgresRepository{SQLRepository{db: client}}
+}
@@ -1,5 +1,12 @@
 package collections
 
+import (
+	"cmp"
+	"slices"
+
+	"categories-test/internal/platform/tree"
+)
+
 type Queries struct {
 	repo QueryRepository
 }
@@ -11,3 +18,83 @@ func NewQueries(repo QueryRepository) *Queries {
 func (q *Queries) List() []*Collection {
 	return q.repo.GetCollections()
 }
+
+// ListAfter returns up to limit collections following the given ID and
+// whether more collections follow them.
+func (q *Queries) ListAfter(after, limit int) ([]*Collection, bool) {
+	items := q.repo.GetCollectionsAfter(after, limit+1)
+	if len(items) > limit {
+		return items[:limit], true
+	}
+	return items, false
+}
+
+// Get returns a collection with the requested relations.
+func (q *Queries) Get(id int, expand Expand) (*Collection, *Relations, error) {
+	collection, err := q.repo.GetCollection(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	relations, err := q.repo.GetCollectionRelations(id, expand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return collection, relations, nil
+}
+
+// Tree returns the collection hierarchy, or only the subtree of rootID when it
+// is set. Siblings are ordered by position. Collections caught in a parent
+// cycle are left out of the full hierarchy; a subtree lists each collection
+// once, so one rooted in a cycle stops where the cycle closes.
+func (q *Queries) Tree(rootID *int) ([]*TreeNode, error) {
+	collections := q.repo.GetCollections()
+	slices.SortStableFunc(collections, func(a, b *Collection) int { return cmp.Compare(a.Position, b.Position) })
+
+	parents := make(tree.Parents, len(collections))
+	for _, c := range collections {
+		parents[c.ID] = c.ParentID
+	}
+
+	nodes := make(map[int]*TreeNode, len(collections))
+	children := make(map[int][]*TreeNode)
+	roots := make([]*TreeNode, 0)
+	for _, c := range collections {
+		path := parents.Path(c.ID)
+		node := &TreeNode{Collection: c, Depth: len(path), Path: path, ProductCount: len(c.ProductIDs)}
+		nodes[c.ID] = node
+		if c.ParentID == nil {
+			roots = append(roots, node)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], node)
+		}
+	}
+
+	result := roots
+	if rootID != nil {
+		node, ok := nodes[*rootID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		result = []*TreeNode{node}
+	}
+
+	// Link children breadth-first from the result, attaching each node once,
+	// so it stays a tree even when the data holds a cycle.
+	linked := make(map[int]bool, len(nodes))
+	for _, node := range result {
+		linked[node.Collection.ID] = true
+	}
+	queue := append([]*TreeNode{}, result...)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, child := range children[node.Collection.ID] {
+			if !linked[child.Collection.ID] {
+				linked[child.Collection.ID] = true
+				node.Children = append(node.Children, child)
+			}
+		}
+		queue = append(queue, node.Children...)
+	}
+	return result, nil
+}
@@ -1,11 +1,27 @@
 package collections
 
+import "categories-test/internal/platform/tree"
+
 type CommandRepository interface {
-	CreateCollection(c *Collection) (*Collection, error)
-	UpdateCollection(c *Collection) (*Collection, error)
-	DeleteCollection(id int) error
+	// CreateCollection, UpdateCollection and MoveCollection check placement
+	// in the transaction that writes the collection.
+	CreateCollection(c *Collection, placement tree.Placement) (*Collection, error)
+	UpdateCollection(c *Collection, placement tree.Placement) (*Collection, error)
+	MoveCollection(m Move, placement tree.Placement) (*Collection, error)
+	DeleteCollection(id int, opts DeleteOptions) (*DeleteResult, error)
+	// UpdateCollectionProducts applies the link changes and returns the
+	// collection. Adding a product twice or removing one that is not in the
+	// collection changes nothing.
+	UpdateCollectionProducts(links ProductLinks) (*Collection, error)
+	MissingCollectionIDs(ids []int) ([]int, error)
+	MissingProductIDs(ids []int) ([]int, error)
 }
 
 type QueryRepository interface {
 	GetCollections() []*Collection
+	GetCollectionsAfter(after, limit int) []*Collection
+	GetCollection(id int) (*Collection, error)
+	// GetCollectionRelations lists the shops exposing the collection, either
+	// directly or through an ancestor.
+	GetCollectionRelations(id int, expand Expand) (*Relations, error)
 }
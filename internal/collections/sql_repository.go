@@ -0,0 +1,396 @@
+package collections
+
+import (
+	"context"
+	"database/sql"
+
+	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/tree"
+)
+
+var versionErrors = db.VersionErrors{NotFound: ErrNotFound, Mismatch: ErrVersionMismatch}
+
+type SQLRepository struct {
+	db *db.Client
+}
+
+func (r *SQLRepository) GetCollections() []*Collection {
+	return r.queryCollections(`SELECT id FROM collections`)
+}
+
+func (r *SQLRepository) GetCollectionsAfter(after, limit int) []*Collection {
+	return r.queryCollections(`SELECT id FROM collections WHERE id > ? ORDER BY id LIMIT ?`, after, limit)
+}
+
+func (r *SQLRepository) GetCollection(id int) (*Collection, error) {
+	c := &Collection{}
+	var parentID sql.NullInt64
+	err := r.db.QueryRow(`SELECT id, name, parent_id, position, version FROM collections WHERE id = ?;`, id).
+		Scan(&c.ID, &c.Name, &parentID, &c.Position, &c.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.ParentID = db.IntPtr(parentID)
+
+	productsByCollection, err := r.getCollectionProductMap(`SELECT id FROM collections WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	c.ProductIDs = productsByCollection[c.ID]
+	return c, nil
+}
+
+// GetCollectionRelations follows the shop rule used for suggestions: a shop
+// exposes its collections and their descendants, or every collection when it
+// has none.
+func (r *SQLRepository) GetCollectionRelations(id int, expand Expand) (*Relations, error) {
+	relations := &Relations{}
+	var err error
+	if expand.Collections {
+		relations.Collections, err = r.queryRefs(`SELECT id, name FROM collections WHERE parent_id = ? ORDER BY position, id;`, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if expand.Shops {
+		relations.Shops, err = r.queryRefs(
+			`WITH RECURSIVE ancestors(id) AS (
+				SELECT CAST(? AS INTEGER)
+				UNION
+				SELECT c.parent_id FROM collections c JOIN ancestors a ON c.id = a.id WHERE c.parent_id IS NOT NULL
+			)
+			SELECT s.id, s.name FROM shops s
+			WHERE NOT EXISTS (SELECT 1 FROM shop_collections sc WHERE sc.shop_id = s.id)
+				OR EXISTS (
+					SELECT 1 FROM shop_collections sc JOIN ancestors a ON a.id = sc.collection_id
+					WHERE sc.shop_id = s.id
+				)
+			ORDER BY s.id;`, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return relations, nil
+}
+
+func (r *SQLRepository) queryRefs(query string, args ...any) ([]Ref, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make([]Ref, 0)
+	for rows.Next() {
+		var ref Ref
+		if err := rows.Scan(&ref.ID, &ref.Name); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// queryCollections loads the collections whose IDs are selected by ids, along
+// with their product links.
+func (r *SQLRepository) queryCollections(ids string, args ...any) []*Collection {
+	productsByCollection, err := r.getCollectionProductMap(ids, args...)
+	if err != nil {
+		productsByCollection = map[int][]int{}
+	}
+
+	rows, err := r.db.Query(`SELECT id, name, parent_id, position, version FROM collections WHERE id IN (`+ids+`) ORDER BY id;`, args...)
+	if err != nil {
+		return []*Collection{}
+	}
+	defer rows.Close()
+
+	items := make([]*Collection, 0)
+	for rows.Next() {
+		c := &Collection{}
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Name, &parentID, &c.Position, &c.Version); err != nil {
+			return []*Collection{}
+		}
+		c.ParentID = db.IntPtr(parentID)
+		c.ProductIDs = productsByCollection[c.ID]
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		return []*Collection{}
+	}
+	return items
+}
+
+// CreateCollection places the collection after its last sibling.
+func (r *SQLRepository) CreateCollection(c *Collection, placement tree.Placement) (*Collection, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := tx.CheckPlacement("collections", placement, 0, c.ParentID); err != nil {
+			return err
+		}
+		position, err := tx.NextPosition("collections", c.ParentID)
+		if err != nil {
+			return err
+		}
+		c.Position = position
+		err = tx.QueryRow(
+			`INSERT INTO collections(name, parent_id, position) VALUES (?, ?, ?) RETURNING id, version;`,
+			c.Name, db.NullableInt(c.ParentID), c.Position,
+		).Scan(&c.ID, &c.Version)
+		if err != nil {
+			if db.IsForeignKeyViolation(err) {
+				return ErrInvalidParent
+			}
+			return err
+		}
+		return insertCollectionProducts(tx, c.ID, c.ProductIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UpdateCollection keeps the collection's position, unless it changes parent
+// and goes after its new siblings.
+func (r *SQLRepository) UpdateCollection(c *Collection, placement tree.Placement) (*Collection, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := tx.CheckPlacement("collections", placement, c.ID, c.ParentID); err != nil {
+			return err
+		}
+		version, err := tx.BumpVersion("collections", c.ID, c.Version, versionErrors)
+		if err != nil {
+			return err
+		}
+		current, err := (&SQLRepository{db: tx}).GetCollection(c.ID)
+		if err != nil {
+			return err
+		}
+		c.Version = version
+		c.Position = current.Position
+		if !tree.SameParent(current.ParentID, c.ParentID) {
+			if c.Position, err = tx.NextPosition("collections", c.ParentID); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(
+			`UPDATE collections SET name = ?, parent_id = ?, position = ? WHERE id = ?;`,
+			c.Name, db.NullableInt(c.ParentID), c.Position, c.ID,
+		); err != nil {
+			if db.IsForeignKeyViolation(err) {
+				return ErrInvalidParent
+			}
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM collection_products WHERE collection_id = ?;`, c.ID); err != nil {
+			return err
+		}
+		return insertCollectionProducts(tx, c.ID, c.ProductIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// MoveCollection reparents the collection and renumbers its new siblings in
+// one transaction.
+func (r *SQLRepository) MoveCollection(m Move, placement tree.Placement) (*Collection, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := tx.CheckPlacement("collections", placement, m.ID, m.ParentID); err != nil {
+			return err
+		}
+		if _, err := tx.BumpVersion("collections", m.ID, m.Version, versionErrors); err != nil {
+			return err
+		}
+		siblings, err := tx.SiblingIDs("collections", m.ParentID)
+		if err != nil {
+			return err
+		}
+		ordered, ok := tree.Place(siblings, m.ID, m.BeforeID, m.AfterID)
+		if !ok {
+			return ErrInvalidSibling
+		}
+		if _, err := tx.Exec(`UPDATE collections SET parent_id = ? WHERE id = ?;`, db.NullableInt(m.ParentID), m.ID); err != nil {
+			if db.IsForeignKeyViolation(err) {
+				return ErrInvalidParent
+			}
+			return err
+		}
+		return tx.SetPositions("collections", ordered)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCollection(m.ID)
+}
+
+// DeleteCollection applies the delete mode and detaches the deleted
+// collections from their shops in a single transaction.
+func (r *SQLRepository) DeleteCollection(id int, opts DeleteOptions) (*DeleteResult, error) {
+	result := newDeleteResult(opts.Mode)
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if _, err := tx.BumpVersion("collections", id, opts.Version, versionErrors); err != nil {
+			return err
+		}
+		repo := &SQLRepository{db: tx}
+		target, err := repo.GetCollection(id)
+		if err != nil {
+			return err
+		}
+		children, err := tx.SiblingIDs("collections", &id)
+		if err != nil {
+			return err
+		}
+
+		deleted := []int{id}
+		switch opts.Mode {
+		case DeleteCascade:
+			parents, err := repo.GetCollectionParents()
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, parents.Descendants(id)...)
+		case DeleteReparent:
+			siblings, err := tx.SiblingIDs("collections", target.ParentID)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE collections SET parent_id = ?, version = version + 1 WHERE parent_id = ?;`, db.NullableInt(target.ParentID), id); err != nil {
+				return err
+			}
+			if err := tx.SetPositions("collections", tree.Promote(siblings, id, children)); err != nil {
+				return err
+			}
+			result.ReparentedCollectionIDs = children
+		default:
+			if len(children) > 0 {
+				return ErrHasChildren
+			}
+		}
+
+		in, args := db.Placeholders(deleted)
+		if result.AffectedShops, err = repo.queryRefs(
+			`SELECT DISTINCT s.id, s.name FROM shops s
+			JOIN shop_collections sc ON sc.shop_id = s.id
+			WHERE sc.collection_id IN (`+in+`)
+			ORDER BY s.id;`, args...); err != nil {
+			return err
+		}
+		if _, err := tx.ExecDynamic(`DELETE FROM shop_collections WHERE collection_id IN (`+in+`);`, args...); err != nil {
+			return err
+		}
+		if len(result.AffectedShops) > 0 {
+			shopIDs := make([]int, 0, len(result.AffectedShops))
+			for _, shop := range result.AffectedShops {
+				shopIDs = append(shopIDs, shop.ID)
+			}
+			in, args := db.Placeholders(shopIDs)
+			if result.ShopsNowExposingAll, err = repo.queryRefs(
+				`SELECT s.id, s.name FROM shops s
+				WHERE s.id IN (`+in+`)
+				AND NOT EXISTS (SELECT 1 FROM shop_collections sc WHERE sc.shop_id = s.id)
+				ORDER BY s.id;`, args...); err != nil {
+				return err
+			}
+		}
+		// Children reference their parent, so delete the deepest collections
+		// first.
+		for i := len(deleted) - 1; i >= 0; i-- {
+			if _, err := tx.Exec(`DELETE FROM collections WHERE id = ?;`, deleted[i]); err != nil {
+				return err
+			}
+		}
+		result.DeletedCollectionIDs = deleted
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *SQLRepository) UpdateCollectionProducts(links ProductLinks) (*Collection, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if _, err := tx.BumpVersion("collections", links.CollectionID, links.Version, versionErrors); err != nil {
+			return err
+		}
+		for _, productID := range links.Add {
+			if _, err := tx.Exec(
+				`INSERT INTO collection_products(collection_id, product_id) VALUES (?, ?) ON CONFLICT DO NOTHING;`,
+				links.CollectionID, productID,
+			); err != nil {
+				if db.IsForeignKeyViolation(err) {
+					return ErrInvalidProduct
+				}
+				return err
+			}
+		}
+		if len(links.Remove) == 0 {
+			return nil
+		}
+		in, args := db.Placeholders(links.Remove)
+		_, err := tx.ExecDynamic(
+			`DELETE FROM collection_products WHERE collection_id = ? AND product_id IN (`+in+`);`,
+			append([]any{links.CollectionID}, args...)...,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCollection(links.CollectionID)
+}
+
+func (r *SQLRepository) MissingCollectionIDs(ids []int) ([]int, error) {
+	return r.db.MissingIDs("collections", ids)
+}
+
+func (r *SQLRepository) GetCollectionParents() (tree.Parents, error) {
+	return r.db.Parents("collections")
+}
+
+func (r *SQLRepository) MissingProductIDs(ids []int) ([]int, error) {
+	return r.db.MissingIDs("products", ids)
+}
+
+func insertCollectionProducts(tx *db.Client, collectionID int, productIDs []int) error {
+	for _, pid := range productIDs {
+		if _, err := tx.Exec(`INSERT INTO collection_products(collection_id, product_id) VALUES (?, ?);`, collectionID, pid); err != nil {
+			if db.IsForeignKeyViolation(err) {
+				return ErrInvalidProduct
+			}
+			if db.IsUniqueViolation(err) {
+				return ErrDuplicateProduct
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLRepository) getCollectionProductMap(ids string, args ...any) (map[int][]int, error) {
+	rows, err := r.db.Query(
+		`SELECT collection_id, product_id FROM collection_products
+		WHERE collection_id IN (`+ids+`)
+		ORDER BY collection_id, product_id;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[int][]int)
+	for rows.Next() {
+		var cid, pid int
+		if err := rows.Scan(&cid, &pid); err != nil {
+			return nil, err
+		}
+		m[cid] = append(m[cid], pid)
+	}
+	return m, rows.Err()
+}
@@ -1,132 +1,311 @@
+// Package db runs the repositories' SQL on SQLite or PostgreSQL. Each
+// repository package writes its queries once, on an SQLRepository that its
+// SQLiteRepository and PostgresRepository embed: Client rewrites ?
+// placeholders for PostgreSQL and the Is* helpers recognise either driver's
+// errors, so only queries needing dialect-specific SQL, such as full-text
+// search, are written per dialect.
 package db
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"os/exec"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+type Dialect int
+
+const (
+	DialectSQLite Dialect = iota
+	DialectPostgres
 )
 
 type Client struct {
-	path string
-	mu   sync.Mutex
+	db      *sql.DB
+	tx      *sql.Tx
+	dialect Dialect
+	stmts   *sync.Map
 }
 
 func OpenSQLite(path string) (*Client, error) {
-	c := &Client{path: path}
-	if err := c.Exec("SELECT 1;"); err != nil {
+	c, err := ConnectSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	return migrated(c)
+}
+
+// ConnectSQLite opens the database without touching its schema.
+func ConnectSQLite(path string) (*Client, error) {
+	sqlDB, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
 		return nil, fmt.Errorf("open sqlite at %s: %w", path, err)
 	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("open sqlite at %s: %w", path, err)
+	}
+	return newClient(sqlDB, DialectSQLite), nil
+}
+
+// ConnectFromEnv opens the database the server would use without touching its
+// schema: STORAGE_BACKEND selects sqlite (the default, at SQLITE_PATH) or
+// postgres (at DATABASE_URL).
+func ConnectFromEnv() (*Client, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "categories.db"
+		}
+		return ConnectSQLite(path)
+	case "postgres":
+		return ConnectPostgres(os.Getenv("DATABASE_URL"))
+	default:
+		return nil, fmt.Errorf("storage backend %q has no database", backend)
+	}
+}
+
+func newClient(sqlDB *sql.DB, dialect Dialect) *Client {
+	return &Client{db: sqlDB, dialect: dialect, stmts: &sync.Map{}}
+}
+
+func migrated(c *Client) (*Client, error) {
 	if err := ApplyMigrations(c); err != nil {
+		c.Close()
 		return nil, err
 	}
 	return c, nil
 }
 
-func (c *Client) Exec(sql string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func sqliteDSN(path string) string {
+	return path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+}
+
+func (c *Client) Dialect() Dialect {
+	return c.dialect
+}
+
+func (c *Client) Close() error {
+	if c.tx != nil {
+		return errors.New("close called on a transaction client")
+	}
+	c.stmts.Range(func(_, value any) bool {
+		value.(*sql.Stmt).Close()
+		return true
+	})
+	return c.db.Close()
+}
 
-	cmd := exec.Command("sqlite3", c.path, sql)
-	out, err := cmd.CombinedOutput()
+// Exec, Query and QueryRow run fixed SQL. Outside transactions its statements
+// are prepared once and cached for the life of the client; a transaction
+// would prepare them again on its own connection, so it runs them directly.
+func (c *Client) Exec(query string, args ...any) (sql.Result, error) {
+	if c.tx != nil {
+		return c.tx.Exec(c.rebind(query), args...)
+	}
+	stmt, err := c.prepare(query)
 	if err != nil {
-		msg := strings.TrimSpace(string(out))
-		if msg == "" {
-			msg = err.Error()
-		}
-		return fmt.Errorf("sqlite exec failed: %s", msg)
+		return nil, err
 	}
-	return nil
+	return stmt.Exec(args...)
 }
 
-func (c *Client) Query(sql string) ([]map[string]interface{}, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Client) Query(query string, args ...any) (*sql.Rows, error) {
+	if c.tx != nil {
+		return c.tx.Query(c.rebind(query), args...)
+	}
+	stmt, err := c.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(args...)
+}
 
-	cmd := exec.Command("sqlite3", "-json", c.path, sql)
-	out, err := cmd.CombinedOutput()
+func (c *Client) QueryRow(query string, args ...any) *sql.Row {
+	if c.tx != nil {
+		return c.tx.QueryRow(c.rebind(query), args...)
+	}
+	stmt, err := c.prepare(query)
 	if err != nil {
-		msg := strings.TrimSpace(string(out))
-		if msg == "" {
-			msg = err.Error()
-		}
-		return nil, fmt.Errorf("sqlite query failed: %s", msg)
+		// Let database/sql surface the prepare error through Row.Scan.
+		return c.db.QueryRow(c.rebind(query), args...)
+	}
+	return stmt.QueryRow(args...)
+}
+
+// ExecDynamic, QueryDynamic and QueryRowDynamic run SQL built at run time,
+// such as IN lists over a varying number of IDs, without preparing it, so
+// that each variant does not add a statement to the cache.
+func (c *Client) ExecDynamic(query string, args ...any) (sql.Result, error) {
+	if c.tx != nil {
+		return c.tx.Exec(c.rebind(query), args...)
 	}
+	return c.db.Exec(c.rebind(query), args...)
+}
 
-	trimmed := strings.TrimSpace(string(out))
-	if trimmed == "" {
-		return []map[string]interface{}{}, nil
+func (c *Client) QueryDynamic(query string, args ...any) (*sql.Rows, error) {
+	if c.tx != nil {
+		return c.tx.Query(c.rebind(query), args...)
 	}
+	return c.db.Query(c.rebind(query), args...)
+}
 
-	var rows []map[string]interface{}
-	if err := json.Unmarshal(out, &rows); err != nil {
-		return nil, fmt.Errorf("decode sqlite json: %w", err)
+func (c *Client) QueryRowDynamic(query string, args ...any) *sql.Row {
+	if c.tx != nil {
+		return c.tx.QueryRow(c.rebind(query), args...)
 	}
-	return rows, nil
+	return c.db.QueryRow(c.rebind(query), args...)
 }
 
-func QuoteString(s string) string {
-	escaped := strings.ReplaceAll(s, "'", "''")
-	return "'" + escaped + "'"
+// WithTx runs fn inside a transaction and commits it if fn returns nil.
+// Repositories constructed from the tx client passed to fn share the
+// transaction, and calling WithTx on a tx client joins the outer transaction
+// instead of starting a new one.
+func (c *Client) WithTx(ctx context.Context, fn func(tx *Client) error) error {
+	return c.withTx(ctx, nil, fn)
 }
 
-func NullableInt(value *int) string {
-	if value == nil {
-		return "NULL"
+// WithReadTx is WithTx for a read-only transaction that sees the database at
+// a single point in time. SQLite begins it deferred rather than immediate, so
+// it takes no write lock and, in WAL mode, blocks no writer while it lasts.
+func (c *Client) WithReadTx(ctx context.Context, fn func(tx *Client) error) error {
+	return c.withTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, fn)
+}
+
+func (c *Client) withTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Client) error) (err error) {
+	if c.tx != nil {
+		return fn(c)
+	}
+
+	sqlTx, err := c.db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
 	}
-	return strconv.Itoa(*value)
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := sqlTx.Rollback(); rbErr != nil {
+				err = errors.Join(err, fmt.Errorf("rollback transaction: %w", rbErr))
+			}
+		}
+	}()
+
+	if err := fn(&Client{db: c.db, tx: sqlTx, dialect: c.dialect, stmts: c.stmts}); err != nil {
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
 }
 
-func IntFrom(row map[string]interface{}, key string) int {
-	v, ok := row[key]
-	if !ok || v == nil {
-		return 0
+func (c *Client) prepare(query string) (*sql.Stmt, error) {
+	if cached, ok := c.stmts.Load(query); ok {
+		return cached.(*sql.Stmt), nil
 	}
-	switch typed := v.(type) {
-	case float64:
-		return int(typed)
-	case int:
-		return typed
-	case string:
-		i, _ := strconv.Atoi(typed)
-		return i
-	default:
-		return 0
+	stmt, err := c.db.Prepare(c.rebind(query))
+	if err != nil {
+		return nil, fmt.Errorf("prepare statement: %w", err)
 	}
+	if cached, loaded := c.stmts.LoadOrStore(query, stmt); loaded {
+		stmt.Close()
+		return cached.(*sql.Stmt), nil
+	}
+	return stmt, nil
 }
 
-func FloatFrom(row map[string]interface{}, key string) float64 {
-	v, ok := row[key]
-	if !ok || v == nil {
-		return 0
+// rebind rewrites the ? placeholders used throughout the repositories into
+// the numbered form PostgreSQL expects. Placeholders inside quoted strings are
+// left alone.
+func (c *Client) rebind(query string) string {
+	if c.dialect != DialectPostgres || !strings.Contains(query, "?") {
+		return query
 	}
-	switch typed := v.(type) {
-	case float64:
-		return typed
-	case string:
-		f, _ := strconv.ParseFloat(typed, 64)
-		return f
-	default:
-		return 0
+	var sb strings.Builder
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
 	}
+	return sb.String()
 }
 
-func StringFrom(row map[string]interface{}, key string) string {
-	v, ok := row[key]
-	if !ok || v == nil {
-		return ""
+func NullableInt(value *int) any {
+	if value == nil {
+		return nil
 	}
-	s, _ := v.(string)
-	return s
+	return *value
 }
 
-func NullableIntFrom(row map[string]interface{}, key string) *int {
-	v, ok := row[key]
-	if !ok || v == nil {
+func IntPtr(value sql.NullInt64) *int {
+	if !value.Valid {
 		return nil
 	}
-	val := IntFrom(row, key)
-	return &val
+	v := int(value.Int64)
+	return &v
+}
+
+// MissingIDs returns the ids that have no row in table. table is interpolated
+// into the query and must not come from user input.
+func (c *Client) MissingIDs(table string, ids []int) ([]int, error) {
+	in, args := Placeholders(ids)
+	rows, err := c.QueryDynamic(`SELECT id FROM `+table+` WHERE id IN (`+in+`);`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[int]bool, len(ids))
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	missing := make([]int, 0)
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// Placeholders returns a placeholder list for an IN clause over ids, along
+// with the matching arguments.
+func Placeholders(ids []int) (string, []any) {
+	marks := make([]string, 0, len(ids))
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		marks = append(marks, "?")
+		args = append(args, id)
+	}
+	return strings.Join(marks, ", "), args
 }
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	client, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func countShops(t *testing.T, client *Client) int {
+	t.Helper()
+	var n int
+	if err := client.QueryRow(`SELECT COUNT(*) FROM shops;`).Scan(&n); err != nil {
+		t.Fatalf("count shops: %v", err)
+	}
+	return n
+}
+
+func TestWithTxCommits(t *testing.T) {
+	client := newTestClient(t)
+
+	err := client.WithTx(context.Background(), func(tx *Client) error {
+		_, err := tx.Exec(`INSERT INTO shops(name) VALUES (?);`, "committed")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if got := countShops(t, client); got != 1 {
+		t.Fatalf("shops = %d, want 1", got)
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	client := newTestClient(t)
+	errBoom := errors.New("boom")
+
+	err := client.WithTx(context.Background(), func(tx *Client) error {
+		if _, err := tx.Exec(`INSERT INTO shops(name) VALUES (?);`, "rolled back"); err != nil {
+			return err
+		}
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("WithTx error = %v, want %v", err, errBoom)
+	}
+	if got := countShops(t, client); got != 0 {
+		t.Fatalf("shops = %d, want 0", got)
+	}
+}
+
+func TestWithTxNestedJoinsOuterTransaction(t *testing.T) {
+	client := newTestClient(t)
+	errBoom := errors.New("boom")
+
+	err := client.WithTx(context.Background(), func(tx *Client) error {
+		err := tx.WithTx(context.Background(), func(inner *Client) error {
+			_, err := inner.Exec(`INSERT INTO shops(name) VALUES (?);`, "inner")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("WithTx error = %v, want %v", err, errBoom)
+	}
+	if got := countShops(t, client); got != 0 {
+		t.Fatalf("shops = %d, want 0", got)
+	}
+}
+
+func TestWithReadTxDoesNotBlockWriters(t *testing.T) {
+	client := newTestClient(t)
+
+	err := client.WithReadTx(context.Background(), func(tx *Client) error {
+		if got := countShops(t, tx); got != 0 {
+			t.Fatalf("shops = %d, want 0", got)
+		}
+		err := client.WithTx(context.Background(), func(writer *Client) error {
+			_, err := writer.Exec(`INSERT INTO shops(name) VALUES (?);`, "written meanwhile")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("WithTx during a read transaction: %v", err)
+		}
+		// The read transaction keeps seeing the point in time it started at.
+		if got := countShops(t, tx); got != 0 {
+			t.Errorf("shops in the read transaction = %d, want 0", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithReadTx: %v", err)
+	}
+	if got := countShops(t, client); got != 1 {
+		t.Fatalf("shops = %d, want 1", got)
+	}
+}
+
+func TestOnlyFixedQueriesAreCached(t *testing.T) {
+	client := newTestClient(t)
+	cached := func() int {
+		n := 0
+		client.stmts.Range(func(_, _ any) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	before := cached()
+
+	for _, ids := range [][]int{{1}, {1, 2}, {1, 2, 3}} {
+		if _, err := client.MissingIDs("shops", ids); err != nil {
+			t.Fatalf("MissingIDs: %v", err)
+		}
+	}
+	err := client.WithTx(context.Background(), func(tx *Client) error {
+		_, err := tx.Exec(`INSERT INTO shops(name) VALUES (?);`, "in a transaction")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if got := cached(); got != before {
+		t.Fatalf("cached statements = %d, want %d", got, before)
+	}
+
+	countShops(t, client)
+	countShops(t, client)
+	if got := cached(); got != before+1 {
+		t.Fatalf("cached statements = %d, want %d", got, before+1)
+	}
+}
+
+func TestRebindPostgres(t *testing.T) {
+	c := &Client{dialect: DialectPostgres}
+	got := c.rebind(`SELECT id FROM products WHERE name = ? AND description <> '?' AND price > ?;`)
+	want := `SELECT id FROM products WHERE name = $1 AND description <> '?' AND price > $2;`
+	if got != want {
+		t.Fatalf("rebind = %q, want %q", got, want)
+	}
+
+	c.dialect = DialectSQLite
+	if query := `SELECT ?;`; c.rebind(query) != query {
+		t.Fatalf("rebind changed a SQLite query: %q", c.rebind(query))
+	}
+}
@@ -1,60 +1,393 @@
 package db
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"io"
+	"path"
 	"sort"
+	"strings"
 )
 
-//go:embed migrations/*.sql
+//go:embed migrations/*.sql migrations_postgres/*.sql
 var migrationFiles embed.FS
 
+var (
+	ErrSchemaAhead      = errors.New("database schema is ahead of this binary")
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	ErrNoDownMigration  = errors.New("migration has no down script")
+)
+
+// Migration is a forward script and its optional inverse. The version is the
+// file name of the up script, which is what schema_migrations records.
+type Migration struct {
+	Version  string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type MigrationState string
+
+const (
+	MigrationApplied  MigrationState = "applied"
+	MigrationPending  MigrationState = "pending"
+	MigrationModified MigrationState = "modified"
+	MigrationUnknown  MigrationState = "unknown"
+)
+
+type MigrationStatus struct {
+	Version   string
+	State     MigrationState
+	AppliedAt string
+}
+
+type appliedMigration struct {
+	version   string
+	checksum  string
+	appliedAt string
+}
+
+type Migrator struct {
+	client     *Client
+	migrations []Migration
+	// DryRun makes Up and Down print the scripts they would run to Out
+	// instead of executing them.
+	DryRun bool
+	Out    io.Writer
+}
+
+func NewMigrator(client *Client) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationsDir(client.dialect))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{client: client, migrations: migrations, Out: io.Discard}, nil
+}
+
+// ApplyMigrations brings the schema up to date. It refuses to run when the
+// database has migrations this binary does not know about or when an applied
+// migration has been edited since.
 func ApplyMigrations(client *Client) error {
-	if err := client.Exec(`
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			version TEXT PRIMARY KEY,
-			applied_at TEXT NOT NULL DEFAULT (datetime('now'))
-		);
-	`); err != nil {
-		return fmt.Errorf("create migrations table: %w", err)
+	m, err := NewMigrator(client)
+	if err != nil {
+		return err
 	}
+	if err := m.Verify(); err != nil {
+		return err
+	}
+	_, err = m.Up()
+	return err
+}
 
-	entries, err := migrationFiles.ReadDir("migrations")
+func migrationsDir(dialect Dialect) string {
+	if dialect == DialectPostgres {
+		return "migrations_postgres"
+	}
+	return "migrations"
+}
+
+func loadMigrations(dir string) ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir(dir)
 	if err != nil {
-		return fmt.Errorf("read migrations: %w", err)
+		return nil, fmt.Errorf("read migrations: %w", err)
 	}
 
-	versions := make([]string, 0, len(entries))
+	byVersion := make(map[string]*Migration)
 	for _, entry := range entries {
-		if !entry.IsDir() {
-			versions = append(versions, entry.Name())
+		if entry.IsDir() {
+			continue
 		}
+		name := entry.Name()
+		contents, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", name, err)
+		}
+
+		if strings.HasSuffix(name, ".down.sql") {
+			version := strings.TrimSuffix(name, ".down.sql") + ".sql"
+			if byVersion[version] == nil {
+				byVersion[version] = &Migration{Version: version}
+			}
+			byVersion[version].Down = string(contents)
+			continue
+		}
+
+		if byVersion[name] == nil {
+			byVersion[name] = &Migration{Version: name}
+		}
+		byVersion[name].Up = string(contents)
+		byVersion[name].Checksum = checksum(contents)
 	}
-	sort.Strings(versions)
 
-	for _, version := range versions {
-		rows, err := client.Query(fmt.Sprintf("SELECT version FROM schema_migrations WHERE version = %s;", QuoteString(version)))
-		if err != nil {
-			return fmt.Errorf("check migration %s: %w", version, err)
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has a down script but no up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func checksum(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(m.migrations))
+	result := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := MigrationStatus{Version: migration.Version, State: MigrationPending}
+		if a, ok := applied[migration.Version]; ok {
+			status.State = MigrationApplied
+			status.AppliedAt = a.appliedAt
+			// Migrations applied before checksums were recorded are trusted.
+			if a.checksum != "" && a.checksum != migration.Checksum {
+				status.State = MigrationModified
+			}
+		}
+		result = append(result, status)
+	}
+	for version, a := range applied {
+		if !known[version] {
+			result = append(result, MigrationStatus{Version: version, State: MigrationUnknown, AppliedAt: a.appliedAt})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// Verify reports ErrSchemaAhead or ErrChecksumMismatch if the database does
+// not match the migrations compiled into this binary.
+func (m *Migrator) Verify() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		switch status.State {
+		case MigrationUnknown:
+			return fmt.Errorf("%w: %s is applied but not known", ErrSchemaAhead, status.Version)
+		case MigrationModified:
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, status.Version)
 		}
-		if len(rows) > 0 {
+	}
+	return nil
+}
+
+// Up applies every pending migration in order and returns their versions.
+func (m *Migrator) Up() ([]string, error) {
+	if !m.DryRun {
+		if err := m.ensureMigrationsTable(); err != nil {
+			return nil, err
+		}
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0)
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
 			continue
 		}
+		if err := m.runUp(migration); err != nil {
+			return versions, fmt.Errorf("apply migration %s: %w", migration.Version, err)
+		}
+		versions = append(versions, migration.Version)
+	}
+	return versions, nil
+}
 
-		path := filepath.ToSlash(filepath.Join("migrations", version))
-		sqlBytes, err := migrationFiles.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("read migration %s: %w", version, err)
+// Down reverts the n most recently applied migrations and returns their
+// versions.
+func (m *Migrator) Down(n int) ([]string, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0, n)
+	for i := len(m.migrations) - 1; i >= 0 && len(versions) < n; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return versions, fmt.Errorf("%w: %s", ErrNoDownMigration, migration.Version)
+		}
+		if err := m.runDown(migration); err != nil {
+			return versions, fmt.Errorf("revert migration %s: %w", migration.Version, err)
+		}
+		versions = append(versions, migration.Version)
+	}
+	return versions, nil
+}
+
+// Redo reverts the most recently applied migration and applies it again.
+func (m *Migrator) Redo() (string, error) {
+	if !m.DryRun {
+		if err := m.ensureMigrationsTable(); err != nil {
+			return "", err
 		}
+	}
+	reverted, err := m.Down(1)
+	if err != nil {
+		return "", err
+	}
+	if len(reverted) == 0 {
+		return "", errors.New("no applied migration to redo")
+	}
+	for _, migration := range m.migrations {
+		if migration.Version == reverted[0] {
+			if err := m.runUp(migration); err != nil {
+				return "", fmt.Errorf("apply migration %s: %w", migration.Version, err)
+			}
+		}
+	}
+	return reverted[0], nil
+}
+
+func (m *Migrator) runUp(migration Migration) error {
+	if m.DryRun {
+		fmt.Fprintf(m.Out, "-- up %s\n%s\n", migration.Version, strings.TrimSpace(migration.Up))
+		return nil
+	}
+	return m.client.WithTx(context.Background(), func(tx *Client) error {
+		// Migration files hold several statements, which cannot be prepared
+		// as one, so they go straight to the underlying transaction.
+		if _, err := tx.tx.Exec(migration.Up); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT INTO schema_migrations(version, checksum) VALUES (?, ?);", migration.Version, migration.Checksum)
+		return err
+	})
+}
+
+func (m *Migrator) runDown(migration Migration) error {
+	if m.DryRun {
+		fmt.Fprintf(m.Out, "-- down %s\n%s\n", migration.Version, strings.TrimSpace(migration.Down))
+		return nil
+	}
+	return m.client.WithTx(context.Background(), func(tx *Client) error {
+		if _, err := tx.tx.Exec(migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?;", migration.Version)
+		return err
+	})
+}
 
-		script := "BEGIN;\n" + string(sqlBytes) + "\n" +
-			fmt.Sprintf("INSERT INTO schema_migrations(version) VALUES (%s);\n", QuoteString(version)) +
-			"COMMIT;"
-		if err := client.Exec(script); err != nil {
-			return fmt.Errorf("apply migration %s: %w", version, err)
+// applied reads schema_migrations without changing it, so that Status and dry
+// runs leave the database alone. A missing table means nothing is applied.
+func (m *Migrator) applied() (map[string]appliedMigration, error) {
+	var hasTable int
+	if err := m.client.QueryRow(m.migrationsTableQuery()).Scan(&hasTable); err != nil {
+		return nil, fmt.Errorf("inspect migrations table: %w", err)
+	}
+	if hasTable == 0 {
+		return map[string]appliedMigration{}, nil
+	}
+	var hasChecksum int
+	if err := m.client.QueryRow(m.checksumColumnQuery()).Scan(&hasChecksum); err != nil {
+		return nil, fmt.Errorf("inspect migrations table: %w", err)
+	}
+	query := `SELECT version, checksum, applied_at FROM schema_migrations;`
+	if hasChecksum == 0 {
+		query = `SELECT version, NULL, applied_at FROM schema_migrations;`
+	}
+
+	rows, err := m.client.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		var sum sql.NullString
+		if err := rows.Scan(&a.version, &sum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("read applied migrations: %w", err)
 		}
+		a.checksum = sum.String
+		applied[a.version] = a
+	}
+	return applied, rows.Err()
+}
+
+// ensureMigrationsTable creates schema_migrations and brings tables written
+// by older binaries up to date. Only real runs of Up and Redo call it.
+func (m *Migrator) ensureMigrationsTable() error {
+	ddl := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TEXT NOT NULL DEFAULT (datetime('now')),
+			checksum TEXT
+		);
+	`
+	if m.client.dialect == DialectPostgres {
+		ddl = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			checksum TEXT
+		);
+	`
+	}
+	if _, err := m.client.db.Exec(ddl); err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
 	}
 
+	// Databases created before checksums were recorded lack the column; their
+	// applied migrations are trusted and stamped with the current checksum.
+	var hasChecksum int
+	if err := m.client.QueryRow(m.checksumColumnQuery()).Scan(&hasChecksum); err != nil {
+		return fmt.Errorf("inspect migrations table: %w", err)
+	}
+	if hasChecksum == 0 {
+		if _, err := m.client.db.Exec(`ALTER TABLE schema_migrations ADD COLUMN checksum TEXT;`); err != nil {
+			return fmt.Errorf("add checksum column: %w", err)
+		}
+	}
+	for _, migration := range m.migrations {
+		if _, err := m.client.Exec(
+			`UPDATE schema_migrations SET checksum = ? WHERE version = ? AND checksum IS NULL;`,
+			migration.Checksum, migration.Version,
+		); err != nil {
+			return fmt.Errorf("backfill checksum for %s: %w", migration.Version, err)
+		}
+	}
 	return nil
 }
+
+func (m *Migrator) migrationsTableQuery() string {
+	if m.client.dialect == DialectPostgres {
+		return `SELECT COUNT(*) FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = 'schema_migrations';`
+	}
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations';`
+}
+
+func (m *Migrator) checksumColumnQuery() string {
+	if m.client.dialect == DialectPostgres {
+		return `SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'schema_migrations' AND column_name = 'checksum';`
+	}
+	return `SELECT COUNT(*) FROM pragma_table_info('schema_migrations') WHERE name = 'checksum';`
+}
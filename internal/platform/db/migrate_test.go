@@ -0,0 +1,213 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsRemoveDanglingLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	// Recreate a database written before foreign keys were enforced.
+	legacy, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open legacy db: %v", err)
+	}
+	initial, err := migrationFiles.ReadFile("migrations/0001_initial.sql")
+	if err != nil {
+		t.Fatalf("read initial migration: %v", err)
+	}
+	if _, err := legacy.Exec(string(initial)); err != nil {
+		t.Fatalf("apply initial migration: %v", err)
+	}
+	if _, err := legacy.Exec(`
+		INSERT INTO schema_migrations(version) VALUES ('0001_initial.sql');
+		INSERT INTO products(id, name, description, price) VALUES (1, 'Shirt', '', 10);
+		INSERT INTO collections(id, name) VALUES (1, 'Summer');
+		INSERT INTO shops(id, name) VALUES (1, 'Shop');
+		INSERT INTO collection_products(collection_id, product_id) VALUES (1, 1), (1, 99), (99, 1);
+		INSERT INTO shop_collections(shop_id, collection_id) VALUES (1, 1), (1, 99);
+		INSERT INTO product_categories(product_id, category_id) VALUES (1, 99);
+	`); err != nil {
+		t.Fatalf("seed legacy db: %v", err)
+	}
+	legacy.Close()
+
+	client, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer client.Close()
+
+	for table, want := range map[string]int{
+		"collection_products": 1,
+		"shop_collections":    1,
+		"product_categories":  0,
+	} {
+		var got int
+		if err := client.QueryRow(`SELECT COUNT(*) FROM ` + table + `;`).Scan(&got); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if got != want {
+			t.Errorf("%s = %d, want %d", table, got, want)
+		}
+	}
+}
+
+func TestForeignKeysEnforced(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.Exec(`INSERT INTO shop_collections(shop_id, collection_id) VALUES (?, ?);`, 1, 1)
+	if !IsForeignKeyViolation(err) {
+		t.Fatalf("insert dangling link error = %v, want foreign key violation", err)
+	}
+}
+
+func newTestMigrator(t *testing.T) (*Client, *Migrator) {
+	t.Helper()
+	client, err := ConnectSQLite(filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatalf("ConnectSQLite: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	m, err := NewMigrator(client)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	return client, m
+}
+
+func TestMigratorDownAndUpRoundTrip(t *testing.T) {
+	client, m := newTestMigrator(t)
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(applied) != len(m.migrations) {
+		t.Fatalf("Up applied %v, want all %d migrations", applied, len(m.migrations))
+	}
+
+	reverted, err := m.Down(len(m.migrations))
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if len(reverted) != len(m.migrations) {
+		t.Fatalf("Down reverted %v, want all %d migrations", reverted, len(m.migrations))
+	}
+	var tables int
+	if err := client.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'products';`).Scan(&tables); err != nil {
+		t.Fatalf("inspect schema: %v", err)
+	}
+	if tables != 0 {
+		t.Fatal("products table still exists after reverting every migration")
+	}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+	if err := m.Verify(); err != nil {
+		t.Fatalf("Verify after round trip: %v", err)
+	}
+}
+
+func TestMigratorDryRunChangesNothing(t *testing.T) {
+	_, m := newTestMigrator(t)
+	var out strings.Builder
+	m.DryRun = true
+	m.Out = &out
+
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if !strings.Contains(out.String(), "CREATE TABLE IF NOT EXISTS products") {
+		t.Errorf("dry run output does not contain the migration SQL:\n%s", out.String())
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.State != MigrationPending {
+			t.Errorf("%s is %s after dry run, want pending", status.Version, status.State)
+		}
+	}
+	if got := countTables(t, m.client); got != 0 {
+		t.Errorf("tables after dry run and status = %d, want 0", got)
+	}
+}
+
+func TestMigratorStatusLeavesLegacyTableAlone(t *testing.T) {
+	client, m := newTestMigrator(t)
+	// Databases created before checksums were recorded lack the column.
+	if _, err := client.db.Exec(m.migrations[0].Up); err != nil {
+		t.Fatalf("apply initial migration: %v", err)
+	}
+	if _, err := client.Exec(`INSERT INTO schema_migrations(version) VALUES (?);`, m.migrations[0].Version); err != nil {
+		t.Fatalf("record initial migration: %v", err)
+	}
+
+	if err := m.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	m.DryRun = true
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("dry run Up: %v", err)
+	}
+	var hasChecksum int
+	if err := client.QueryRow(m.checksumColumnQuery()).Scan(&hasChecksum); err != nil {
+		t.Fatalf("inspect migrations table: %v", err)
+	}
+	if hasChecksum != 0 {
+		t.Fatal("status and dry run added the checksum column")
+	}
+
+	m.DryRun = false
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	var sum string
+	if err := client.QueryRow(`SELECT checksum FROM schema_migrations WHERE version = ?;`, m.migrations[0].Version).Scan(&sum); err != nil {
+		t.Fatalf("read checksum: %v", err)
+	}
+	if sum != m.migrations[0].Checksum {
+		t.Errorf("checksum = %q, want it backfilled", sum)
+	}
+}
+
+func countTables(t *testing.T, client *Client) int {
+	t.Helper()
+	var n int
+	if err := client.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table';`).Scan(&n); err != nil {
+		t.Fatalf("count tables: %v", err)
+	}
+	return n
+}
+
+func TestMigratorVerifyDetectsDrift(t *testing.T) {
+	client, m := newTestMigrator(t)
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	if _, err := client.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = ?;`, m.migrations[0].Version); err != nil {
+		t.Fatalf("tamper checksum: %v", err)
+	}
+	if err := m.Verify(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Verify with edited migration = %v, want %v", err, ErrChecksumMismatch)
+	}
+	if _, err := client.Exec(`UPDATE schema_migrations SET checksum = ? WHERE version = ?;`, m.migrations[0].Checksum, m.migrations[0].Version); err != nil {
+		t.Fatalf("restore checksum: %v", err)
+	}
+
+	if _, err := client.Exec(`INSERT INTO schema_migrations(version, checksum) VALUES ('9999_future.sql', 'x');`); err != nil {
+		t.Fatalf("record future migration: %v", err)
+	}
+	if err := ApplyMigrations(client); !errors.Is(err, ErrSchemaAhead) {
+		t.Fatalf("ApplyMigrations with newer schema = %v, want %v", err, ErrSchemaAhead)
+	}
+}
@@ -0,0 +1,27 @@
+CREATE TABLE collection_products_old (
+  collection_id INTEGER NOT NULL,
+  product_id INTEGER NOT NULL,
+  PRIMARY KEY (collection_id, product_id),
+  FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+  FOREIGN KEY(product_id) REFERENCES products(id)
+);
+
+INSERT INTO collection_products_old(collection_id, product_id)
+SELECT collection_id, product_id FROM collection_products;
+
+DROP TABLE collection_products;
+ALTER TABLE collection_products_old RENAME TO collection_products;
+
+CREATE TABLE shop_collections_old (
+  shop_id INTEGER NOT NULL,
+  collection_id INTEGER NOT NULL,
+  PRIMARY KEY (shop_id, collection_id),
+  FOREIGN KEY(shop_id) REFERENCES shops(id) ON DELETE CASCADE,
+  FOREIGN KEY(collection_id) REFERENCES collections(id)
+);
+
+INSERT INTO shop_collections_old(shop_id, collection_id)
+SELECT shop_id, collection_id FROM shop_collections;
+
+DROP TABLE shop_collections;
+ALTER TABLE shop_collections_old RENAME TO shop_collections;
@@ -0,0 +1,41 @@
+DELETE FROM product_categories
+WHERE product_id NOT IN (SELECT id FROM products)
+   OR category_id NOT IN (SELECT id FROM categories);
+
+UPDATE categories SET parent_id = NULL
+WHERE parent_id IS NOT NULL AND parent_id NOT IN (SELECT id FROM categories);
+
+UPDATE collections SET parent_id = NULL
+WHERE parent_id IS NOT NULL AND parent_id NOT IN (SELECT id FROM collections);
+
+CREATE TABLE collection_products_new (
+  collection_id INTEGER NOT NULL,
+  product_id INTEGER NOT NULL,
+  PRIMARY KEY (collection_id, product_id),
+  FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+  FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+INSERT INTO collection_products_new(collection_id, product_id)
+SELECT collection_id, product_id FROM collection_products
+WHERE collection_id IN (SELECT id FROM collections)
+  AND product_id IN (SELECT id FROM products);
+
+DROP TABLE collection_products;
+ALTER TABLE collection_products_new RENAME TO collection_products;
+
+CREATE TABLE shop_collections_new (
+  shop_id INTEGER NOT NULL,
+  collection_id INTEGER NOT NULL,
+  PRIMARY KEY (shop_id, collection_id),
+  FOREIGN KEY(shop_id) REFERENCES shops(id) ON DELETE CASCADE,
+  FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+INSERT INTO shop_collections_new(shop_id, collection_id)
+SELECT shop_id, collection_id FROM shop_collections
+WHERE shop_id IN (SELECT id FROM shops)
+  AND collection_id IN (SELECT id FROM collections);
+
+DROP TABLE shop_collections;
+ALTER TABLE shop_collections_new RENAME TO shop_collections;
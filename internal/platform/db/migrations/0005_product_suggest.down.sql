@@ -0,0 +1 @@
+DROP TABLE IF EXISTS products_fts_vocab;
@@ -0,0 +1 @@
+CREATE VIRTUAL TABLE products_fts_vocab USING fts5vocab(products_fts, 'col');
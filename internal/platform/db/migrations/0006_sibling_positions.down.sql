@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_collections_parent_position;
+DROP INDEX IF EXISTS idx_categories_parent_position;
+
+ALTER TABLE collections DROP COLUMN position;
+ALTER TABLE categories DROP COLUMN position;
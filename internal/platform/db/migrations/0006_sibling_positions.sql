@@ -0,0 +1,15 @@
+ALTER TABLE categories ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE collections ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+-- Existing siblings keep the ID order they were listed in so far.
+UPDATE categories SET position = (
+  SELECT COUNT(*) FROM categories s
+  WHERE s.parent_id IS categories.parent_id AND s.id < categories.id
+);
+UPDATE collections SET position = (
+  SELECT COUNT(*) FROM collections s
+  WHERE s.parent_id IS collections.parent_id AND s.id < collections.id
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_position ON categories(parent_id, position);
+CREATE INDEX IF NOT EXISTS idx_collections_parent_position ON collections(parent_id, position);
@@ -0,0 +1,4 @@
+ALTER TABLE shops DROP COLUMN version;
+ALTER TABLE collections DROP COLUMN version;
+ALTER TABLE categories DROP COLUMN version;
+ALTER TABLE products DROP COLUMN version;
@@ -0,0 +1,4 @@
+ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE categories ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE collections ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE shops ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
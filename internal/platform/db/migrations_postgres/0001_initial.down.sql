@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS shop_collections;
+DROP TABLE IF EXISTS shops;
+DROP TABLE IF EXISTS collection_products;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS product_categories;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
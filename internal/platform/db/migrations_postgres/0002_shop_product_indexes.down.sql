@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_shop_collections_collection_id;
+DROP INDEX IF EXISTS idx_collection_products_product_id;
+DROP INDEX IF EXISTS idx_product_categories_category_id;
+DROP INDEX IF EXISTS idx_collections_parent_id;
+DROP INDEX IF EXISTS idx_categories_parent_id;
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+CREATE INDEX IF NOT EXISTS idx_collections_parent_id ON collections(parent_id);
+CREATE INDEX IF NOT EXISTS idx_product_categories_category_id ON product_categories(category_id, product_id);
+CREATE INDEX IF NOT EXISTS idx_collection_products_product_id ON collection_products(product_id);
+CREATE INDEX IF NOT EXISTS idx_shop_collections_collection_id ON shop_collections(collection_id);
@@ -0,0 +1,17 @@
+ALTER TABLE products ADD COLUMN search_vector tsvector;
+
+CREATE FUNCTION products_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.description, '')), 'B');
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_search_vector BEFORE INSERT OR UPDATE OF name, description ON products
+  FOR EACH ROW EXECUTE FUNCTION products_search_vector_update();
+
+UPDATE products SET name = name;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
@@ -0,0 +1,11 @@
+-- pg_trgm is a setup prerequisite rather than part of the migration, since
+-- only a superuser or the database owner may create it.
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+    RAISE EXCEPTION 'the pg_trgm extension is missing: run CREATE EXTENSION pg_trgm as a superuser or the database owner first';
+  END IF;
+END;
+$$;
+
+CREATE INDEX idx_products_name_trgm ON products USING GIN (name gin_trgm_ops);
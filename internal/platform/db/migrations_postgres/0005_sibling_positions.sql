@@ -0,0 +1,13 @@
+ALTER TABLE categories ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE collections ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+-- Existing siblings keep the ID order they were listed in so far.
+UPDATE categories c SET position = s.position
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY id) - 1 AS position FROM categories) s
+WHERE c.id = s.id;
+UPDATE collections c SET position = s.position
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY id) - 1 AS position FROM collections) s
+WHERE c.id = s.id;
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_position ON categories(parent_id, position);
+CREATE INDEX IF NOT EXISTS idx_collections_parent_position ON collections(parent_id, position);
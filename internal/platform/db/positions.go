@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+
+	"categories-test/internal/platform/tree"
+)
+
+// Nested tables order siblings by a position column. The helpers below take
+// the table name, which is interpolated into the query and must not come from
+// user input; a nil parentID selects the roots.
+
+// NextPosition returns the position after the last child of parentID.
+func (c *Client) NextPosition(table string, parentID *int) (int, error) {
+	where, args := childrenOf(parentID)
+	var position int
+	err := c.QueryRow(`SELECT COALESCE(MAX(position) + 1, 0) FROM `+table+` WHERE `+where+`;`, args...).Scan(&position)
+	return position, err
+}
+
+// SiblingIDs lists the children of parentID in order.
+func (c *Client) SiblingIDs(table string, parentID *int) ([]int, error) {
+	where, args := childrenOf(parentID)
+	rows, err := c.Query(`SELECT id FROM `+table+` WHERE `+where+` ORDER BY position, id;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetPositions numbers ids in order, starting from zero.
+func (c *Client) SetPositions(table string, ids []int) error {
+	for position, id := range ids {
+		if _, err := c.Exec(`UPDATE `+table+` SET position = ? WHERE id = ?;`, position, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parents reads the parent links of table.
+func (c *Client) Parents(table string) (tree.Parents, error) {
+	rows, err := c.Query(`SELECT id, parent_id FROM ` + table + `;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := make(tree.Parents)
+	for rows.Next() {
+		var id int
+		var parentID sql.NullInt64
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, err
+		}
+		parents[id] = IntPtr(parentID)
+	}
+	return parents, rows.Err()
+}
+
+// CheckPlacement checks placing node id under parentID against the parent
+// links of table, and must run in the transaction that writes the node. On
+// PostgreSQL it first locks table against other writers until the
+// transaction ends, so the links cannot change before the write commits;
+// SQLite transactions hold the database write lock already.
+func (c *Client) CheckPlacement(table string, placement tree.Placement, id int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	if c.dialect == DialectPostgres {
+		if _, err := c.Exec(`LOCK TABLE ` + table + ` IN SHARE ROW EXCLUSIVE MODE;`); err != nil {
+			return err
+		}
+	}
+	parents, err := c.Parents(table)
+	if err != nil {
+		return err
+	}
+	return placement.Check(parents, id, parentID)
+}
+
+func childrenOf(parentID *int) (string, []any) {
+	if parentID == nil {
+		return `parent_id IS NULL`, nil
+	}
+	return `parent_id = ?`, []any{*parentID}
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// OpenPostgres connects to the database and migrates its schema. Product
+// suggestions need the pg_trgm extension, which only a superuser or the
+// database owner may create, so run CREATE EXTENSION pg_trgm once before the
+// first migration.
+func OpenPostgres(dsn string) (*Client, error) {
+	c, err := ConnectPostgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return migrated(c)
+}
+
+// ConnectPostgres opens the database without touching its schema.
+func ConnectPostgres(dsn string) (*Client, error) {
+	sqlDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	return newClient(sqlDB, DialectPostgres), nil
+}
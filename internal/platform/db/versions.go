@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// VersionErrors are the errors BumpVersion returns for a missing row and for
+// a row that changed since the caller read it, so that repositories report
+// their own.
+type VersionErrors struct {
+	NotFound error
+	Mismatch error
+}
+
+// BumpVersion increments the version of row id in table and returns the new
+// version. A non-zero expected version must match the stored one. Inside a
+// transaction the update also locks the row until it ends. table is
+// interpolated into the query and must not come from user input.
+func (c *Client) BumpVersion(table string, id, expected int, errs VersionErrors) (int, error) {
+	query := `UPDATE ` + table + ` SET version = version + 1 WHERE id = ?`
+	args := []any{id}
+	if expected != 0 {
+		query += ` AND version = ?`
+		args = append(args, expected)
+	}
+
+	var version int
+	err := c.QueryRow(query+` RETURNING version;`, args...).Scan(&version)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return version, err
+	}
+	if expected != 0 {
+		missing, lookupErr := c.MissingIDs(table, []int{id})
+		if lookupErr != nil {
+			return 0, lookupErr
+		}
+		if len(missing) == 0 {
+			return 0, errs.Mismatch
+		}
+	}
+	return 0, errs.NotFound
+}
@@ -0,0 +1,88 @@
+package httpx
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+const cursorPrefix = "id:"
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a keyset position: results continue with IDs greater than After.
+// IDs are never reused, so rows inserted while a client pages through a list
+// only ever show up after the rows it has already seen.
+type Cursor struct {
+	After int
+	Limit int
+}
+
+// CursorList is the response envelope of list endpoints in cursor mode.
+type CursorList[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"nextCursor"`
+}
+
+func EncodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(id)))
+}
+
+func DecodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || id < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// WantsCursor reports whether a list request opted into cursor pagination.
+// Without after or limit, list endpoints keep returning a plain array.
+func WantsCursor(query url.Values) bool {
+	return query.Has("after") || query.Has("limit")
+}
+
+func ParseCursor(query url.Values) (Cursor, error) {
+	cursor := Cursor{Limit: ParseLimit(query.Get("limit"))}
+	if after := query.Get("after"); after != "" {
+		id, err := DecodeCursor(after)
+		if err != nil {
+			return Cursor{}, err
+		}
+		cursor.After = id
+	}
+	return cursor, nil
+}
+
+// ParseLimit returns the requested page size, falling back to DefaultLimit
+// for missing or invalid values and capping it at MaxLimit.
+func ParseLimit(value string) int {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+// NextCursor returns the cursor following lastID, or nil on the last page.
+func NextCursor(lastID int, hasMore bool) *string {
+	if !hasMore {
+		return nil
+	}
+	cursor := EncodeCursor(lastID)
+	return &cursor
+}
@@ -0,0 +1,28 @@
+package httpx
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	id, err := DecodeCursor(EncodeCursor(42))
+	if err != nil || id != 42 {
+		t.Fatalf("DecodeCursor(EncodeCursor(42)) = %d, %v", id, err)
+	}
+	for _, cursor := range []string{"42", "not base64!", EncodeCursor(-1)} {
+		if _, err := DecodeCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) error = %v, want %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestParseCursorLimit(t *testing.T) {
+	tests := map[string]int{"": DefaultLimit, "0": DefaultLimit, "abc": DefaultLimit, "5": 5, "1000": MaxLimit}
+	for value, want := range tests {
+		cursor, err := ParseCursor(url.Values{"limit": {value}})
+		if err != nil || cursor.Limit != want {
+			t.Errorf("ParseCursor(limit=%q) = %+v, %v, want limit %d", value, cursor, err, want)
+		}
+	}
+}
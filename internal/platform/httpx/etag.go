@@ -0,0 +1,86 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrIfMatchRequired = errors.New("If-Match header is required")
+	ErrInvalidIfMatch  = errors.New("invalid If-Match header")
+	// ErrPreconditionFailed reports an If-Match header that cannot match the
+	// resource, before the write reaches the repository.
+	ErrPreconditionFailed = errors.New("If-Match does not match the current version")
+)
+
+// ETag is the entity tag of a resource version.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func SetETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// IfMatch returns the resource version a write must find, taken from its
+// required If-Match header. It returns zero for "*", which matches any
+// version. Writes compare entity tags strongly, so weak tags never match and
+// a header holding only weak tags fails with ErrPreconditionFailed. A list of
+// several strong tags matches when one of them is the current version, which
+// is then read through current.
+func IfMatch(r *http.Request, current func() (int, error)) (int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch {
+	case value == "":
+		return 0, ErrIfMatchRequired
+	case value == "*":
+		return 0, nil
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		weak := strings.HasPrefix(tag, "W/")
+		opaque, ok := strings.CutPrefix(strings.TrimPrefix(tag, "W/"), `"`)
+		if ok {
+			opaque, ok = strings.CutSuffix(opaque, `"`)
+		}
+		if !ok || strings.Contains(opaque, `"`) {
+			return 0, fmt.Errorf("%w: expected entity tags such as %s", ErrInvalidIfMatch, ETag(1))
+		}
+		// Tags this server did not issue are valid but match no version.
+		if version, err := strconv.Atoi(opaque); err == nil && version > 0 && !weak {
+			versions = append(versions, version)
+		}
+	}
+	switch len(versions) {
+	case 0:
+		return 0, fmt.Errorf("%w: no strong entity tag in If-Match", ErrPreconditionFailed)
+	case 1:
+		return versions[0], nil
+	}
+	version, err := current()
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, version) {
+		return 0, fmt.Errorf("%w: current version %s is not listed in If-Match", ErrPreconditionFailed, ETag(version))
+	}
+	return version, nil
+}
+
+// OptionalIfMatch is IfMatch for writes that cannot undo concurrent changes,
+// such as adding a single link, and so may be made without the header.
+func OptionalIfMatch(r *http.Request, current func() (int, error)) (int, error) {
+	if strings.TrimSpace(r.Header.Get("If-Match")) == "" {
+		return 0, nil
+	}
+	return IfMatch(r, current)
+}
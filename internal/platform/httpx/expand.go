@@ -0,0 +1,30 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+var ErrUnknownExpansion = errors.New("unknown expansion")
+
+// ParseExpand returns the relations named by the expand parameter, given
+// either comma-separated or repeated. Names outside allowed are rejected.
+func ParseExpand(query url.Values, allowed ...string) (map[string]bool, error) {
+	expand := make(map[string]bool)
+	for _, value := range query["expand"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !slices.Contains(allowed, name) {
+				return nil, fmt.Errorf("%w %q", ErrUnknownExpansion, name)
+			}
+			expand[name] = true
+		}
+	}
+	return expand, nil
+}
@@ -3,12 +3,33 @@ package httpx
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 )
 
+// MaxBodyBytes caps the size of JSON request bodies.
+const MaxBodyBytes = 1 << 20
+
+// ReadJSON decodes a single JSON value from the request body, rejecting
+// unknown fields, trailing data and bodies over MaxBodyBytes.
 func ReadJSON(r *http.Request, v interface{}) error {
-	return json.NewDecoder(r.Body).Decode(v)
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, tooLarge.Limit)
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+	// More reports false before a stray } or ], so only reaching the end
+	// of the body proves there is nothing after the value.
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: unexpected data after JSON value", ErrInvalidBody)
+	}
+	return nil
 }
 
 func WriteJSON(w http.ResponseWriter, v interface{}) {
@@ -16,11 +37,25 @@ func WriteJSON(w http.ResponseWriter, v interface{}) {
 }
 
 func ParseID(path string) (int, error) {
+	return parsePathID(path, 2)
+}
+
+// ParseLinkedID returns the ID of the linked resource in a link path such as
+// /api/collections/{id}/products/{productId}.
+func ParseLinkedID(path string) (int, error) {
+	return parsePathID(path, 4)
+}
+
+func parsePathID(path string, index int) (int, error) {
 	parts := splitPath(path)
-	if len(parts) < 3 {
-		return 0, errors.New("invalid path")
+	if len(parts) <= index {
+		return 0, ErrInvalidID
+	}
+	id, err := strconv.Atoi(parts[index])
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidID, parts[index])
 	}
-	return strconv.Atoi(parts[2])
+	return id, nil
 }
 
 func splitPath(path string) []string {
@@ -0,0 +1,112 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadJSON(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"valid", `{"name":"Tee"}`, nil},
+		{"unknown field", `{"name":"Tee","colour":"red"}`, ErrInvalidBody},
+		{"malformed", `{"name":`, ErrInvalidBody},
+		{"trailing data", `{"name":"Tee"} {"name":"Polo"}`, ErrInvalidBody},
+		{"trailing brace", `{"name":"Tee"}}`, ErrInvalidBody},
+		{"trailing bracket", `{"name":"Tee"}]`, ErrInvalidBody},
+		{"too large", `{"name":"` + strings.Repeat("a", MaxBodyBytes) + `"}`, ErrBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got payload
+			err := ReadJSON(httptest.NewRequest("POST", "/", strings.NewReader(tt.body)), &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadJSON error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got.Name != "Tee" {
+				t.Errorf("decoded %+v", got)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int
+		wantErr error
+	}{
+		{`"3"`, 3, nil},
+		{`*`, 0, nil},
+		{``, 0, ErrIfMatchRequired},
+		{`3`, 0, ErrInvalidIfMatch},
+		{`"3", *`, 0, ErrInvalidIfMatch},
+		// Weak tags never match under the strong comparison writes use.
+		{`W/"3"`, 0, ErrPreconditionFailed},
+		{`W/"3", "abc"`, 0, ErrPreconditionFailed},
+		{`W/"4", "3"`, 3, nil},
+		// The current version is 3.
+		{`"2", "3"`, 3, nil},
+		{`"1", "2"`, 0, ErrPreconditionFailed},
+	}
+	current := func() (int, error) { return 3, nil }
+	for _, tt := range tests {
+		r := httptest.NewRequest("PUT", "/", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		got, err := IfMatch(r, current)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("IfMatch(%q) = %d, %v, want %d, %v", tt.header, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReadMergePatch(t *testing.T) {
+	type resource struct {
+		Name     string `json:"name"`
+		ParentID *int   `json:"parentId"`
+		IDs      []int  `json:"ids"`
+	}
+	parentID := 7
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantErr     error
+	}{
+		{"omitted members kept", MergePatchContentType, `{"name":"Polo"}`, `{"name":"Polo","parentId":7,"ids":[1,2]}`, nil},
+		{"null resets", MergePatchContentType, `{"parentId":null,"ids":null}`, `{"name":"Tee","parentId":null,"ids":null}`, nil},
+		{"arrays replaced", "application/json", `{"ids":[3]}`, `{"name":"Tee","parentId":7,"ids":[3]}`, nil},
+		{"unknown member", MergePatchContentType, `{"colour":"red"}`, "", ErrInvalidBody},
+		{"not an object", MergePatchContentType, `["name"]`, "", ErrInvalidBody},
+		{"other media type", "text/plain", `{"name":"Polo"}`, "", ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PATCH", "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			got := resource{Name: "Tee", ParentID: &parentID, IDs: []int{1, 2}}
+			err := ReadMergePatch(r, &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadMergePatch error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if encoded, _ := json.Marshal(got); string(encoded) != tt.want {
+				t.Errorf("patched = %s, want %s", encoded, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package httpx
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+)
+
+const MergePatchContentType = "application/merge-patch+json"
+
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// ReadMergePatch applies the JSON merge patch (RFC 7396) in the request body
+// to v, a pointer to the current representation of the resource. Members the
+// patch omits keep their value, null resets a member to its zero value and
+// arrays are replaced as a whole. Members v does not know are rejected, as
+// with ReadJSON. Save the result at PatchVersion.
+func ReadMergePatch(r *http.Request, v any) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != MergePatchContentType && mediaType != "application/json") {
+			return fmt.Errorf("%w %q: use %s", ErrUnsupportedMediaType, contentType, MergePatchContentType)
+		}
+	}
+
+	var patch any
+	if err := ReadJSON(r, &patch); err != nil {
+		return err
+	}
+	if _, ok := patch.(map[string]any); !ok {
+		return fmt.Errorf("%w: merge patch must be a JSON object", ErrInvalidBody)
+	}
+
+	current, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var target any
+	if err := json.Unmarshal(current, &target); err != nil {
+		return err
+	}
+	merged, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return err
+	}
+
+	// Members removed by the patch are absent from merged, so start over from
+	// the zero value rather than keep their current values.
+	reflect.ValueOf(v).Elem().SetZero()
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+	return nil
+}
+
+// PatchVersion is the version to save a merge patch at, given the version
+// from IfMatch and the version the patch was applied to. The patch was
+// applied to the version read, so even with "If-Match: *" it must not
+// overwrite an update made since.
+func PatchVersion(ifMatch, read int) int {
+	return cmp.Or(ifMatch, read)
+}
+
+// mergePatch implements the MergePatch function of RFC 7396, section 2.
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,118 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"categories-test/internal/platform/validate"
+)
+
+const ProblemContentType = "application/problem+json"
+
+var (
+	ErrInvalidID     = errors.New("invalid id")
+	ErrInvalidBody   = errors.New("invalid request body")
+	ErrBodyTooLarge  = errors.New("request body too large")
+	ErrRouteNotFound = errors.New("route not found")
+	ErrInvalidParam  = errors.New("invalid parameter")
+)
+
+// Problem is an RFC 7807 problem details body. Code is a stable,
+// machine-readable identifier of the error; Errors lists the request fields
+// it concerns.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Code     string       `json:"code"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError = validate.FieldError
+
+// InvalidParam reports a malformed query parameter. It is a *validate.Error
+// that WriteError reports as 400 Bad Request rather than 422.
+func InvalidParam(name, message string) error {
+	invalid := &validate.Error{Fields: []FieldError{{Field: name, Code: "invalid", Message: message}}}
+	return fmt.Errorf("%w: %w", ErrInvalidParam, invalid)
+}
+
+// ErrorMapping maps a domain error to its problem response. Field, when set,
+// names the request field the error is about.
+type ErrorMapping struct {
+	Err    error
+	Status int
+	Code   string
+	Field  string
+}
+
+var commonErrors = []ErrorMapping{
+	{Err: ErrInvalidID, Status: http.StatusBadRequest, Code: "invalid_id", Field: "id"},
+	{Err: ErrInvalidBody, Status: http.StatusBadRequest, Code: "invalid_body"},
+	{Err: ErrBodyTooLarge, Status: http.StatusRequestEntityTooLarge, Code: "body_too_large"},
+	{Err: ErrInvalidCursor, Status: http.StatusBadRequest, Code: "invalid_cursor", Field: "after"},
+	{Err: ErrUnknownExpansion, Status: http.StatusBadRequest, Code: "unknown_expansion", Field: "expand"},
+	{Err: ErrRouteNotFound, Status: http.StatusNotFound, Code: "not_found"},
+	{Err: ErrIfMatchRequired, Status: http.StatusPreconditionRequired, Code: "if_match_required"},
+	{Err: ErrInvalidIfMatch, Status: http.StatusBadRequest, Code: "invalid_if_match"},
+	{Err: ErrPreconditionFailed, Status: http.StatusPreconditionFailed, Code: "version_mismatch"},
+	{Err: ErrUnsupportedMediaType, Status: http.StatusUnsupportedMediaType, Code: "unsupported_media_type"},
+}
+
+// WriteError writes err as a problem, using the first mapping it matches.
+// A *validate.Error is reported as 422 Unprocessable Entity with its fields.
+// Unmapped errors are logged and reported as internal errors without
+// exposing their details.
+func WriteError(w http.ResponseWriter, r *http.Request, err error, mappings []ErrorMapping) {
+	problem := Problem{Type: "about:blank", Instance: r.URL.Path}
+
+	var invalid *validate.Error
+	if errors.As(err, &invalid) {
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Code = "validation_failed"
+		if errors.Is(err, ErrInvalidParam) {
+			problem.Status = http.StatusBadRequest
+			problem.Code = "invalid_parameter"
+		}
+		problem.Detail = invalid.Error()
+		problem.Errors = invalid.Fields
+		WriteProblem(w, problem)
+		return
+	}
+
+	for _, mapping := range slices.Concat(mappings, commonErrors) {
+		if !errors.Is(err, mapping.Err) {
+			continue
+		}
+		problem.Status = mapping.Status
+		problem.Code = mapping.Code
+		problem.Detail = err.Error()
+		if mapping.Field != "" {
+			problem.Errors = []FieldError{{Field: mapping.Field, Code: mapping.Code, Message: err.Error()}}
+		}
+		WriteProblem(w, problem)
+		return
+	}
+
+	log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	problem.Status = http.StatusInternalServerError
+	problem.Code = "internal_error"
+	WriteProblem(w, problem)
+}
+
+// WriteProblem writes p, filling in its title from the status.
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,51 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"categories-test/internal/platform/validate"
+)
+
+var errWidgetMissing = errors.New("widget not found")
+
+func TestWriteError(t *testing.T) {
+	mappings := []ErrorMapping{{Err: errWidgetMissing, Status: http.StatusNotFound, Code: "widget_not_found", Field: "id"}}
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantFields int
+	}{
+		{"mapped", fmt.Errorf("load: %w", errWidgetMissing), http.StatusNotFound, "widget_not_found", 1},
+		{"common", ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor", 1},
+		{"validation", &validate.Error{Fields: []FieldError{{Field: "name", Code: "required", Message: "is required"}}}, http.StatusUnprocessableEntity, "validation_failed", 1},
+		{"param", InvalidParam("sort", "is unknown"), http.StatusBadRequest, "invalid_parameter", 1},
+		{"unmapped", errors.New("disk on fire"), http.StatusInternalServerError, "internal_error", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(rec, httptest.NewRequest(http.MethodGet, "/api/widgets/1", nil), tt.err, mappings)
+
+			if rec.Code != tt.wantStatus || rec.Header().Get("Content-Type") != ProblemContentType {
+				t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			var problem Problem
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Status != tt.wantStatus || problem.Code != tt.wantCode || len(problem.Errors) != tt.wantFields || problem.Instance != "/api/widgets/1" {
+				t.Errorf("problem = %+v", problem)
+			}
+			if tt.wantStatus == http.StatusInternalServerError && problem.Detail != "" {
+				t.Errorf("internal error detail %q leaked", problem.Detail)
+			}
+		})
+	}
+}
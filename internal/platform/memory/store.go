@@ -0,0 +1,267 @@
+package memory
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"categories-test/internal/platform/tree"
+)
+
+type ProductRow struct {
+	ID          int
+	Name        string
+	Description string
+	Price       float64
+	CategoryIDs []int
+	Version     int
+}
+
+type CategoryRow struct {
+	ID       int
+	Name     string
+	ParentID *int
+	Position int
+	Version  int
+}
+
+type CollectionRow struct {
+	ID         int
+	Name       string
+	ParentID   *int
+	Position   int
+	ProductIDs []int
+	Version    int
+}
+
+type ShopRow struct {
+	ID            int
+	Name          string
+	CollectionIDs []int
+	Version       int
+}
+
+// Tables is the shared state behind the in-memory repositories. Link IDs are
+// stored on the owning row, mirroring the link tables of the SQL schema.
+type Tables struct {
+	Products    map[int]*ProductRow
+	Categories  map[int]*CategoryRow
+	Collections map[int]*CollectionRow
+	Shops       map[int]*ShopRow
+
+	lastIDs map[string]int
+}
+
+// NextID returns a new identifier for table. Identifiers are never reused,
+// matching SQLite's AUTOINCREMENT.
+func (t *Tables) NextID(table string) int {
+	t.lastIDs[table]++
+	return t.lastIDs[table]
+}
+
+// ReserveID makes NextID return identifiers above id, which was assigned
+// outside of NextID.
+func (t *Tables) ReserveID(table string, id int) {
+	t.lastIDs[table] = max(t.lastIDs[table], id)
+}
+
+// VersionMatches reports whether a row at version current may be replaced by
+// a caller expecting version expected. Zero expects any version.
+func VersionMatches(current, expected int) bool {
+	return expected == 0 || expected == current
+}
+
+type Store struct {
+	mu     sync.RWMutex
+	tables *Tables
+}
+
+func NewStore() *Store {
+	return &Store{tables: &Tables{
+		Products:    make(map[int]*ProductRow),
+		Categories:  make(map[int]*CategoryRow),
+		Collections: make(map[int]*CollectionRow),
+		Shops:       make(map[int]*ShopRow),
+		lastIDs:     make(map[string]int),
+	}}
+}
+
+func (s *Store) Read(fn func(t *Tables)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.tables)
+}
+
+// Write runs fn with exclusive access to the tables. fn must validate its
+// input before mutating anything, since Write does not roll back; Tx does.
+func (s *Store) Write(fn func(t *Tables) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.tables)
+}
+
+// Tx runs fn against a copy of the tables, which replaces them when fn
+// returns nil and is dropped otherwise, so the repositories built on tx roll
+// back together. The store stays locked until fn returns, much like an
+// immediate SQLite transaction, and fn must only use tx.
+func (s *Store) Tx(fn func(tx *Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := &Store{tables: s.tables.copy()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.tables = tx.tables
+	return nil
+}
+
+func (t *Tables) copy() *Tables {
+	c := &Tables{
+		Products:    make(map[int]*ProductRow, len(t.Products)),
+		Categories:  make(map[int]*CategoryRow, len(t.Categories)),
+		Collections: make(map[int]*CollectionRow, len(t.Collections)),
+		Shops:       make(map[int]*ShopRow, len(t.Shops)),
+		lastIDs:     make(map[string]int, len(t.lastIDs)),
+	}
+	for id, row := range t.Products {
+		r := *row
+		r.CategoryIDs = slices.Clone(row.CategoryIDs)
+		c.Products[id] = &r
+	}
+	for id, row := range t.Categories {
+		r := *row
+		r.ParentID = CopyIntPtr(row.ParentID)
+		c.Categories[id] = &r
+	}
+	for id, row := range t.Collections {
+		r := *row
+		r.ParentID = CopyIntPtr(row.ParentID)
+		r.ProductIDs = slices.Clone(row.ProductIDs)
+		c.Collections[id] = &r
+	}
+	for id, row := range t.Shops {
+		r := *row
+		r.CollectionIDs = slices.Clone(row.CollectionIDs)
+		c.Shops[id] = &r
+	}
+	for table, id := range t.lastIDs {
+		c.lastIDs[table] = id
+	}
+	return c
+}
+
+// SortedInts returns a sorted copy of values, matching the ORDER BY used by
+// the SQL repositories when reading link tables.
+func SortedInts(values []int) []int {
+	if len(values) == 0 {
+		return nil
+	}
+	result := append([]int{}, values...)
+	sort.Ints(result)
+	return result
+}
+
+func CopyIntPtr(value *int) *int {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
+
+func ContainsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func RemoveInt(values []int, value int) []int {
+	result := values[:0]
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func HasDuplicates(values []int) bool {
+	seen := make(map[int]bool, len(values))
+	for _, v := range values {
+		if seen[v] {
+			return true
+		}
+		seen[v] = true
+	}
+	return false
+}
+
+// PageAfter returns up to limit items whose ID is greater than after. items
+// must be sorted by ID.
+func PageAfter[T any](items []T, id func(T) int, after, limit int) []T {
+	start := sort.Search(len(items), func(i int) bool { return id(items[i]) > after })
+	end := start + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+// MissingIDs returns the ids that have no row in rows.
+func MissingIDs[T any](rows map[int]T, ids []int) []int {
+	missing := make([]int, 0)
+	for _, id := range ids {
+		if _, ok := rows[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// SiblingIDs lists the rows under parentID, or the roots when it is nil, in
+// position order. place returns the parent and position of a row.
+func SiblingIDs[T any](rows map[int]T, place func(T) (*int, int), parentID *int) []int {
+	type sibling struct{ id, position int }
+	siblings := make([]sibling, 0)
+	for id, row := range rows {
+		if parent, position := place(row); tree.SameParent(parent, parentID) {
+			siblings = append(siblings, sibling{id, position})
+		}
+	}
+	sort.Slice(siblings, func(i, j int) bool {
+		if siblings[i].position != siblings[j].position {
+			return siblings[i].position < siblings[j].position
+		}
+		return siblings[i].id < siblings[j].id
+	})
+	ids := make([]int, 0, len(siblings))
+	for _, s := range siblings {
+		ids = append(ids, s.id)
+	}
+	return ids
+}
+
+// NextPosition returns the position after the last row under parentID.
+func NextPosition[T any](rows map[int]T, place func(T) (*int, int), parentID *int) int {
+	next := 0
+	for _, row := range rows {
+		if parent, position := place(row); tree.SameParent(parent, parentID) && position >= next {
+			next = position + 1
+		}
+	}
+	return next
+}
+
+// Parents maps each row to its parent. place returns the parent and position
+// of a row.
+func Parents[T any](rows map[int]T, place func(T) (*int, int)) tree.Parents {
+	parents := make(tree.Parents, len(rows))
+	for id, row := range rows {
+		parent, _ := place(row)
+		parents[id] = CopyIntPtr(parent)
+	}
+	return parents
+}
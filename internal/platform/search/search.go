@@ -0,0 +1,199 @@
+// Package search turns user input into full-text queries and renders
+// highlighted snippets consistently across storage backends.
+package search
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// StartMark and EndMark delimit highlighted words in raw snippets. They are
+// control characters so they cannot collide with product text, which lets
+// Snippet escape the text before turning them into markup.
+const (
+	StartMark = "\x02"
+	EndMark   = "\x03"
+)
+
+const snippetWords = 12
+
+// Terms splits q into lowercase words. Punctuation is dropped, so user input
+// never reaches the database as query syntax.
+func Terms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// FTS5Query matches documents containing every term as a word prefix.
+func FTS5Query(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		parts = append(parts, fmt.Sprintf(`"%s"*`, term))
+	}
+	return strings.Join(parts, " ")
+}
+
+// FTS5ColumnQuery is FTS5Query restricted to a single column.
+func FTS5ColumnQuery(column string, terms []string) string {
+	return fmt.Sprintf("%s : (%s)", column, FTS5Query(terms))
+}
+
+// TSQuery is the PostgreSQL to_tsquery equivalent of FTS5Query.
+func TSQuery(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		parts = append(parts, term+":*")
+	}
+	return strings.Join(parts, " & ")
+}
+
+// Snippet escapes raw for HTML and turns the marks into <mark> elements.
+func Snippet(raw string) string {
+	escaped := html.EscapeString(raw)
+	escaped = strings.ReplaceAll(escaped, StartMark, "<mark>")
+	return strings.ReplaceAll(escaped, EndMark, "</mark>")
+}
+
+// Matches reports whether a word of text starts with term.
+func Matches(text, term string) bool {
+	for _, word := range Terms(text) {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// Score rates a product for backends without a full-text index: every term
+// must prefix a word of the name or the description, and name matches weigh
+// ten times as much, mirroring the BM25 column weights used with FTS5.
+func Score(terms []string, name, description string) (float64, bool) {
+	score := 0.0
+	for _, term := range terms {
+		switch {
+		case Matches(name, term):
+			score += 10
+		case Matches(description, term):
+			score++
+		default:
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+// maxEdits is the number of typos tolerated in a term, growing with its
+// length so short prefixes do not match everything.
+func maxEdits(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Similar reports whether word is term with a few typos, either as a whole
+// word or as the prefix a shopper is still typing.
+func Similar(term, word string) bool {
+	if strings.HasPrefix(word, term) {
+		return true
+	}
+	edits := maxEdits(term)
+	if edits == 0 {
+		return false
+	}
+	if Distance(term, word) <= edits {
+		return true
+	}
+	runes := []rune(word)
+	if n := len([]rune(term)); n < len(runes) {
+		return Distance(term, string(runes[:n])) <= edits
+	}
+	return false
+}
+
+// Fuzzy reports whether every term is similar to some word of text.
+func Fuzzy(text string, terms []string) bool {
+	words := Terms(text)
+	for _, term := range terms {
+		found := false
+		for _, word := range words {
+			if Similar(term, word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Distance is the edit distance between a and b, counting a swap of two
+// adjacent characters as a single typo.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// Highlight builds a snippet of text around its first matching word for
+// backends without native snippet support.
+func Highlight(text string, terms []string) string {
+	words := strings.Fields(text)
+	first := -1
+	for i, word := range words {
+		for _, term := range terms {
+			if Matches(word, term) {
+				words[i] = StartMark + word + EndMark
+				if first < 0 {
+					first = i
+				}
+				break
+			}
+		}
+	}
+
+	start := 0
+	if first > snippetWords/2 {
+		start = first - snippetWords/2
+	}
+	end := start + snippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+	snippet := strings.Join(words[start:end], " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(words) {
+		snippet += "…"
+	}
+	return Snippet(snippet)
+}
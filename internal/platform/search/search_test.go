@@ -0,0 +1,41 @@
+package search
+
+import "testing"
+
+func TestQueriesDropSyntax(t *testing.T) {
+	terms := Terms(`Red "shirt"* OR -café`)
+	if got, want := FTS5Query(terms), `"red"* "shirt"* "or"* "café"*`; got != want {
+		t.Errorf("FTS5Query = %q, want %q", got, want)
+	}
+	if got, want := TSQuery(terms), `red:* & shirt:* & or:* & café:*`; got != want {
+		t.Errorf("TSQuery = %q, want %q", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("A <b>linen</b> shirt", []string{"shi"})
+	if want := "A &lt;b&gt;linen&lt;/b&gt; <mark>shirt</mark>"; got != want {
+		t.Errorf("Highlight = %q, want %q", got, want)
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	tests := []struct {
+		text  string
+		query string
+		want  bool
+	}{
+		{"Linen Shirt", "shi", true},
+		{"Linen Shirt", "shrit", true},
+		{"Linen Shirt", "linn shirt", true},
+		{"Linen Shirt", "sh", true},
+		{"Linen Shirt", "sj", false},
+		{"Linen Shirt", "boots", false},
+		{"Sandal", "sandle", true},
+	}
+	for _, tc := range tests {
+		if got := Fuzzy(tc.text, Terms(tc.query)); got != tc.want {
+			t.Errorf("Fuzzy(%q, %q) = %v, want %v", tc.text, tc.query, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package tree
+
+import "fmt"
+
+// Move places a node under ParentID, right before BeforeID or right after
+// AfterID, or after its last sibling when neither is set. A non-zero Version
+// must match the node's.
+type Move struct {
+	ID       int
+	ParentID *int
+	BeforeID *int
+	AfterID  *int
+	Version  int
+}
+
+// Placement keeps a tree free of cycles and no deeper than MaxDepth, roots
+// counting as depth 1. ErrCycle and ErrTooDeep are the errors Check reports,
+// so that each tree reports its own.
+type Placement struct {
+	MaxDepth   int
+	ErrCycle   error
+	ErrTooDeep error
+}
+
+type Option func(*Placement)
+
+// WithMaxDepth limits how deeply nodes nest. Non-positive values keep
+// DefaultMaxDepth.
+func WithMaxDepth(depth int) Option {
+	return func(p *Placement) {
+		if depth > 0 {
+			p.MaxDepth = depth
+		}
+	}
+}
+
+func NewPlacement(errCycle, errTooDeep error, opts ...Option) Placement {
+	p := Placement{MaxDepth: DefaultMaxDepth, ErrCycle: errCycle, ErrTooDeep: errTooDeep}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+// Check rejects putting node id, with its existing subtree, under parentID
+// when that closes a cycle or nests deeper than MaxDepth. A zero id is a new
+// node. Repositories check inside the transaction that writes the node, so
+// concurrent writes cannot together break the rules.
+func (pl Placement) Check(parents Parents, id int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	if id != 0 && (id == *parentID || parents.IsAncestor(id, *parentID)) {
+		return pl.ErrCycle
+	}
+	depth := parents.Depth(*parentID) + 1
+	if id != 0 {
+		depth += parents.Height(id) - 1
+	}
+	if depth > pl.MaxDepth {
+		return fmt.Errorf("%w: at most %d levels are allowed", pl.ErrTooDeep, pl.MaxDepth)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Package tree walks the parent links of nested categories and collections.
+// Every walk tolerates cycles, so corrupt data cannot make it loop forever.
+package tree
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+)
+
+// DefaultMaxDepth is the deepest nesting allowed unless configured otherwise.
+const DefaultMaxDepth = 10
+
+// Parents maps each node to its parent, or to nil for roots.
+type Parents map[int]*int
+
+// Depth counts the nodes from the root down to id, so roots have depth 1.
+func (p Parents) Depth(id int) int {
+	depth := 0
+	seen := make(map[int]bool)
+	for current := &id; current != nil && !seen[*current]; current = p[*current] {
+		seen[*current] = true
+		depth++
+	}
+	return depth
+}
+
+// Path lists the nodes from the root down to id, id included.
+func (p Parents) Path(id int) []int {
+	path := make([]int, 0)
+	seen := make(map[int]bool)
+	for current := &id; current != nil && !seen[*current]; current = p[*current] {
+		seen[*current] = true
+		path = append(path, *current)
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// IsAncestor reports whether ancestor is on the parent chain above id.
+func (p Parents) IsAncestor(ancestor, id int) bool {
+	seen := map[int]bool{id: true}
+	for current := p[id]; current != nil && !seen[*current]; current = p[*current] {
+		if *current == ancestor {
+			return true
+		}
+		seen[*current] = true
+	}
+	return false
+}
+
+// Descendants returns the nodes below id, every parent before its children.
+func (p Parents) Descendants(id int) []int {
+	children := p.children()
+	result := make([]int, 0)
+	seen := map[int]bool{id: true}
+	queue := []int{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			result = append(result, child)
+			queue = append(queue, child)
+		}
+	}
+	return result
+}
+
+// TopDown returns every node reachable from a root, each parent before its
+// children and siblings by ID.
+func (p Parents) TopDown() []int {
+	children := p.children()
+	result := make([]int, 0, len(p))
+	for _, id := range p.ids() {
+		if p[id] == nil {
+			result = append(result, id)
+		}
+	}
+	for i := 0; i < len(result); i++ {
+		result = append(result, children[result[i]]...)
+	}
+	return result
+}
+
+// Height counts the levels of the subtree rooted at id, so leaves have
+// height 1.
+func (p Parents) Height(id int) int {
+	children := p.children()
+	height := 0
+	seen := map[int]bool{id: true}
+	level := []int{id}
+	for len(level) > 0 {
+		height++
+		next := make([]int, 0)
+		for _, node := range level {
+			for _, child := range children[node] {
+				if !seen[child] {
+					seen[child] = true
+					next = append(next, child)
+				}
+			}
+		}
+		level = next
+	}
+	return height
+}
+
+// Cycles returns every cycle of parent links, each sorted and the list
+// ordered by smallest ID.
+func (p Parents) Cycles() [][]int {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[int]int, len(p))
+	cycles := make([][]int, 0)
+	for _, start := range p.ids() {
+		path := make([]int, 0)
+		current := &start
+		for current != nil && state[*current] == unvisited {
+			state[*current] = visiting
+			path = append(path, *current)
+			current = p[*current]
+		}
+		if current != nil && state[*current] == visiting {
+			// The walk came back to a node of this path: the cycle is the
+			// tail of the path starting at that node.
+			for i, id := range path {
+				if id == *current {
+					cycle := append([]int{}, path[i:]...)
+					sort.Ints(cycle)
+					cycles = append(cycles, cycle)
+					break
+				}
+			}
+		}
+		for _, id := range path {
+			state[id] = done
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// SameParent reports whether two parent links point to the same node, nil
+// standing for the root level.
+func SameParent(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Place returns siblings, in order, with id moved right before beforeID or
+// right after afterID, or to the end when neither is set. It reports false
+// when the anchor is not one of siblings.
+func Place(siblings []int, id int, beforeID, afterID *int) ([]int, bool) {
+	ordered := slices.DeleteFunc(slices.Clone(siblings), func(s int) bool { return s == id })
+	index := len(ordered)
+	if anchor := cmp.Or(beforeID, afterID); anchor != nil {
+		index = slices.Index(ordered, *anchor)
+		if index < 0 {
+			return nil, false
+		}
+		if afterID != nil {
+			index++
+		}
+	}
+	return slices.Insert(ordered, index, id), true
+}
+
+// Promote returns siblings with id replaced by its children, in order, as
+// when id is deleted and its children take its place.
+func Promote(siblings []int, id int, children []int) []int {
+	index := slices.Index(siblings, id)
+	if index < 0 {
+		return append(slices.Clone(siblings), children...)
+	}
+	return slices.Replace(slices.Clone(siblings), index, index+1, children...)
+}
+
+func (p Parents) children() map[int][]int {
+	children := make(map[int][]int)
+	for _, id := range p.ids() {
+		if parent := p[id]; parent != nil {
+			children[*parent] = append(children[*parent], id)
+		}
+	}
+	return children
+}
+
+func (p Parents) ids() []int {
+	ids := make([]int, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
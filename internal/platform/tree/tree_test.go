@@ -0,0 +1,122 @@
+package tree
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func ptr(v int) *int {
+	return &v
+}
+
+func TestWalks(t *testing.T) {
+	// 1 ─ 2 ─ 3
+	//   └ 4
+	parents := Parents{1: nil, 2: ptr(1), 3: ptr(2), 4: ptr(1)}
+
+	if got := parents.Depth(3); got != 3 {
+		t.Errorf("Depth(3) = %d, want 3", got)
+	}
+	if got := parents.Path(3); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Path(3) = %v, want [1 2 3]", got)
+	}
+	if got := parents.Height(1); got != 3 {
+		t.Errorf("Height(1) = %d, want 3", got)
+	}
+	if got := parents.Height(4); got != 1 {
+		t.Errorf("Height(4) = %d, want 1", got)
+	}
+	if !parents.IsAncestor(1, 3) || parents.IsAncestor(3, 1) || parents.IsAncestor(4, 3) {
+		t.Error("IsAncestor disagrees with the tree")
+	}
+	if got := parents.Descendants(1); !slices.Equal(got, []int{2, 4, 3}) {
+		t.Errorf("Descendants(1) = %v, want parents before children", got)
+	}
+	if got := parents.Cycles(); len(got) != 0 {
+		t.Errorf("Cycles() = %v, want none", got)
+	}
+	if got := parents.TopDown(); !slices.Equal(got, []int{1, 2, 4, 3}) {
+		t.Errorf("TopDown() = %v, want parents before children", got)
+	}
+}
+
+func TestCycles(t *testing.T) {
+	// 1 → 2 → 3 → 1 is a cycle with 4 hanging off it; 5 is its own parent.
+	parents := Parents{1: ptr(3), 2: ptr(1), 3: ptr(2), 4: ptr(2), 5: ptr(5), 6: nil}
+
+	if got := parents.Cycles(); len(got) != 2 || !slices.Equal(got[0], []int{1, 2, 3}) || !slices.Equal(got[1], []int{5}) {
+		t.Errorf("Cycles() = %v, want [[1 2 3] [5]]", got)
+	}
+	// Walks terminate despite the cycles.
+	if got := parents.Depth(4); got != 4 {
+		t.Errorf("Depth(4) = %d, want 4", got)
+	}
+	if got := parents.Descendants(1); !slices.Equal(got, []int{2, 3, 4}) {
+		t.Errorf("Descendants(1) = %v", got)
+	}
+	if parents.IsAncestor(6, 4) {
+		t.Error("IsAncestor(6, 4) = true")
+	}
+	if got := parents.Height(5); got != 1 {
+		t.Errorf("Height(5) = %d, want 1", got)
+	}
+}
+
+func TestPlacementCheck(t *testing.T) {
+	// 1 ─ 2 ─ 3
+	//   4 ─ 5
+	parents := Parents{1: nil, 2: ptr(1), 3: ptr(2), 4: nil, 5: ptr(4)}
+	errCycle, errTooDeep := errors.New("cycle"), errors.New("too deep")
+	placement := NewPlacement(errCycle, errTooDeep, WithMaxDepth(3))
+
+	tests := []struct {
+		name     string
+		id       int
+		parentID *int
+		want     error
+	}{
+		{"root", 1, nil, nil},
+		{"under itself", 2, ptr(2), errCycle},
+		{"under a descendant", 1, ptr(3), errCycle},
+		{"new node at the limit", 0, ptr(2), nil},
+		{"new node below the limit", 0, ptr(3), errTooDeep},
+		{"subtree at the limit", 4, ptr(1), nil},
+		{"subtree below the limit", 4, ptr(2), errTooDeep},
+	}
+	for _, tt := range tests {
+		if err := placement.Check(parents, tt.id, tt.parentID); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Check = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if got := NewPlacement(errCycle, errTooDeep, WithMaxDepth(0)).MaxDepth; got != DefaultMaxDepth {
+		t.Errorf("WithMaxDepth(0) sets MaxDepth %d, want %d", got, DefaultMaxDepth)
+	}
+}
+
+func TestPlace(t *testing.T) {
+	siblings := []int{1, 2, 3}
+	tests := []struct {
+		name              string
+		id                int
+		beforeID, afterID *int
+		want              []int
+	}{
+		{"to end", 1, nil, nil, []int{2, 3, 1}},
+		{"new sibling before", 4, ptr(2), nil, []int{1, 4, 2, 3}},
+		{"after last", 1, nil, ptr(3), []int{2, 3, 1}},
+		{"after first", 3, nil, ptr(1), []int{1, 3, 2}},
+		{"unknown anchor", 1, ptr(9), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Place(siblings, tt.id, tt.beforeID, tt.afterID)
+			if ok != (tt.want != nil) || !slices.Equal(got, tt.want) {
+				t.Errorf("Place = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+	if !slices.Equal(siblings, []int{1, 2, 3}) {
+		t.Errorf("Place modified its input: %v", siblings)
+	}
+}
@@ -0,0 +1,121 @@
+// Package validate collects field-level errors for command input, so every
+// package reports invalid requests the same way.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"categories-test/internal/platform/tree"
+)
+
+const MaxNameLength = 200
+
+// FieldError describes why a single input field was rejected. Field uses the
+// JSON name of the field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error lists the fields of a command that failed validation.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Errors accumulates field errors while a command is checked.
+type Errors struct {
+	fields []FieldError
+}
+
+func (e *Errors) Add(field, code, message string) {
+	e.fields = append(e.fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err returns the accumulated errors as an *Error, or nil when there are none.
+func (e *Errors) Err() error {
+	if len(e.fields) == 0 {
+		return nil
+	}
+	return &Error{Fields: e.fields}
+}
+
+// Name checks a required display name.
+func (e *Errors) Name(field, value string) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		e.Add(field, "required", "must not be empty")
+	case utf8.RuneCountInString(value) > MaxNameLength:
+		e.Add(field, "too_long", fmt.Sprintf("must be at most %d characters", MaxNameLength))
+	}
+}
+
+// References checks a list of IDs for repeats and, through missing, for IDs
+// that do not exist. Only lookup failures are returned.
+func (e *Errors) References(field string, ids []int, missing func(ids []int) ([]int, error)) error {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			e.Add(field, "duplicate", fmt.Sprintf("lists %d more than once", id))
+			return nil
+		}
+		seen[id] = true
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	absent, err := missing(ids)
+	if err != nil {
+		return err
+	}
+	if len(absent) > 0 {
+		e.Add(field, "not_found", fmt.Sprintf("references unknown IDs %v", absent))
+	}
+	return nil
+}
+
+// Links checks the IDs of links to add, of which there must be at least one,
+// like References.
+func (e *Errors) Links(field string, ids []int, missing func(ids []int) ([]int, error)) error {
+	if len(ids) == 0 {
+		e.Add(field, "required", "must list at least one ID")
+		return nil
+	}
+	return e.References(field, ids, missing)
+}
+
+// Parent checks an optional parent reference of the resource with the given
+// ID, which is zero for new resources.
+func (e *Errors) Parent(field string, id int, parentID *int, missing func(ids []int) ([]int, error)) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == id {
+		e.Add(field, "self_reference", "must not reference itself")
+		return nil
+	}
+	return e.References(field, []int{*parentID}, missing)
+}
+
+// Move checks the new parent of a move, which missing must find, and that it
+// names at most one neighbour.
+func (e *Errors) Move(move tree.Move, missing func(ids []int) ([]int, error)) error {
+	if err := e.Parent("parentId", move.ID, move.ParentID, missing); err != nil {
+		return err
+	}
+	if move.BeforeID != nil && move.AfterID != nil {
+		e.Add("afterId", "conflict", "must not be set together with beforeId")
+	}
+	return nil
+}
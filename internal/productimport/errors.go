@@ -0,0 +1,8 @@
+package productimport
+
+import "errors"
+
+var (
+	ErrInvalidFile = errors.New("invalid import file")
+	ErrTooManyRows = errors.New("import file has too many rows")
+)
@@ -0,0 +1,167 @@
+package productimport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"categories-test/internal/platform/httpx"
+	"categories-test/internal/platform/validate"
+)
+
+const (
+	CSVContentType    = "text/csv"
+	NDJSONContentType = "application/x-ndjson"
+	// MaxFileBytes caps the size of import files.
+	MaxFileBytes = 32 << 20
+)
+
+// Parsers maps the media types a handler accepts to the parsers reading
+// them.
+type Parsers map[string]func(r io.Reader) ([]Row, error)
+
+// DefaultParsers reads the CSV and NDJSON layouts of ParseCSV and
+// ParseNDJSON.
+var DefaultParsers = Parsers{
+	CSVContentType:       ParseCSV,
+	NDJSONContentType:    ParseNDJSON,
+	"application/ndjson": ParseNDJSON,
+}
+
+type HTTPHandler struct {
+	importer *Importer
+	parsers  Parsers
+}
+
+func NewHTTPHandler(importer *Importer, parsers Parsers) *HTTPHandler {
+	return &HTTPHandler{importer: importer, parsers: parsers}
+}
+
+// errorMappings maps import errors to problem responses.
+var errorMappings = []httpx.ErrorMapping{
+	{Err: ErrInvalidFile, Status: http.StatusBadRequest, Code: "invalid_import_file"},
+	{Err: ErrTooManyRows, Status: http.StatusRequestEntityTooLarge, Code: "too_many_rows"},
+}
+
+type reportDTO struct {
+	DryRun   bool           `json:"dryRun"`
+	Valid    int            `json:"valid"`
+	Invalid  int            `json:"invalid"`
+	Imported int            `json:"imported"`
+	Failed   int            `json:"failed"`
+	Rows     []rowResultDTO `json:"rows"`
+}
+
+type rowResultDTO struct {
+	Line           int                   `json:"line"`
+	Status         RowStatus             `json:"status"`
+	ProductID      int                   `json:"productId,omitzero"`
+	NewCategories  []string              `json:"newCategories,omitzero"`
+	NewCollections []string              `json:"newCollections,omitzero"`
+	Errors         []validate.FieldError `json:"errors,omitzero"`
+}
+
+func toReportDTO(report *Report) reportDTO {
+	dto := reportDTO{
+		DryRun:   report.DryRun,
+		Valid:    report.Valid,
+		Invalid:  report.Invalid,
+		Imported: report.Imported,
+		Failed:   report.Failed,
+		Rows:     make([]rowResultDTO, 0, len(report.Rows)),
+	}
+	for _, row := range report.Rows {
+		dto.Rows = append(dto.Rows, rowResultDTO{
+			Line:           row.Line,
+			Status:         row.Status,
+			ProductID:      row.ProductID,
+			NewCategories:  row.NewCategories,
+			NewCollections: row.NewCollections,
+			Errors:         row.Errors,
+		})
+	}
+	return dto
+}
+
+// Import reads a file of products in one of the handler's formats. With
+// ?dryRun=true it only reports whether each row would be imported. Otherwise
+// it imports the file when every row is valid, responding 422 Unprocessable
+// Entity with the report when some are not.
+func (h *HTTPHandler) Import(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := parseDryRun(r)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	rows, err := h.readRows(w, r)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	if dryRun {
+		report, err := h.importer.Check(rows)
+		if err != nil {
+			httpx.WriteError(w, r, err, errorMappings)
+			return
+		}
+		httpx.WriteJSON(w, toReportDTO(report))
+		return
+	}
+
+	report, err := h.importer.Import(rows)
+	switch {
+	case report == nil:
+		httpx.WriteError(w, r, err, errorMappings)
+	case err != nil:
+		// Earlier batches were committed, so the report is still worth
+		// returning.
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		writeReport(w, http.StatusInternalServerError, report)
+	case report.Invalid > 0:
+		writeReport(w, http.StatusUnprocessableEntity, report)
+	default:
+		httpx.WriteJSON(w, toReportDTO(report))
+	}
+}
+
+func writeReport(w http.ResponseWriter, status int, report *Report) {
+	w.WriteHeader(status)
+	httpx.WriteJSON(w, toReportDTO(report))
+}
+
+func parseDryRun(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("dryRun")
+	if value == "" {
+		return false, nil
+	}
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, httpx.InvalidParam("dryRun", "must be true or false")
+	}
+	return dryRun, nil
+}
+
+// readRows parses the request body according to its Content-Type.
+func (h *HTTPHandler) readRows(w http.ResponseWriter, r *http.Request) ([]Row, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	parse, ok := h.parsers[mediaType]
+	if !ok {
+		mediaTypes := slices.Sorted(maps.Keys(h.parsers))
+		return nil, fmt.Errorf("%w %q: use %s", httpx.ErrUnsupportedMediaType, r.Header.Get("Content-Type"), strings.Join(mediaTypes, " or "))
+	}
+
+	rows, err := parse(http.MaxBytesReader(w, r.Body, MaxFileBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, fmt.Errorf("%w: limit is %d bytes", httpx.ErrBodyTooLarge, tooLarge.Limit)
+	}
+	return rows, err
+}
@@ -0,0 +1,420 @@
+package productimport
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/platform/validate"
+	"categories-test/internal/products"
+)
+
+// DefaultBatchSize is the number of rows imported per transaction.
+const DefaultBatchSize = 500
+
+type CategoryRepository interface {
+	categories.CommandRepository
+	GetCategories() []*categories.Category
+}
+
+type CollectionRepository interface {
+	collections.CommandRepository
+	GetCollections() []*collections.Collection
+}
+
+// Repositories are the repositories an import reads and writes through.
+type Repositories struct {
+	Products    products.CommandRepository
+	Categories  CategoryRepository
+	Collections CollectionRepository
+}
+
+// Transactor runs fn with repositories sharing one transaction. InTx commits
+// it when fn returns nil and rolls it back otherwise. InReadTx only reads, so
+// checking rows neither takes the write lock nor waits for writers.
+type Transactor interface {
+	InTx(fn func(repos Repositories) error) error
+	InReadTx(fn func(repos Repositories) error) error
+}
+
+// TransactorFuncs adapts a pair of functions to a Transactor.
+type TransactorFuncs struct {
+	Tx     func(fn func(repos Repositories) error) error
+	ReadTx func(fn func(repos Repositories) error) error
+}
+
+func (f TransactorFuncs) InTx(fn func(repos Repositories) error) error {
+	return f.Tx(fn)
+}
+
+func (f TransactorFuncs) InReadTx(fn func(repos Repositories) error) error {
+	return f.ReadTx(fn)
+}
+
+type Importer struct {
+	tx                Transactor
+	batchSize         int
+	maxDepth          int
+	createCollections bool
+}
+
+type Option func(*Importer)
+
+// WithBatchSize sets the number of rows imported per transaction.
+// Non-positive values keep DefaultBatchSize.
+func WithBatchSize(size int) Option {
+	return func(i *Importer) {
+		if size > 0 {
+			i.batchSize = size
+		}
+	}
+}
+
+// WithMaxDepth limits how deeply created categories nest, as
+// tree.WithMaxDepth does.
+func WithMaxDepth(depth int) Option {
+	return func(i *Importer) {
+		if depth > 0 {
+			i.maxDepth = depth
+		}
+	}
+}
+
+// WithCollectionCreation makes rows naming collections that do not exist
+// create them, as roots, rather than be rejected.
+func WithCollectionCreation() Option {
+	return func(i *Importer) {
+		i.createCollections = true
+	}
+}
+
+func NewImporter(tx Transactor, opts ...Option) *Importer {
+	i := &Importer{tx: tx, batchSize: DefaultBatchSize, maxDepth: tree.DefaultMaxDepth}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// plannedRow is a valid row with its category paths split into levels.
+type plannedRow struct {
+	result      *RowResult
+	row         *Row
+	paths       [][]string
+	collections []string
+}
+
+// Check validates rows against the current catalog without changing it.
+func (i *Importer) Check(rows []Row) (*Report, error) {
+	report, _, err := i.check(rows)
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = true
+	return report, nil
+}
+
+// Import checks rows and, when they are all valid, imports them in batches,
+// each in its own transaction. Invalid rows make it import nothing. When a
+// batch fails, the batches before it stay imported and the error is returned
+// along with the report.
+func (i *Importer) Import(rows []Row) (*Report, error) {
+	report, plan, err := i.check(rows)
+	if err != nil {
+		return nil, err
+	}
+	if report.Invalid > 0 {
+		return report, nil
+	}
+
+	for start := 0; start < len(plan); start += i.batchSize {
+		batch := plan[start:min(start+i.batchSize, len(plan))]
+		err := i.tx.InTx(func(repos Repositories) error {
+			return i.importBatch(repos, batch)
+		})
+		if err != nil {
+			failBatch(batch, err)
+			for _, p := range plan[start+len(batch):] {
+				p.result.Status = RowSkipped
+			}
+			report.Failed = len(plan) - start
+			return report, fmt.Errorf("import lines %d to %d: %w", batch[0].row.Line, batch[len(batch)-1].row.Line, err)
+		}
+		report.Imported += len(batch)
+	}
+	return report, nil
+}
+
+func (i *Importer) check(rows []Row) (*Report, []plannedRow, error) {
+	report := &Report{Rows: make([]RowResult, len(rows))}
+	var plan []plannedRow
+	err := i.tx.InReadTx(func(repos Repositories) error {
+		categoryIDs := newCategoryIndex(repos.Categories.GetCategories())
+		collectionIDs := make(map[string][]int)
+		for _, c := range repos.Collections.GetCollections() {
+			collectionIDs[c.Name] = append(collectionIDs[c.Name], c.ID)
+		}
+
+		// Categories and collections created by earlier rows get placeholder
+		// IDs, so later rows find them instead of reporting them again.
+		placeholder := 0
+		createPlaceholder := func(string, *int) (int, error) {
+			placeholder--
+			return placeholder, nil
+		}
+
+		for n := range rows {
+			row, result := &rows[n], &report.Rows[n]
+			result.Line = row.Line
+			p, errs := i.checkRow(row, categoryIDs, collectionIDs)
+			if err := errs.Err(); err != nil {
+				result.Status = RowInvalid
+				result.Errors = err.(*validate.Error).Fields
+				report.Invalid++
+				continue
+			}
+			for _, path := range p.paths {
+				_, created, _ := categoryIDs.resolve(path, createPlaceholder)
+				result.NewCategories = append(result.NewCategories, created...)
+			}
+			for _, name := range p.collections {
+				if len(collectionIDs[name]) == 0 {
+					placeholder--
+					collectionIDs[name] = []int{placeholder}
+					result.NewCollections = append(result.NewCollections, name)
+				}
+			}
+			result.Status = RowValid
+			report.Valid++
+			p.result = result
+			plan = append(plan, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return report, plan, nil
+}
+
+func (i *Importer) checkRow(row *Row, categoryIDs categoryIndex, collectionIDs map[string][]int) (plannedRow, validate.Errors) {
+	var errs validate.Errors
+	p := plannedRow{row: row}
+	for _, fieldErr := range row.Errors {
+		errs.Add(fieldErr.Field, fieldErr.Code, fieldErr.Message)
+		if fieldErr.Field == "row" {
+			// The fields of an unreadable row are unknown.
+			return p, errs
+		}
+	}
+	errs.Name("name", row.Name)
+	if row.Price < 0 {
+		errs.Add("price", "negative", "must not be negative")
+	}
+
+	seenPaths := make(map[string]bool)
+	for _, path := range row.Categories {
+		levels := splitPath(path)
+		key := strings.Join(levels, " "+PathSeparator+" ")
+		switch {
+		case slices.Contains(levels, ""):
+			errs.Add("categories", "required", fmt.Sprintf("%q has an empty level", path))
+		case slices.ContainsFunc(levels, tooLong):
+			errs.Add("categories", "too_long", fmt.Sprintf("%q has a level longer than %d characters", path, validate.MaxNameLength))
+		case len(levels) > i.maxDepth && !categoryIDs.exists(levels):
+			errs.Add("categories", "too_deep", fmt.Sprintf("%q is deeper than %d levels", path, i.maxDepth))
+		case seenPaths[key]:
+			errs.Add("categories", "duplicate", fmt.Sprintf("lists %q more than once", key))
+		default:
+			p.paths = append(p.paths, levels)
+		}
+		seenPaths[key] = true
+	}
+
+	seenCollections := make(map[string]bool)
+	for _, name := range row.Collections {
+		ids := collectionIDs[name]
+		switch {
+		case seenCollections[name]:
+			errs.Add("collections", "duplicate", fmt.Sprintf("lists %q more than once", name))
+		case len(ids) == 0 && !i.createCollections:
+			errs.Add("collections", "not_found", fmt.Sprintf("no collection is named %q", name))
+		case len(ids) > 1:
+			errs.Add("collections", "ambiguous", fmt.Sprintf("%d collections are named %q", len(ids), name))
+		default:
+			p.collections = append(p.collections, name)
+		}
+		seenCollections[name] = true
+	}
+	return p, errs
+}
+
+// importBatch creates the products of batch, and the categories and
+// collections they need, through the commands of each package so their rules
+// apply, then adds the products to their collections.
+func (i *Importer) importBatch(repos Repositories, batch []plannedRow) error {
+	categoryCommands := categories.NewCommands(repos.Categories, tree.WithMaxDepth(i.maxDepth))
+	productCommands := products.NewCommands(repos.Products)
+	collectionCommands := collections.NewCommands(repos.Collections)
+
+	categoryIDs := newCategoryIndex(repos.Categories.GetCategories())
+	createCategory := func(name string, parentID *int) (int, error) {
+		category, err := categoryCommands.Create(&categories.Category{Name: name, ParentID: parentID})
+		if err != nil {
+			return 0, err
+		}
+		return category.ID, nil
+	}
+	collectionIDs := make(map[string]int)
+	for _, c := range repos.Collections.GetCollections() {
+		if id, ok := collectionIDs[c.Name]; !ok || c.ID < id {
+			collectionIDs[c.Name] = c.ID
+		}
+	}
+
+	additions := make(map[int][]int)
+	for _, p := range batch {
+		product := &products.Product{Name: p.row.Name, Description: p.row.Description, Price: p.row.Price, CategoryIDs: []int{}}
+		p.result.NewCategories = nil
+		p.result.NewCollections = nil
+		for _, path := range p.paths {
+			id, created, err := categoryIDs.resolve(path, createCategory)
+			if err != nil {
+				return err
+			}
+			product.CategoryIDs = append(product.CategoryIDs, id)
+			p.result.NewCategories = append(p.result.NewCategories, created...)
+		}
+		created, err := productCommands.Create(product)
+		if err != nil {
+			return err
+		}
+		p.result.Status = RowImported
+		p.result.ProductID = created.ID
+		for _, name := range p.collections {
+			id, ok := collectionIDs[name]
+			if !ok {
+				if !i.createCollections {
+					var errs validate.Errors
+					errs.Add("collections", "not_found", fmt.Sprintf("no collection is named %q", name))
+					return errs.Err()
+				}
+				collection, err := collectionCommands.Create(&collections.Collection{Name: name})
+				if err != nil {
+					return err
+				}
+				id = collection.ID
+				collectionIDs[name] = id
+				p.result.NewCollections = append(p.result.NewCollections, name)
+			}
+			additions[id] = append(additions[id], created.ID)
+		}
+	}
+
+	ids := make([]int, 0, len(additions))
+	for id := range additions {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if _, err := collectionCommands.AddProducts(id, 0, additions[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failBatch marks the rows of a rolled back batch as failed, listing the
+// field errors of err, if any, on each of them.
+func failBatch(batch []plannedRow, err error) {
+	var invalid *validate.Error
+	errors.As(err, &invalid)
+	for _, p := range batch {
+		p.result.Status = RowFailed
+		p.result.ProductID = 0
+		p.result.NewCategories = nil
+		p.result.NewCollections = nil
+		if invalid != nil {
+			p.result.Errors = invalid.Fields
+		}
+	}
+}
+
+// categoryIndex finds categories by parent and name, roots having parent 0.
+// Among siblings sharing a name, the oldest wins.
+type categoryIndex map[categoryKey]int
+
+type categoryKey struct {
+	parentID int
+	name     string
+}
+
+func newCategoryIndex(list []*categories.Category) categoryIndex {
+	index := make(categoryIndex, len(list))
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	for _, c := range list {
+		key := categoryKey{name: c.Name}
+		if c.ParentID != nil {
+			key.parentID = *c.ParentID
+		}
+		if _, ok := index[key]; !ok {
+			index[key] = c.ID
+		}
+	}
+	return index
+}
+
+func (x categoryIndex) exists(levels []string) bool {
+	parentID := 0
+	for _, name := range levels {
+		id, ok := x[categoryKey{parentID, name}]
+		if !ok {
+			return false
+		}
+		parentID = id
+	}
+	return true
+}
+
+// resolve returns the ID of the category at the path given by levels,
+// creating the missing levels with create. created lists the paths of the
+// categories it created.
+func (x categoryIndex) resolve(levels []string, create func(name string, parentID *int) (int, error)) (int, []string, error) {
+	var parentID *int
+	var created []string
+	for n, name := range levels {
+		key := categoryKey{name: name}
+		if parentID != nil {
+			key.parentID = *parentID
+		}
+		id, ok := x[key]
+		if !ok {
+			var err error
+			if id, err = create(name, parentID); err != nil {
+				return 0, nil, err
+			}
+			x[key] = id
+			created = append(created, strings.Join(levels[:n+1], " "+PathSeparator+" "))
+		}
+		parentID = &id
+	}
+	return *parentID, created, nil
+}
+
+func splitPath(path string) []string {
+	levels := strings.Split(path, PathSeparator)
+	for n := range levels {
+		levels[n] = strings.TrimSpace(levels[n])
+	}
+	return levels
+}
+
+func tooLong(name string) bool {
+	return utf8.RuneCountInString(name) > validate.MaxNameLength
+}
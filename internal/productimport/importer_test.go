@@ -0,0 +1,327 @@
+package productimport
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/products"
+)
+
+func memoryTransactor(store *memory.Store) Transactor {
+	return memoryTransactorWith(store, func(*memory.Store) error { return nil })
+}
+
+// memoryTransactorWith runs before at the start of each transaction.
+func memoryTransactorWith(store *memory.Store, before func(tx *memory.Store) error) Transactor {
+	repos := func(store *memory.Store) Repositories {
+		return Repositories{
+			Products:    products.NewMemoryRepository(store),
+			Categories:  categories.NewMemoryRepository(store),
+			Collections: collections.NewMemoryRepository(store),
+		}
+	}
+	return TransactorFuncs{
+		Tx: func(fn func(Repositories) error) error {
+			return store.Tx(func(tx *memory.Store) error {
+				if err := before(tx); err != nil {
+					return err
+				}
+				return fn(repos(tx))
+			})
+		},
+		ReadTx: func(fn func(Repositories) error) error {
+			if err := before(store); err != nil {
+				return err
+			}
+			return fn(repos(store))
+		},
+	}
+}
+
+// sqliteTransactor runs before at the start of each transaction.
+func sqliteTransactor(client *db.Client, before func(tx *db.Client) error) Transactor {
+	within := func(withTx func(context.Context, func(*db.Client) error) error) func(fn func(Repositories) error) error {
+		return func(fn func(Repositories) error) error {
+			return withTx(context.Background(), func(tx *db.Client) error {
+				if err := before(tx); err != nil {
+					return err
+				}
+				return fn(Repositories{
+					Products:    products.NewSQLiteRepository(tx),
+					Categories:  categories.NewSQLiteRepository(tx),
+					Collections: collections.NewSQLiteRepository(tx),
+				})
+			})
+		}
+	}
+	return TransactorFuncs{Tx: within(client.WithTx), ReadTx: within(client.WithReadTx)}
+}
+
+func TestImporterCheck(t *testing.T) {
+	store := memory.NewStore()
+	store.Write(func(t *memory.Tables) error {
+		t.Categories[1] = &memory.CategoryRow{ID: 1, Name: "Clothing"}
+		t.Collections[1] = &memory.CollectionRow{ID: 1, Name: "Summer"}
+		t.Collections[2] = &memory.CollectionRow{ID: 2, Name: "Sale"}
+		t.Collections[3] = &memory.CollectionRow{ID: 3, Name: "Sale"}
+		return nil
+	})
+	importer := NewImporter(memoryTransactor(store), WithMaxDepth(3))
+
+	report, err := importer.Check([]Row{
+		{Line: 2, Name: "Tee", Categories: []string{"Clothing > Shirts > Tees"}, Collections: []string{"Summer"}},
+		{Line: 3, Name: "Polo", Categories: []string{"Clothing > Shirts", "Clothing"}},
+		{Line: 4, Name: " ", Price: -1},
+		{Line: 5, Name: "Sock", Categories: []string{"Clothing >  > Socks", "A > B > C > D"}},
+		{Line: 6, Name: "Hat", Categories: []string{"Hats", "Hats"}, Collections: []string{"Winter", "Sale"}},
+	})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if !report.DryRun || report.Valid != 2 || report.Invalid != 3 {
+		t.Fatalf("report = %+v, want a dry run with 2 valid and 3 invalid rows", report)
+	}
+	if got := report.Rows[0].NewCategories; !slices.Equal(got, []string{"Clothing > Shirts", "Clothing > Shirts > Tees"}) {
+		t.Errorf("line 2 new categories = %v", got)
+	}
+	if got := report.Rows[1].NewCategories; got != nil {
+		t.Errorf("line 3 new categories = %v, want none, line 2 creates them", got)
+	}
+
+	codes := func(n int) []string {
+		var codes []string
+		for _, fieldErr := range report.Rows[n].Errors {
+			codes = append(codes, fieldErr.Field+":"+fieldErr.Code)
+		}
+		return codes
+	}
+	for n, want := range map[int][]string{
+		2: {"name:required", "price:negative"},
+		3: {"categories:required", "categories:too_deep"},
+		4: {"categories:duplicate", "collections:not_found", "collections:ambiguous"},
+	} {
+		if got := codes(n); !slices.Equal(got, want) {
+			t.Errorf("line %d errors = %v, want %v", report.Rows[n].Line, got, want)
+		}
+	}
+
+	if got := len(categories.NewMemoryRepository(store).GetCategories()); got != 1 {
+		t.Errorf("categories after dry run = %d, want 1", got)
+	}
+}
+
+func TestImporterImport(t *testing.T) {
+	store := memory.NewStore()
+	summer, err := collections.NewMemoryRepository(store).CreateCollection(&collections.Collection{Name: "Summer"}, collections.NewPlacement())
+	if err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	importer := NewImporter(memoryTransactor(store), WithBatchSize(2))
+
+	rows := []Row{
+		{Line: 2, Name: "Tee", Price: 12, Categories: []string{"Clothing > Shirts"}, Collections: []string{"Summer"}},
+		{Line: 3, Name: "Polo", Categories: []string{"Clothing > Shirts"}},
+		{Line: 4, Name: "Shorts", Categories: []string{"Clothing > Shorts"}, Collections: []string{"Summer"}},
+	}
+	report, err := importer.Import(rows)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if report.Imported != 3 || report.Failed != 0 {
+		t.Fatalf("report = %+v, want 3 imported rows", report)
+	}
+
+	productIDs := make([]int, 0, len(report.Rows))
+	for _, row := range report.Rows {
+		if row.Status != RowImported || row.ProductID == 0 {
+			t.Fatalf("line %d = %+v, want an imported product", row.Line, row)
+		}
+		productIDs = append(productIDs, row.ProductID)
+	}
+	if got := report.Rows[2].NewCategories; !slices.Equal(got, []string{"Clothing > Shorts"}) {
+		t.Errorf("line 4 new categories = %v, want the category missing from the first batch", got)
+	}
+
+	tee, err := products.NewMemoryRepository(store).GetProduct(productIDs[0])
+	if err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	polo, err := products.NewMemoryRepository(store).GetProduct(productIDs[1])
+	if err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	if tee.Price != 12 || !reflect.DeepEqual(tee.CategoryIDs, polo.CategoryIDs) {
+		t.Errorf("tee = %+v, polo = %+v, want both in the same category", tee, polo)
+	}
+	got, err := collections.NewMemoryRepository(store).GetCollection(summer.ID)
+	if err != nil {
+		t.Fatalf("GetCollection: %v", err)
+	}
+	if want := []int{productIDs[0], productIDs[2]}; !slices.Equal(got.ProductIDs, want) {
+		t.Errorf("summer products = %v, want %v", got.ProductIDs, want)
+	}
+}
+
+func TestImporterCreatesCollections(t *testing.T) {
+	store := memory.NewStore()
+	importer := NewImporter(memoryTransactor(store), WithCollectionCreation(), WithBatchSize(1))
+	rows := []Row{
+		{Line: 2, Name: "Tee", Collections: []string{"Summer"}},
+		{Line: 3, Name: "Polo", Collections: []string{"Summer"}},
+	}
+
+	for _, run := range []func([]Row) (*Report, error){importer.Check, importer.Import} {
+		report, err := run(rows)
+		if err != nil {
+			t.Fatalf("report: %v", err)
+		}
+		if got := report.Rows[0].NewCollections; !slices.Equal(got, []string{"Summer"}) {
+			t.Errorf("line 2 new collections = %v, want [Summer]", got)
+		}
+		if got := report.Rows[1].NewCollections; got != nil {
+			t.Errorf("line 3 new collections = %v, want none, line 2 creates it", got)
+		}
+	}
+
+	list := collections.NewMemoryRepository(store).GetCollections()
+	if len(list) != 1 || len(list[0].ProductIDs) != 2 {
+		t.Errorf("collections = %+v, want Summer holding both products", list)
+	}
+}
+
+func TestImporterRollsBackFailedMemoryBatch(t *testing.T) {
+	store := memory.NewStore()
+	summer, err := collections.NewMemoryRepository(store).CreateCollection(&collections.Collection{Name: "Summer"}, collections.NewPlacement())
+	if err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	// The collection disappears after the rows were checked and the first
+	// batch imported, so the second batch fails after creating its category.
+	calls := 0
+	importer := NewImporter(memoryTransactorWith(store, func(tx *memory.Store) error {
+		calls++
+		if calls == 3 {
+			_, err := collections.NewMemoryRepository(tx).DeleteCollection(summer.ID, collections.DeleteOptions{})
+			return err
+		}
+		return nil
+	}), WithBatchSize(2))
+
+	report, err := importer.Import([]Row{
+		{Line: 2, Name: "Tee", Categories: []string{"Shirts"}},
+		{Line: 3, Name: "Polo", Categories: []string{"Shirts"}},
+		{Line: 4, Name: "Shorts", Categories: []string{"Shorts"}, Collections: []string{"Summer"}},
+		{Line: 5, Name: "Cap"},
+	})
+	if err == nil {
+		t.Fatal("Import succeeded, want the second batch to fail")
+	}
+	if report.Imported != 2 || report.Failed != 2 {
+		t.Errorf("report = %+v, want 2 imported and 2 failed rows", report)
+	}
+
+	if got := len(products.NewMemoryRepository(store).GetProducts()); got != 2 {
+		t.Errorf("products = %d, want the 2 of the first batch", got)
+	}
+	var names []string
+	for _, category := range categories.NewMemoryRepository(store).GetCategories() {
+		names = append(names, category.Name)
+	}
+	if !slices.Equal(names, []string{"Shirts"}) {
+		t.Errorf("categories = %v, want only those of the first batch", names)
+	}
+	if got := collections.NewMemoryRepository(store).GetCollections(); len(got) != 1 {
+		t.Errorf("collections = %+v, want Summer, deleted in the failed batch, back", got)
+	}
+}
+
+func TestImporterRollsBackFailedBatch(t *testing.T) {
+	client, err := db.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	summer, err := collections.NewSQLiteRepository(client).CreateCollection(&collections.Collection{Name: "Summer"}, collections.NewPlacement())
+	if err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	// The collection disappears after the rows were checked and the first
+	// batch imported, so the second batch fails.
+	calls := 0
+	importer := NewImporter(sqliteTransactor(client, func(tx *db.Client) error {
+		calls++
+		if calls == 3 {
+			_, err := collections.NewSQLiteRepository(tx).DeleteCollection(summer.ID, collections.DeleteOptions{})
+			return err
+		}
+		return nil
+	}), WithBatchSize(2))
+
+	report, err := importer.Import([]Row{
+		{Line: 2, Name: "Tee", Categories: []string{"Shirts"}},
+		{Line: 3, Name: "Polo", Categories: []string{"Shirts"}},
+		{Line: 4, Name: "Shorts", Categories: []string{"Shorts"}, Collections: []string{"Summer"}},
+		{Line: 5, Name: "Cap"},
+	})
+	if err == nil {
+		t.Fatal("Import succeeded, want the second batch to fail")
+	}
+	var statuses []RowStatus
+	for _, row := range report.Rows {
+		statuses = append(statuses, row.Status)
+	}
+	if want := []RowStatus{RowImported, RowImported, RowFailed, RowFailed}; !slices.Equal(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+	if report.Imported != 2 || report.Failed != 2 {
+		t.Errorf("report = %+v, want 2 imported and 2 failed rows", report)
+	}
+
+	if got := len(products.NewSQLiteRepository(client).GetProducts()); got != 2 {
+		t.Errorf("products = %d, want the 2 of the first batch", got)
+	}
+	var names []string
+	for _, category := range categories.NewSQLiteRepository(client).GetCategories() {
+		names = append(names, category.Name)
+	}
+	if !slices.Equal(names, []string{"Shirts"}) {
+		t.Errorf("categories = %v, want only those of the first batch", names)
+	}
+}
+
+func TestImporterCheckDoesNotBlockWriters(t *testing.T) {
+	client, err := db.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	importer := NewImporter(sqliteTransactor(client, func(*db.Client) error { return nil }))
+
+	// A dry run while an import holds the write lock neither waits for it
+	// nor sees its uncommitted categories.
+	err = client.WithTx(context.Background(), func(writer *db.Client) error {
+		if _, err := categories.NewSQLiteRepository(writer).CreateCategory(&categories.Category{Name: "Shirts"}, categories.NewPlacement()); err != nil {
+			return err
+		}
+		report, err := importer.Check([]Row{{Line: 2, Name: "Tee", Categories: []string{"Shirts"}}})
+		if err != nil {
+			t.Fatalf("Check during an import: %v", err)
+		}
+		if got := report.Rows[0].NewCategories; !slices.Equal(got, []string{"Shirts"}) {
+			t.Errorf("new categories = %v, want [Shirts]", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+}
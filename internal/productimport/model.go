@@ -0,0 +1,54 @@
+// Package productimport loads products in bulk from CSV or NDJSON files,
+// creating the categories they name and adding them to existing collections.
+package productimport
+
+import "categories-test/internal/platform/validate"
+
+// Row is a product read from an import file. Categories lists category paths
+// such as "Clothing > Shirts", from the root down, and Collections names
+// existing collections.
+type Row struct {
+	Line        int
+	Name        string
+	Description string
+	Price       float64
+	Categories  []string
+	Collections []string
+	// Errors holds the problems found while parsing the row.
+	Errors []validate.FieldError
+}
+
+type RowStatus string
+
+const (
+	RowValid    RowStatus = "valid"
+	RowInvalid  RowStatus = "invalid"
+	RowImported RowStatus = "imported"
+	// RowFailed marks the rows of a batch that was rolled back.
+	RowFailed RowStatus = "failed"
+	// RowSkipped marks the rows after a failed batch, which were not tried.
+	RowSkipped RowStatus = "skipped"
+)
+
+// RowResult reports what became of a row. NewCategories lists the paths of
+// the categories the row created, or would create in a dry run, and
+// NewCollections the names of the collections.
+type RowResult struct {
+	Line           int
+	Status         RowStatus
+	ProductID      int
+	NewCategories  []string
+	NewCollections []string
+	Errors         []validate.FieldError
+}
+
+// Report lists the result of every row, in file order. Failed counts the
+// rows of a failed batch and those skipped after it.
+type Report struct {
+	DryRun   bool
+	Valid    int
+	Invalid  int
+	Imported int
+	Failed   int
+	Rows     []RowResult
+}
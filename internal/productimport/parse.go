@@ -0,0 +1,187 @@
+package productimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"categories-test/internal/platform/httpx"
+	"categories-test/internal/platform/validate"
+)
+
+const (
+	// MaxRows caps the number of products in one import.
+	MaxRows = 10000
+	// PathSeparator separates the levels of a category path.
+	PathSeparator = ">"
+	// ListSeparator separates the category paths and collection names of a
+	// CSV cell.
+	ListSeparator = "|"
+)
+
+var csvColumns = []string{"name", "description", "price", "categories", "collections"}
+
+// ParseCSV reads products from CSV with a header row naming its columns:
+// name, which is required, description, price, categories and collections.
+// Category paths and collection names are separated by ListSeparator.
+func ParseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: missing header row", ErrInvalidFile)
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("%w: unknown column %q, expected %s", ErrInvalidFile, name, strings.Join(csvColumns, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("%w: column %q appears more than once", ErrInvalidFile, name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("%w: missing name column", ErrInvalidFile)
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount):
+			rows = append(rows, Row{Line: parseErr.StartLine, Errors: []validate.FieldError{{
+				Field: "row", Code: "field_count", Message: fmt.Sprintf("has %d fields, want %d", len(record), len(header)),
+			}}})
+		case err != nil:
+			return nil, csvError(err)
+		default:
+			line, _ := reader.FieldPos(0)
+			rows = append(rows, csvRow(line, record, columns))
+		}
+		if len(rows) > MaxRows {
+			return nil, fmt.Errorf("%w: at most %d are allowed", ErrTooManyRows, MaxRows)
+		}
+	}
+	return checkRows(rows)
+}
+
+func csvRow(line int, record []string, columns map[string]int) Row {
+	cell := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	row := Row{
+		Line:        line,
+		Name:        cell("name"),
+		Description: cell("description"),
+		Categories:  splitList(cell("categories")),
+		Collections: splitList(cell("collections")),
+	}
+	if price := cell("price"); price != "" {
+		parsed, err := strconv.ParseFloat(price, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			row.Errors = append(row.Errors, validate.FieldError{Field: "price", Code: "invalid", Message: fmt.Sprintf("%q is not a number", price)})
+		}
+		row.Price = parsed
+	}
+	return row
+}
+
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return err
+}
+
+func splitList(cell string) []string {
+	var values []string
+	for _, value := range strings.Split(cell, ListSeparator) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+type ndjsonRow struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	Categories  []string `json:"categories"`
+	Collections []string `json:"collections"`
+}
+
+// ParseNDJSON reads products from newline-delimited JSON, one object per line
+// with the members name, description, price, categories and collections.
+// Blank lines are ignored.
+func ParseNDJSON(r io.Reader) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, httpx.MaxBodyBytes)
+
+	var rows []Row
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		rows = append(rows, ndjsonLine(line, scanner.Bytes()))
+		if len(rows) > MaxRows {
+			return nil, fmt.Errorf("%w: at most %d are allowed", ErrTooManyRows, MaxRows)
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return nil, fmt.Errorf("%w: lines must be at most %d bytes", ErrInvalidFile, httpx.MaxBodyBytes)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return checkRows(rows)
+}
+
+func ndjsonLine(line int, data []byte) Row {
+	var dto ndjsonRow
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&dto); err != nil {
+		return Row{Line: line, Errors: []validate.FieldError{{Field: "row", Code: "invalid_json", Message: err.Error()}}}
+	}
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return Row{Line: line, Errors: []validate.FieldError{{Field: "row", Code: "invalid_json", Message: "holds more than one JSON value"}}}
+	}
+	return Row{
+		Line:        line,
+		Name:        dto.Name,
+		Description: dto.Description,
+		Price:       dto.Price,
+		Categories:  dto.Categories,
+		Collections: dto.Collections,
+	}
+}
+
+func checkRows(rows []Row) ([]Row, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no products to import", ErrInvalidFile)
+	}
+	return rows, nil
+}
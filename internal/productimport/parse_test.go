@@ -0,0 +1,69 @@
+package productimport
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"categories-test/internal/platform/validate"
+)
+
+func TestParseCSV(t *testing.T) {
+	rows, err := ParseCSV(strings.NewReader("Name,price,categories,collections\n" +
+		"Tee,12.5,Clothing > Shirts | Sale,Summer\n" +
+		"\"Mug, large\",abc,,\n" +
+		"Cap,3\n"))
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	want := []Row{
+		{Line: 2, Name: "Tee", Price: 12.5, Categories: []string{"Clothing > Shirts", "Sale"}, Collections: []string{"Summer"}},
+		{Line: 3, Name: "Mug, large", Errors: []validate.FieldError{{Field: "price", Code: "invalid", Message: `"abc" is not a number`}}},
+		{Line: 4, Errors: []validate.FieldError{{Field: "row", Code: "field_count", Message: "has 2 fields, want 4"}}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %+v, want %+v", rows, want)
+	}
+}
+
+func TestParseCSVInvalidFile(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":            "",
+		"no rows":          "name\n",
+		"no name column":   "price\n1\n",
+		"unknown column":   "name,colour\nTee,red\n",
+		"repeated column":  "name,name\nTee,Tee\n",
+		"unbalanced quote": "name\n\"Tee\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseCSV(strings.NewReader(input)); !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("error = %v, want %v", err, ErrInvalidFile)
+			}
+		})
+	}
+}
+
+func TestParseNDJSON(t *testing.T) {
+	rows, err := ParseNDJSON(strings.NewReader(`{"name":"Tee","price":12.5,"categories":["Clothing > Shirts"],"collections":["Summer"]}
+
+{"name":"Mug","colour":"red"}
+{"name":"Cap"}}
+`))
+	if err != nil {
+		t.Fatalf("ParseNDJSON: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("rows = %+v, want 3", rows)
+	}
+	want := Row{Line: 1, Name: "Tee", Price: 12.5, Categories: []string{"Clothing > Shirts"}, Collections: []string{"Summer"}}
+	if !reflect.DeepEqual(rows[0], want) {
+		t.Errorf("rows[0] = %+v, want %+v", rows[0], want)
+	}
+	if rows[1].Line != 3 || len(rows[1].Errors) != 1 || rows[1].Errors[0].Code != "invalid_json" {
+		t.Errorf("rows[1] = %+v, want an invalid_json error on line 3", rows[1])
+	}
+	if rows[2].Line != 4 || len(rows[2].Errors) != 1 || rows[2].Errors[0].Code != "invalid_json" {
+		t.Errorf("rows[2] = %+v, want an invalid_json error for the trailing brace on line 4", rows[2])
+	}
+}
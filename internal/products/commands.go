@@ -1,5 +1,7 @@
 package products
 
+import "categories-test/internal/platform/validate"
+
 type Commands struct {
 	repo CommandRepository
 }
@@ -9,13 +11,47 @@ func NewCommands(repo CommandRepository) *Commands {
 }
 
 func (c *Commands) Create(product *Product) (*Product, error) {
+	if err := c.validate(product); err != nil {
+		return nil, err
+	}
 	return c.repo.CreateProduct(product)
 }
 
 func (c *Commands) Update(product *Product) (*Product, error) {
+	if err := c.validate(product); err != nil {
+		return nil, err
+	}
 	return c.repo.UpdateProduct(product)
 }
 
-func (c *Commands) Delete(id int) error {
-	return c.repo.DeleteProduct(id)
+func (c *Commands) Delete(id, version int) error {
+	return c.repo.DeleteProduct(id, version)
+}
+
+// LinkCategories adds categories to the product, keeping those it has.
+func (c *Commands) LinkCategories(id, version int, categoryIDs []int) (*Product, error) {
+	var errs validate.Errors
+	if err := errs.Links("categoryIds", categoryIDs, c.repo.MissingCategoryIDs); err != nil {
+		return nil, err
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return c.repo.UpdateProductCategories(CategoryLinks{ProductID: id, Add: categoryIDs, Version: version})
+}
+
+func (c *Commands) UnlinkCategory(id, version, categoryID int) (*Product, error) {
+	return c.repo.UpdateProductCategories(CategoryLinks{ProductID: id, Remove: []int{categoryID}, Version: version})
+}
+
+func (c *Commands) validate(product *Product) error {
+	var errs validate.Errors
+	errs.Name("name", product.Name)
+	if product.Price < 0 {
+		errs.Add("price", "negative", "must not be negative")
+	}
+	if err := errs.References("categoryIds", product.CategoryIDs, c.repo.MissingCategoryIDs); err != nil {
+		return err
+	}
+	return errs.Err()
 }
@@ -0,0 +1,58 @@
+package products
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/validate"
+)
+
+func TestCommandsValidate(t *testing.T) {
+	store := memory.NewStore()
+	store.Write(func(t *memory.Tables) error {
+		t.Categories[1] = &memory.CategoryRow{ID: 1, Name: "Shirts"}
+		return nil
+	})
+	commands := NewCommands(NewMemoryRepository(store))
+
+	tests := []struct {
+		name    string
+		product Product
+		want    []string
+	}{
+		{"valid", Product{Name: "Tee", Price: 10, CategoryIDs: []int{1}}, nil},
+		{"free", Product{Name: "Sticker"}, nil},
+		{"blank name", Product{Name: "  ", Price: 10}, []string{"name:required"}},
+		{"long name", Product{Name: strings.Repeat("a", validate.MaxNameLength+1)}, []string{"name:too_long"}},
+		{"negative price", Product{Name: "Tee", Price: -1}, []string{"price:negative"}},
+		{"unknown category", Product{Name: "Tee", CategoryIDs: []int{1, 404}}, []string{"categoryIds:not_found"}},
+		{"repeated category", Product{Name: "Tee", CategoryIDs: []int{1, 1}}, []string{"categoryIds:duplicate"}},
+		{"several fields", Product{Price: -5, CategoryIDs: []int{404}}, []string{"name:required", "price:negative", "categoryIds:not_found"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := tt.product
+			_, err := commands.Create(&product)
+			if got := fieldCodes(err); !slices.Equal(got, tt.want) {
+				t.Errorf("Create error = %v, want fields %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// fieldCodes lists the "field:code" pairs of a validation error in order, or
+// nil when err is not one.
+func fieldCodes(err error) []string {
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	codes := make([]string, 0, len(invalid.Fields))
+	for _, field := range invalid.Fields {
+		codes = append(codes, field.Field+":"+field.Code)
+	}
+	return codes
+}
@@ -2,4 +2,9 @@ package products
 
 import "errors"
 
-var ErrNotFound = errors.New("product not found")
+var (
+	ErrNotFound          = errors.New("product not found")
+	ErrInvalidCategory   = errors.New("product references unknown category")
+	ErrDuplicateCategory = errors.New("product lists a category more than once")
+	ErrVersionMismatch   = errors.New("product was modified since it was read")
+)
@@ -1,8 +1,8 @@
 package products
 
 import (
-	"errors"
 	"net/http"
+	"strings"
 
 	"categories-test/internal/platform/httpx"
 )
@@ -16,12 +16,56 @@ func NewHTTPHandler(commands *Commands, queries *Queries) *HTTPHandler {
 	return &HTTPHandler{commands: commands, queries: queries}
 }
 
+// errorMappings maps product errors to problem responses.
+var errorMappings = []httpx.ErrorMapping{
+	{Err: ErrNotFound, Status: http.StatusNotFound, Code: "product_not_found"},
+	{Err: ErrInvalidCategory, Status: http.StatusUnprocessableEntity, Code: "unknown_category", Field: "categoryIds"},
+	{Err: ErrDuplicateCategory, Status: http.StatusUnprocessableEntity, Code: "duplicate_category", Field: "categoryIds"},
+	{Err: ErrVersionMismatch, Status: http.StatusPreconditionFailed, Code: "version_mismatch"},
+}
+
 type productDTO struct {
 	ID          int     `json:"id"`
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
 	CategoryIDs []int   `json:"categoryIds"`
+	Version     int     `json:"version"`
+}
+
+type categoryLinksDTO struct {
+	CategoryIDs []int `json:"categoryIds"`
+}
+
+type searchResultDTO struct {
+	productDTO
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// productDetailDTO embeds the relations requested with ?expand. Relations
+// that were not requested are left out.
+type productDetailDTO struct {
+	productDTO
+	Categories  []refDTO `json:"categories,omitzero"`
+	Collections []refDTO `json:"collections,omitzero"`
+	Shops       []refDTO `json:"shops,omitzero"`
+}
+
+type refDTO struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func toRefDTOs(refs []Ref) []refDTO {
+	if refs == nil {
+		return nil
+	}
+	dtos := make([]refDTO, 0, len(refs))
+	for _, ref := range refs {
+		dtos = append(dtos, refDTO{ID: ref.ID, Name: ref.Name})
+	}
+	return dtos
 }
 
 func ensureIntSlice(value []int) []int {
@@ -38,6 +82,7 @@ func toProductDTO(p *Product) productDTO {
 		Description: p.Description,
 		Price:       p.Price,
 		CategoryIDs: ensureIntSlice(p.CategoryIDs),
+		Version:     p.Version,
 	}
 }
 
@@ -52,6 +97,11 @@ func fromProductDTO(dto productDTO) Product {
 }
 
 func (h *HTTPHandler) List(w http.ResponseWriter, r *http.Request) {
+	if httpx.WantsCursor(r.URL.Query()) {
+		h.listAfter(w, r)
+		return
+	}
+
 	products := h.queries.List()
 	response := make([]productDTO, 0, len(products))
 	for _, product := range products {
@@ -60,20 +110,90 @@ func (h *HTTPHandler) List(w http.ResponseWriter, r *http.Request) {
 	httpx.WriteJSON(w, response)
 }
 
+func (h *HTTPHandler) listAfter(w http.ResponseWriter, r *http.Request) {
+	cursor, err := httpx.ParseCursor(r.URL.Query())
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	products, hasMore := h.queries.ListAfter(cursor.After, cursor.Limit)
+	response := httpx.CursorList[productDTO]{Items: make([]productDTO, 0, len(products))}
+	for _, product := range products {
+		response.Items = append(response.Items, toProductDTO(product))
+	}
+	if len(products) > 0 {
+		response.NextCursor = httpx.NextCursor(products[len(products)-1].ID, hasMore)
+	}
+	httpx.WriteJSON(w, response)
+}
+
+func (h *HTTPHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	expand, err := httpx.ParseExpand(r.URL.Query(), "categories", "collections", "shops")
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	product, relations, err := h.queries.Get(id, Expand{
+		Categories:  expand["categories"],
+		Collections: expand["collections"],
+		Shops:       expand["shops"],
+	})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, product.Version)
+	httpx.WriteJSON(w, productDetailDTO{
+		productDTO:  toProductDTO(product),
+		Categories:  toRefDTOs(relations.Categories),
+		Collections: toRefDTOs(relations.Collections),
+		Shops:       toRefDTOs(relations.Shops),
+	})
+}
+
+func (h *HTTPHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if strings.TrimSpace(q) == "" {
+		httpx.WriteError(w, r, httpx.InvalidParam("q", "must not be empty"), errorMappings)
+		return
+	}
+
+	results := h.queries.Search(q, httpx.ParseLimit(r.URL.Query().Get("limit")))
+	response := make([]searchResultDTO, 0, len(results))
+	for _, result := range results {
+		response = append(response, searchResultDTO{
+			productDTO: toProductDTO(result.Product),
+			Score:      result.Score,
+			Snippet:    result.Snippet,
+		})
+	}
+	httpx.WriteJSON(w, response)
+}
+
 func (h *HTTPHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var payload productDTO
 	if err := httpx.ReadJSON(r, &payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	product := fromProductDTO(payload)
 	created, err := h.commands.Create(&product)
 	if err != nil {
-		http.Error(w, "Failed to persist product", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, created.Version)
 	w.WriteHeader(http.StatusCreated)
 	httpx.WriteJSON(w, toProductDTO(created))
 }
@@ -81,42 +201,156 @@ func (h *HTTPHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	var payload productDTO
 	if err := httpx.ReadJSON(r, &payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	product := fromProductDTO(payload)
 	product.ID = id
+	product.Version = version
 	updated, err := h.commands.Update(&product)
 	if err != nil {
-		http.Error(w, "Failed to persist product", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, updated.Version)
 	httpx.WriteJSON(w, toProductDTO(updated))
 }
 
+// Patch applies a JSON merge patch to the product, so fields the patch leaves
+// out keep their values.
+func (h *HTTPHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	current, _, err := h.queries.Get(id, Expand{})
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	payload := toProductDTO(current)
+	if err := httpx.ReadMergePatch(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	product := fromProductDTO(payload)
+	product.ID = id
+	product.Version = httpx.PatchVersion(version, current.Version)
+	updated, err := h.commands.Update(&product)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, updated.Version)
+	httpx.WriteJSON(w, toProductDTO(updated))
+}
+
+// LinkCategories adds categories to the product, keeping the ones it has.
+func (h *HTTPHandler) LinkCategories(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.OptionalIfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	var payload categoryLinksDTO
+	if err := httpx.ReadJSON(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	product, err := h.commands.LinkCategories(id, version, payload.CategoryIDs)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, product.Version)
+	httpx.WriteJSON(w, toProductDTO(product))
+}
+
+func (h *HTTPHandler) UnlinkCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	categoryID, err := httpx.ParseLinkedID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.OptionalIfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	product, err := h.commands.UnlinkCategory(id, version, categoryID)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, product.Version)
+	httpx.WriteJSON(w, toProductDTO(product))
+}
+
 func (h *HTTPHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
-	if err := h.commands.Delete(id); err != nil {
-		if errors.Is(err, ErrNotFound) {
-			http.Error(w, "Product not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to persist product", http.StatusInternalServerError)
+	if err := h.commands.Delete(id, version); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// currentVersion reads the product's version for an If-Match header listing
+// several entity tags.
+func (h *HTTPHandler) currentVersion(id int) func() (int, error) {
+	return func() (int, error) {
+		p, _, err := h.queries.Get(id, Expand{})
+		if err != nil {
+			return 0, err
+		}
+		return p.Version, nil
+	}
+}
@@ -0,0 +1,53 @@
+package products
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"categories-test/internal/platform/memory"
+)
+
+func TestHandlerRejectsStaleVersions(t *testing.T) {
+	repo := NewMemoryRepository(memory.NewStore())
+	handler := NewHTTPHandler(NewCommands(repo), NewQueries(repo))
+	if _, err := NewCommands(repo).Create(&Product{Name: "Tee", Price: 10}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	serve := func(method, ifMatch string, serve http.HandlerFunc) int {
+		body := strings.NewReader(`{"name": "Tee", "description": "", "price": 12, "categoryIds": []}`)
+		r := httptest.NewRequest(method, "/api/products/1", body)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		serve(rec, r)
+		return rec.Code
+	}
+
+	// The first admin saves, moving the product to version 2.
+	if got := serve(http.MethodPut, `"1"`, handler.Update); got != http.StatusOK {
+		t.Fatalf("PUT at the current version: status %d, want 200", got)
+	}
+	// A second admin still holding version 1 must not overwrite or delete it.
+	tests := []struct {
+		name    string
+		method  string
+		ifMatch string
+		serve   http.HandlerFunc
+		want    int
+	}{
+		{"stale update", http.MethodPut, `"1"`, handler.Update, http.StatusPreconditionFailed},
+		{"stale patch", http.MethodPatch, `"1"`, handler.Patch, http.StatusPreconditionFailed},
+		{"stale delete", http.MethodDelete, `"1"`, handler.Delete, http.StatusPreconditionFailed},
+		{"unversioned delete", http.MethodDelete, "", handler.Delete, http.StatusPreconditionRequired},
+		{"current delete", http.MethodDelete, `"2"`, handler.Delete, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		if got := serve(tt.method, tt.ifMatch, tt.serve); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
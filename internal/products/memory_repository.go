@@ -0,0 +1,260 @@
+package products
+
+import (
+	"sort"
+
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/search"
+)
+
+type MemoryRepository struct {
+	store *memory.Store
+}
+
+func NewMemoryRepository(store *memory.Store) *MemoryRepository {
+	return &MemoryRepository{store: store}
+}
+
+func (r *MemoryRepository) GetProducts() []*Product {
+	var products []*Product
+	r.store.Read(func(t *memory.Tables) {
+		products = make([]*Product, 0, len(t.Products))
+		for _, row := range t.Products {
+			products = append(products, toProduct(row))
+		}
+	})
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products
+}
+
+func (r *MemoryRepository) GetProductsAfter(after, limit int) []*Product {
+	return memory.PageAfter(r.GetProducts(), func(p *Product) int { return p.ID }, after, limit)
+}
+
+func (r *MemoryRepository) GetProduct(id int) (*Product, error) {
+	var product *Product
+	r.store.Read(func(t *memory.Tables) {
+		if row, ok := t.Products[id]; ok {
+			product = toProduct(row)
+		}
+	})
+	if product == nil {
+		return nil, ErrNotFound
+	}
+	return product, nil
+}
+
+func (r *MemoryRepository) GetProductRelations(id int, expand Expand) (*Relations, error) {
+	relations := &Relations{}
+	var err error
+	r.store.Read(func(t *memory.Tables) {
+		product, ok := t.Products[id]
+		if !ok {
+			err = ErrNotFound
+			return
+		}
+		if expand.Categories {
+			relations.Categories = make([]Ref, 0, len(product.CategoryIDs))
+			for _, categoryID := range product.CategoryIDs {
+				category, ok := t.Categories[categoryID]
+				if !ok {
+					continue
+				}
+				relations.Categories = append(relations.Categories, Ref{ID: categoryID, Name: category.Name})
+			}
+			sortRefs(relations.Categories)
+		}
+		if expand.Collections {
+			relations.Collections = make([]Ref, 0)
+			for _, c := range t.Collections {
+				if memory.ContainsInt(c.ProductIDs, id) {
+					relations.Collections = append(relations.Collections, Ref{ID: c.ID, Name: c.Name})
+				}
+			}
+			sortRefs(relations.Collections)
+		}
+		if expand.Shops {
+			relations.Shops = make([]Ref, 0)
+			for _, s := range t.Shops {
+				exposed := len(s.CollectionIDs) == 0
+				for _, collectionID := range s.CollectionIDs {
+					collection, ok := t.Collections[collectionID]
+					if ok && memory.ContainsInt(collection.ProductIDs, id) {
+						exposed = true
+						break
+					}
+				}
+				if exposed {
+					relations.Shops = append(relations.Shops, Ref{ID: s.ID, Name: s.Name})
+				}
+			}
+			sortRefs(relations.Shops)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
+
+func sortRefs(refs []Ref) {
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ID < refs[j].ID })
+}
+
+// SearchProducts highlights the name when it matches, the description
+// otherwise.
+func (r *MemoryRepository) SearchProducts(terms []string, limit int) []*SearchResult {
+	results := make([]*SearchResult, 0)
+	for _, p := range r.GetProducts() {
+		score, ok := search.Score(terms, p.Name, p.Description)
+		if !ok {
+			continue
+		}
+		result := &SearchResult{Product: p, Score: score, Snippet: search.Highlight(p.Description, terms)}
+		for _, term := range terms {
+			if search.Matches(p.Name, term) {
+				result.Snippet = search.Highlight(p.Name, terms)
+				break
+			}
+		}
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func (r *MemoryRepository) CreateProduct(p *Product) (*Product, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		if err := validateCategoryLinks(t, p.CategoryIDs); err != nil {
+			return err
+		}
+		p.ID = t.NextID("products")
+		p.Version = 1
+		t.Products[p.ID] = fromProduct(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *MemoryRepository) UpdateProduct(p *Product) (*Product, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Products[p.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, p.Version) {
+			return ErrVersionMismatch
+		}
+		if err := validateCategoryLinks(t, p.CategoryIDs); err != nil {
+			return err
+		}
+		p.Version = row.Version + 1
+		t.Products[p.ID] = fromProduct(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *MemoryRepository) DeleteProduct(id, version int) error {
+	return r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Products[id]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, version) {
+			return ErrVersionMismatch
+		}
+		delete(t.Products, id)
+		for _, c := range t.Collections {
+			c.ProductIDs = memory.RemoveInt(c.ProductIDs, id)
+		}
+		return nil
+	})
+}
+
+func (r *MemoryRepository) UpdateProductCategories(links CategoryLinks) (*Product, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Products[links.ProductID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, links.Version) {
+			return ErrVersionMismatch
+		}
+		for _, categoryID := range links.Add {
+			if _, ok := t.Categories[categoryID]; !ok {
+				return ErrInvalidCategory
+			}
+		}
+		for _, categoryID := range links.Add {
+			if !memory.ContainsInt(row.CategoryIDs, categoryID) {
+				row.CategoryIDs = append(row.CategoryIDs, categoryID)
+			}
+		}
+		for _, categoryID := range links.Remove {
+			row.CategoryIDs = memory.RemoveInt(row.CategoryIDs, categoryID)
+		}
+		row.Version++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetProduct(links.ProductID)
+}
+
+func (r *MemoryRepository) MissingCategoryIDs(ids []int) ([]int, error) {
+	var missing []int
+	r.store.Read(func(t *memory.Tables) {
+		missing = memory.MissingIDs(t.Categories, ids)
+	})
+	return missing, nil
+}
+
+func validateCategoryLinks(t *memory.Tables, categoryIDs []int) error {
+	if memory.HasDuplicates(categoryIDs) {
+		return ErrDuplicateCategory
+	}
+	for _, id := range categoryIDs {
+		if _, ok := t.Categories[id]; !ok {
+			return ErrInvalidCategory
+		}
+	}
+	return nil
+}
+
+func toProduct(row *memory.ProductRow) *Product {
+	categoryIDs := memory.SortedInts(row.CategoryIDs)
+	if categoryIDs == nil {
+		categoryIDs = []int{}
+	}
+	return &Product{
+		ID:          row.ID,
+		Name:        row.Name,
+		Description: row.Description,
+		Price:       row.Price,
+		CategoryIDs: categoryIDs,
+		Version:     row.Version,
+	}
+}
+
+func fromProduct(p *Product) *memory.ProductRow {
+	return &memory.ProductRow{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		CategoryIDs: append([]int{}, p.CategoryIDs...),
+		Version:     p.Version,
+	}
+}
@@ -0,0 +1,28 @@
+package products
+
+import (
+	"testing"
+
+	"categories-test/internal/platform/memory"
+)
+
+func TestGetProductRelationsSkipsMissingRows(t *testing.T) {
+	store := memory.NewStore()
+	store.Write(func(tables *memory.Tables) error {
+		tables.Categories[1] = &memory.CategoryRow{ID: 1, Name: "Hats"}
+		tables.Products[1] = &memory.ProductRow{ID: 1, Name: "Cap", CategoryIDs: []int{1, 2}}
+		tables.Shops[1] = &memory.ShopRow{ID: 1, Name: "Outlet", CollectionIDs: []int{3}}
+		return nil
+	})
+
+	relations, err := NewMemoryRepository(store).GetProductRelations(1, Expand{Categories: true, Shops: true})
+	if err != nil {
+		t.Fatalf("GetProductRelations: %v", err)
+	}
+	if len(relations.Categories) != 1 || relations.Categories[0].ID != 1 {
+		t.Fatalf("categories = %+v, want only category 1", relations.Categories)
+	}
+	if len(relations.Shops) != 0 {
+		t.Fatalf("shops = %+v, want none", relations.Shops)
+	}
+}
@@ -1,9 +1,50 @@
 package products
 
+// Product is a catalog item. Version counts its updates; when updating, it
+// holds the version the caller read, or zero to skip the check.
 type Product struct {
 	ID          int
 	Name        string
 	Description string
 	Price       float64
 	CategoryIDs []int
+	Version     int
+}
+
+// CategoryLinks links categories to a product and unlinks others, leaving
+// its remaining links alone. A non-zero Version must match the product's.
+type CategoryLinks struct {
+	ProductID int
+	Add       []int
+	Remove    []int
+	Version   int
+}
+
+// SearchResult is a product matching a search, with its relevance score
+// (higher is better) and an HTML snippet highlighting the matched words.
+type SearchResult struct {
+	Product *Product
+	Score   float64
+	Snippet string
+}
+
+// Expand selects the relations embedded in a single product.
+type Expand struct {
+	Categories  bool
+	Collections bool
+	Shops       bool
+}
+
+// Relations are the expanded relations of a product. Relations that were not
+// requested are nil.
+type Relations struct {
+	Categories  []Ref
+	Collections []Ref
+	Shops       []Ref
+}
+
+// Ref names a related resource.
+type Ref struct {
+	ID   int
+	Name string
 }
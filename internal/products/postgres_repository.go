@@ -0,0 +1,48 @@
+package products
+
+import (
+	"fmt"
+
+	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/search"
+)
+
+// PostgresRepository stores products in PostgreSQL.
+type PostgresRepository struct {
+	SQLRepository
+}
+
+func NewPostgresRepository(client *db.Client) *PostgresRepository {
+	return &PostgresRepository{SQLRepository{db: client}}
+}
+
+// SearchProducts ranks matches with ts_rank over the weighted search_vector
+// column maintained by a trigger.
+func (r *PostgresRepository) SearchProducts(terms []string, limit int) []*SearchResult {
+	query := search.TSQuery(terms)
+	categoriesByProduct, err := r.getProductCategoryMap(
+		`SELECT id FROM products, to_tsquery('simple', ?) q
+		WHERE search_vector @@ q
+		ORDER BY ts_rank(search_vector, q) DESC, id LIMIT ?`,
+		query, limit,
+	)
+	if err != nil {
+		categoriesByProduct = map[int][]int{}
+	}
+
+	headline := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=12, MinWords=4", search.StartMark, search.EndMark)
+	rows, err := r.db.Query(
+		`SELECT id, name, description, price, version,
+			ts_rank(search_vector, q),
+			ts_headline('simple', name || ' ' || description, q, ?)
+		FROM products, to_tsquery('simple', ?) q
+		WHERE search_vector @@ q
+		ORDER BY ts_rank(search_vector, q) DESC, id
+		LIMIT ?;`,
+		headline, query, limit,
+	)
+	if err != nil {
+		return []*SearchResult{}
+	}
+	return scanSearchResults(rows, categoriesByProduct)
+}
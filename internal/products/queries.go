@@ -1,5 +1,7 @@
 package products
 
+import "categories-test/internal/platform/search"
+
 type Queries struct {
 	repo QueryRepository
 }
@@ -11,3 +13,36 @@ func NewQueries(repo QueryRepository) *Queries {
 func (q *Queries) List() []*Product {
 	return q.repo.GetProducts()
 }
+
+// ListAfter returns up to limit products following the given ID and whether
+// more products follow them.
+func (q *Queries) ListAfter(after, limit int) ([]*Product, bool) {
+	items := q.repo.GetProductsAfter(after, limit+1)
+	if len(items) > limit {
+		return items[:limit], true
+	}
+	return items, false
+}
+
+// Get returns a product with the requested relations.
+func (q *Queries) Get(id int, expand Expand) (*Product, *Relations, error) {
+	product, err := q.repo.GetProduct(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	relations, err := q.repo.GetProductRelations(id, expand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return product, relations, nil
+}
+
+// Search returns up to limit products matching every word of q as a prefix,
+// best matches first.
+func (q *Queries) Search(query string, limit int) []*SearchResult {
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		return []*SearchResult{}
+	}
+	return q.repo.SearchProducts(terms, limit)
+}
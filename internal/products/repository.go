@@ -3,9 +3,22 @@ package products
 type CommandRepository interface {
 	CreateProduct(p *Product) (*Product, error)
 	UpdateProduct(p *Product) (*Product, error)
-	DeleteProduct(id int) error
+	// DeleteProduct deletes the product provided it is still at version, or
+	// whatever its version when version is zero.
+	DeleteProduct(id, version int) error
+	// UpdateProductCategories applies the link changes and returns the
+	// product. Linking a category twice or unlinking one that is not linked
+	// changes nothing.
+	UpdateProductCategories(links CategoryLinks) (*Product, error)
+	MissingCategoryIDs(ids []int) ([]int, error)
 }
 
 type QueryRepository interface {
 	GetProducts() []*Product
+	GetProductsAfter(after, limit int) []*Product
+	GetProduct(id int) (*Product, error)
+	// GetProductRelations lists the categories it is linked to, the
+	// collections holding it and the shops exposing it.
+	GetProductRelations(id int, expand Expand) (*Relations, error)
+	SearchProducts(terms []string, limit int) []*SearchResult
 }
@@ -0,0 +1,284 @@
+package products
+
+import (
+	"context"
+	"database/sql"
+
+	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/search"
+)
+
+var versionErrors = db.VersionErrors{NotFound: ErrNotFound, Mismatch: ErrVersionMismatch}
+
+type SQLRepository struct {
+	db *db.Client
+}
+
+func (r *SQLRepository) GetProducts() []*Product {
+	return r.queryProducts(`SELECT id FROM products`)
+}
+
+func (r *SQLRepository) GetProductsAfter(after, limit int) []*Product {
+	return r.queryProducts(`SELECT id FROM products WHERE id > ? ORDER BY id LIMIT ?`, after, limit)
+}
+
+func (r *SQLRepository) GetProduct(id int) (*Product, error) {
+	p := &Product{}
+	err := r.db.QueryRow(`SELECT id, name, description, price, version FROM products WHERE id = ?;`, id).
+		Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	categoriesByProduct, err := r.getProductCategoryMap(`SELECT id FROM products WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	p.CategoryIDs = categoriesByProduct[p.ID]
+	if p.CategoryIDs == nil {
+		p.CategoryIDs = []int{}
+	}
+	return p, nil
+}
+
+// GetProductRelations follows the shop rule used for listing products: a shop
+// exposes the products of its own collections, or every product when it has
+// no collections.
+func (r *SQLRepository) GetProductRelations(id int, expand Expand) (*Relations, error) {
+	relations := &Relations{}
+	var err error
+	if expand.Categories {
+		relations.Categories, err = r.queryRefs(
+			`SELECT c.id, c.name FROM categories c
+			JOIN product_categories pc ON pc.category_id = c.id
+			WHERE pc.product_id = ? ORDER BY c.id;`, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if expand.Collections {
+		relations.Collections, err = r.queryRefs(
+			`SELECT c.id, c.name FROM collections c
+			JOIN collection_products cp ON cp.collection_id = c.id
+			WHERE cp.product_id = ? ORDER BY c.id;`, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if expand.Shops {
+		relations.Shops, err = r.queryRefs(
+			`SELECT s.id, s.name FROM shops s
+			WHERE NOT EXISTS (SELECT 1 FROM shop_collections sc WHERE sc.shop_id = s.id)
+				OR EXISTS (
+					SELECT 1 FROM shop_collections sc
+					JOIN collection_products cp ON cp.collection_id = sc.collection_id
+					WHERE sc.shop_id = s.id AND cp.product_id = ?
+				)
+			ORDER BY s.id;`, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return relations, nil
+}
+
+func (r *SQLRepository) queryRefs(query string, args ...any) ([]Ref, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make([]Ref, 0)
+	for rows.Next() {
+		var ref Ref
+		if err := rows.Scan(&ref.ID, &ref.Name); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// queryProducts loads the products whose IDs are selected by ids, along with
+// their category links.
+func (r *SQLRepository) queryProducts(ids string, args ...any) []*Product {
+	categoriesByProduct, err := r.getProductCategoryMap(ids, args...)
+	if err != nil {
+		categoriesByProduct = map[int][]int{}
+	}
+
+	rows, err := r.db.Query(`SELECT id, name, description, price, version FROM products WHERE id IN (`+ids+`) ORDER BY id;`, args...)
+	if err != nil {
+		return []*Product{}
+	}
+	defer rows.Close()
+
+	products := make([]*Product, 0)
+	for rows.Next() {
+		p := &Product{}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Version); err != nil {
+			return []*Product{}
+		}
+		p.CategoryIDs = categoriesByProduct[p.ID]
+		if p.CategoryIDs == nil {
+			p.CategoryIDs = []int{}
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return []*Product{}
+	}
+
+	return products
+}
+
+func scanSearchResults(rows *sql.Rows, categoriesByProduct map[int][]int) []*SearchResult {
+	defer rows.Close()
+
+	results := make([]*SearchResult, 0)
+	for rows.Next() {
+		p := &Product{}
+		result := &SearchResult{Product: p}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Version, &result.Score, &result.Snippet); err != nil {
+			return []*SearchResult{}
+		}
+		p.CategoryIDs = categoriesByProduct[p.ID]
+		if p.CategoryIDs == nil {
+			p.CategoryIDs = []int{}
+		}
+		result.Snippet = search.Snippet(result.Snippet)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return []*SearchResult{}
+	}
+	return results
+}
+
+func (r *SQLRepository) CreateProduct(p *Product) (*Product, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := tx.QueryRow(
+			`INSERT INTO products(name, description, price) VALUES (?, ?, ?) RETURNING id, version;`,
+			p.Name, p.Description, p.Price,
+		).Scan(&p.ID, &p.Version); err != nil {
+			return err
+		}
+		return insertProductCategories(tx, p.ID, p.CategoryIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *SQLRepository) UpdateProduct(p *Product) (*Product, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		version, err := tx.BumpVersion("products", p.ID, p.Version, versionErrors)
+		if err != nil {
+			return err
+		}
+		p.Version = version
+		if _, err := tx.Exec(
+			`UPDATE products SET name = ?, description = ?, price = ? WHERE id = ?;`,
+			p.Name, p.Description, p.Price, p.ID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM product_categories WHERE product_id = ?;`, p.ID); err != nil {
+			return err
+		}
+		return insertProductCategories(tx, p.ID, p.CategoryIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *SQLRepository) DeleteProduct(id, version int) error {
+	return r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if _, err := tx.BumpVersion("products", id, version, versionErrors); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM products WHERE id = ?;`, id)
+		return err
+	})
+}
+
+func (r *SQLRepository) UpdateProductCategories(links CategoryLinks) (*Product, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if _, err := tx.BumpVersion("products", links.ProductID, links.Version, versionErrors); err != nil {
+			return err
+		}
+		for _, categoryID := range links.Add {
+			if _, err := tx.Exec(
+				`INSERT INTO product_categories(product_id, category_id) VALUES (?, ?) ON CONFLICT DO NOTHING;`,
+				links.ProductID, categoryID,
+			); err != nil {
+				if db.IsForeignKeyViolation(err) {
+					return ErrInvalidCategory
+				}
+				return err
+			}
+		}
+		if len(links.Remove) == 0 {
+			return nil
+		}
+		in, args := db.Placeholders(links.Remove)
+		_, err := tx.ExecDynamic(
+			`DELETE FROM product_categories WHERE product_id = ? AND category_id IN (`+in+`);`,
+			append([]any{links.ProductID}, args...)...,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetProduct(links.ProductID)
+}
+
+func (r *SQLRepository) MissingCategoryIDs(ids []int) ([]int, error) {
+	return r.db.MissingIDs("categories", ids)
+}
+
+func insertProductCategories(tx *db.Client, productID int, categoryIDs []int) error {
+	for _, categoryID := range categoryIDs {
+		if _, err := tx.Exec(`INSERT INTO product_categories(product_id, category_id) VALUES (?, ?);`, productID, categoryID); err != nil {
+			if db.IsForeignKeyViolation(err) {
+				return ErrInvalidCategory
+			}
+			if db.IsUniqueViolation(err) {
+				return ErrDuplicateCategory
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLRepository) getProductCategoryMap(ids string, args ...any) (map[int][]int, error) {
+	rows, err := r.db.Query(
+		`SELECT product_id, category_id FROM product_categories
+		WHERE product_id IN (`+ids+`)
+		ORDER BY product_id, category_id;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[int][]int)
+	for rows.Next() {
+		var pid, cid int
+		if err := rows.Scan(&pid, &cid); err != nil {
+			return nil, err
+		}
+		m[pid] = append(m[pid], cid)
+	}
+	return m, rows.Err()
+}
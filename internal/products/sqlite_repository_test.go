@@ -0,0 +1,149 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/db"
+)
+
+func newTestClient(t *testing.T) *db.Client {
+	t.Helper()
+	client, err := db.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func countRows(t *testing.T, client *db.Client, table string) int {
+	t.Helper()
+	var n int
+	if err := client.QueryRow(`SELECT COUNT(*) FROM ` + table + `;`).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func createCategory(t *testing.T, client *db.Client, name string) int {
+	t.Helper()
+	category, err := categories.NewSQLiteRepository(client).CreateCategory(&categories.Category{Name: name}, categories.NewPlacement())
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	return category.ID
+}
+
+func TestCreateProductFailedLinkInsertLeavesNoOrphan(t *testing.T) {
+	client := newTestClient(t)
+	repo := NewSQLiteRepository(client)
+	categoryID := createCategory(t, client, "Shirts")
+
+	// The duplicate category ID violates the product_categories primary key
+	// on the second link insert, after the product row has been written.
+	_, err := repo.CreateProduct(&Product{Name: "Shirt", Price: 10, CategoryIDs: []int{categoryID, categoryID}})
+	if err == nil {
+		t.Fatal("CreateProduct succeeded, want link insert error")
+	}
+
+	if got := countRows(t, client, "products"); got != 0 {
+		t.Fatalf("products = %d, want 0", got)
+	}
+	if got := countRows(t, client, "product_categories"); got != 0 {
+		t.Fatalf("product_categories = %d, want 0", got)
+	}
+}
+
+func TestUpdateProductFailedLinkInsertKeepsPreviousState(t *testing.T) {
+	client := newTestClient(t)
+	repo := NewSQLiteRepository(client)
+	shirts := createCategory(t, client, "Shirts")
+	sale := createCategory(t, client, "Sale")
+
+	created, err := repo.CreateProduct(&Product{Name: "Shirt", Price: 10, CategoryIDs: []int{shirts}})
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	_, err = repo.UpdateProduct(&Product{ID: created.ID, Name: "Renamed", Price: 12, CategoryIDs: []int{sale, sale}})
+	if err == nil {
+		t.Fatal("UpdateProduct succeeded, want link insert error")
+	}
+
+	got := repo.GetProducts()
+	if len(got) != 1 || got[0].Name != "Shirt" || len(got[0].CategoryIDs) != 1 || got[0].CategoryIDs[0] != shirts {
+		t.Fatalf("products after failed update = %+v, want original product", got)
+	}
+}
+
+func TestRepositoriesShareTransaction(t *testing.T) {
+	client := newTestClient(t)
+	errBoom := errors.New("boom")
+
+	err := client.WithTx(context.Background(), func(tx *db.Client) error {
+		category, err := categories.NewSQLiteRepository(tx).CreateCategory(&categories.Category{Name: "Shirts"}, categories.NewPlacement())
+		if err != nil {
+			return err
+		}
+		if _, err := NewSQLiteRepository(tx).CreateProduct(&Product{Name: "Shirt", CategoryIDs: []int{category.ID}}); err != nil {
+			return err
+		}
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("WithTx error = %v, want %v", err, errBoom)
+	}
+
+	for _, table := range []string{"categories", "products", "product_categories"} {
+		if got := countRows(t, client, table); got != 0 {
+			t.Fatalf("%s = %d, want 0", table, got)
+		}
+	}
+}
+
+func TestCreateProductUnknownCategory(t *testing.T) {
+	client := newTestClient(t)
+	repo := NewSQLiteRepository(client)
+
+	_, err := repo.CreateProduct(&Product{Name: "Shirt", Price: 10, CategoryIDs: []int{42}})
+	if !errors.Is(err, ErrInvalidCategory) {
+		t.Fatalf("CreateProduct error = %v, want %v", err, ErrInvalidCategory)
+	}
+	if got := countRows(t, client, "products"); got != 0 {
+		t.Fatalf("products = %d, want 0", got)
+	}
+}
+
+func TestUpdateProductNotFound(t *testing.T) {
+	repo := NewSQLiteRepository(newTestClient(t))
+
+	_, err := repo.UpdateProduct(&Product{ID: 42, Name: "Missing"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("UpdateProduct error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestDeleteProductRemovesCollectionLinks(t *testing.T) {
+	client := newTestClient(t)
+	repo := NewSQLiteRepository(client)
+
+	product, err := repo.CreateProduct(&Product{Name: "Shirt", Price: 10})
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	if _, err := collections.NewSQLiteRepository(client).CreateCollection(&collections.Collection{Name: "Summer", ProductIDs: []int{product.ID}}, collections.NewPlacement()); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	if err := repo.DeleteProduct(product.ID, product.Version); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	if got := countRows(t, client, "collection_products"); got != 0 {
+		t.Fatalf("collection_products = %d, want 0", got)
+	}
+}
@@ -0,0 +1,349 @@
+package repotest
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/platform/tree"
+)
+
+func RunCategories(t *testing.T, newRepos Factory) {
+	t.Run("CreateAndList", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		child := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+
+		got := repos.Categories.GetCategories()
+		if len(got) != 2 {
+			t.Fatalf("GetCategories returned %d categories, want 2", len(got))
+		}
+		if got[0].ID != root || got[0].Name != "Clothing" || got[0].ParentID != nil {
+			t.Errorf("root = %+v", got[0])
+		}
+		if got[1].ID != child || got[1].ParentID == nil || *got[1].ParentID != root {
+			t.Errorf("child = %+v", got[1])
+		}
+	})
+
+	t.Run("UnknownParent", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Categories.CreateCategory(&categories.Category{Name: "Orphan", ParentID: intPtr(404)}, categories.NewPlacement())
+		if !errors.Is(err, categories.ErrInvalidParent) {
+			t.Fatalf("CreateCategory error = %v, want %v", err, categories.ErrInvalidParent)
+		}
+	})
+
+	t.Run("Placement", func(t *testing.T) {
+		repos := newRepos(t)
+		placement := categories.NewPlacement(tree.WithMaxDepth(3))
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		child := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		leaf := mustCreateCategory(t, repos.Categories, "Tees", intPtr(child))
+		other := mustCreateCategory(t, repos.Categories, "Sale", nil)
+		mustCreateCategory(t, repos.Categories, "Outlet", intPtr(other))
+
+		if _, err := repos.Categories.MoveCategory(categories.Move{ID: root, ParentID: intPtr(leaf)}, placement); !errors.Is(err, categories.ErrCycle) {
+			t.Errorf("MoveCategory under a descendant: error = %v, want %v", err, categories.ErrCycle)
+		}
+		if _, err := repos.Categories.UpdateCategory(&categories.Category{ID: root, Name: "Clothing", ParentID: intPtr(leaf)}, placement); !errors.Is(err, categories.ErrCycle) {
+			t.Errorf("UpdateCategory under a descendant: error = %v, want %v", err, categories.ErrCycle)
+		}
+		if _, err := repos.Categories.CreateCategory(&categories.Category{Name: "Deep", ParentID: intPtr(leaf)}, placement); !errors.Is(err, categories.ErrTooDeep) {
+			t.Errorf("CreateCategory below the limit: error = %v, want %v", err, categories.ErrTooDeep)
+		}
+		if _, err := repos.Categories.MoveCategory(categories.Move{ID: other, ParentID: intPtr(child)}, placement); !errors.Is(err, categories.ErrTooDeep) {
+			t.Errorf("MoveCategory with a subtree below the limit: error = %v, want %v", err, categories.ErrTooDeep)
+		}
+		if _, err := repos.Categories.MoveCategory(categories.Move{ID: other, ParentID: intPtr(root)}, placement); err != nil {
+			t.Errorf("MoveCategory within the limit: %v", err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		id := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+
+		if _, err := repos.Categories.UpdateCategory(&categories.Category{ID: id, Name: "T-Shirts", ParentID: intPtr(root)}, categories.NewPlacement()); err != nil {
+			t.Fatalf("UpdateCategory: %v", err)
+		}
+		got := repos.Categories.GetCategories()
+		if got[1].Name != "T-Shirts" || got[1].ParentID == nil || *got[1].ParentID != root {
+			t.Errorf("category after update = %+v", got[1])
+		}
+
+		_, err := repos.Categories.UpdateCategory(&categories.Category{ID: 404, Name: "Missing"}, categories.NewPlacement())
+		if !errors.Is(err, categories.ErrNotFound) {
+			t.Fatalf("UpdateCategory error = %v, want %v", err, categories.ErrNotFound)
+		}
+	})
+
+	t.Run("DeleteRemovesDescendants", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		child := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		mustCreateCategory(t, repos.Categories, "Polos", intPtr(child))
+		other := mustCreateCategory(t, repos.Categories, "Shoes", nil)
+
+		if _, err := repos.Categories.DeleteCategory(root, categories.DeleteOptions{}); err != nil {
+			t.Fatalf("DeleteCategory: %v", err)
+		}
+		got := repos.Categories.GetCategories()
+		if len(got) != 1 || got[0].ID != other {
+			t.Fatalf("categories after delete = %+v, want only %d", got, other)
+		}
+	})
+
+	t.Run("DeleteInUse", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		child := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		mustCreateProduct(t, repos.Products, "Tee", 9.5, child)
+
+		if _, err := repos.Categories.DeleteCategory(child, categories.DeleteOptions{}); !errors.Is(err, categories.ErrCategoryInUse) {
+			t.Errorf("DeleteCategory(child) error = %v, want %v", err, categories.ErrCategoryInUse)
+		}
+		if _, err := repos.Categories.DeleteCategory(root, categories.DeleteOptions{}); !errors.Is(err, categories.ErrChildInUse) {
+			t.Errorf("DeleteCategory(root) error = %v, want %v", err, categories.ErrChildInUse)
+		}
+		if got := repos.Categories.GetCategories(); len(got) != 2 {
+			t.Errorf("categories after refused deletes = %+v, want both kept", got)
+		}
+	})
+
+	t.Run("DeleteReparentChildren", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		polos := mustCreateCategory(t, repos.Categories, "Polos", intPtr(shirts))
+		linen := mustCreateCategory(t, repos.Categories, "Linen", intPtr(shirts))
+		shoes := mustCreateCategory(t, repos.Categories, "Shoes", intPtr(root))
+		mustCreateProduct(t, repos.Products, "Polo", 20, polos)
+
+		opts := categories.DeleteOptions{Strategy: categories.DeleteReparentChildren}
+		result, err := repos.Categories.DeleteCategory(shirts, opts)
+		if err != nil || !equalInts(result.DeletedCategoryIDs, []int{shirts}) || !equalInts(result.ReparentedCategoryIDs, []int{polos, linen}) {
+			t.Fatalf("DeleteCategory = %+v, %v", result, err)
+		}
+		// The children take the deleted category's place among its siblings.
+		for _, c := range repos.Categories.GetCategories() {
+			want := map[int]int{root: 0, polos: 0, linen: 1, shoes: 2}[c.ID]
+			if c.ID != root && (c.ParentID == nil || *c.ParentID != root || c.Position != want) {
+				t.Errorf("category %d = %+v, want under %d at %d", c.ID, c, root, want)
+			}
+		}
+
+		if _, err := repos.Categories.DeleteCategory(polos, opts); !errors.Is(err, categories.ErrCategoryInUse) {
+			t.Errorf("DeleteCategory(polos) error = %v, want %v", err, categories.ErrCategoryInUse)
+		}
+	})
+
+	t.Run("DeleteReassignProducts", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		polos := mustCreateCategory(t, repos.Categories, "Polos", intPtr(shirts))
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10, shirts)
+		polo := mustCreateProduct(t, repos.Products, "Polo", 20, root, polos)
+
+		result, err := repos.Categories.DeleteCategory(shirts, categories.DeleteOptions{Strategy: categories.DeleteReassignProducts, ReassignTo: intPtr(root)})
+		if err != nil || !equalInts(result.DeletedCategoryIDs, []int{shirts, polos}) || !equalInts(result.ReassignedProductIDs, []int{tee, polo}) {
+			t.Fatalf("DeleteCategory = %+v, %v", result, err)
+		}
+		for _, id := range []int{tee, polo} {
+			if p, err := repos.Products.GetProduct(id); err != nil || !equalInts(p.CategoryIDs, []int{root}) {
+				t.Errorf("product %d after reassign = %+v, %v, want only category %d", id, p, err, root)
+			}
+		}
+	})
+
+	t.Run("DeleteReassignToInvalidTarget", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		polos := mustCreateCategory(t, repos.Categories, "Polos", intPtr(shirts))
+		mustCreateProduct(t, repos.Products, "Tee", 10, shirts)
+
+		for _, tt := range []struct {
+			to   int
+			want error
+		}{
+			{404, categories.ErrInvalidTarget},
+			{shirts, categories.ErrTargetDeleted},
+			{polos, categories.ErrTargetDeleted},
+		} {
+			_, err := repos.Categories.DeleteCategory(shirts, categories.DeleteOptions{Strategy: categories.DeleteReassignProducts, ReassignTo: intPtr(tt.to)})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("DeleteCategory reassigning to %d = %v, want %v", tt.to, err, tt.want)
+			}
+		}
+		if got := len(repos.Categories.GetCategories()); got != 3 {
+			t.Errorf("categories = %d, want all 3 kept", got)
+		}
+	})
+
+	t.Run("DeleteDetachProducts", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		shoes := mustCreateCategory(t, repos.Categories, "Shoes", nil)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10, shirts, shoes)
+
+		result, err := repos.Categories.DeleteCategory(root, categories.DeleteOptions{Strategy: categories.DeleteDetachProducts})
+		if err != nil || !equalInts(result.DeletedCategoryIDs, []int{root, shirts}) || !equalInts(result.DetachedProductIDs, []int{tee}) {
+			t.Fatalf("DeleteCategory = %+v, %v", result, err)
+		}
+		if p, err := repos.Products.GetProduct(tee); err != nil || !equalInts(p.CategoryIDs, []int{shoes}) {
+			t.Errorf("product after detach = %+v, %v", p, err)
+		}
+	})
+
+	t.Run("DeleteMissing", func(t *testing.T) {
+		repos := newRepos(t)
+		if _, err := repos.Categories.DeleteCategory(404, categories.DeleteOptions{}); !errors.Is(err, categories.ErrNotFound) {
+			t.Fatalf("DeleteCategory error = %v, want %v", err, categories.ErrNotFound)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		id := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+
+		updated, err := repos.Categories.UpdateCategory(&categories.Category{ID: id, Name: "T-Shirts", Version: 1}, categories.NewPlacement())
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("UpdateCategory = %+v, %v, want version 2", updated, err)
+		}
+		moved, err := repos.Categories.MoveCategory(categories.Move{ID: id, ParentID: intPtr(root), Version: 2}, categories.NewPlacement())
+		if err != nil || moved.Version != 3 {
+			t.Fatalf("MoveCategory = %+v, %v, want version 3", moved, err)
+		}
+		if _, err := repos.Categories.MoveCategory(categories.Move{ID: id, Version: 2}, categories.NewPlacement()); !errors.Is(err, categories.ErrVersionMismatch) {
+			t.Fatalf("stale MoveCategory error = %v, want %v", err, categories.ErrVersionMismatch)
+		}
+		_, err = repos.Categories.UpdateCategory(&categories.Category{ID: id, Name: "Stale", ParentID: intPtr(root), Version: 2}, categories.NewPlacement())
+		if !errors.Is(err, categories.ErrVersionMismatch) {
+			t.Fatalf("stale UpdateCategory error = %v, want %v", err, categories.ErrVersionMismatch)
+		}
+		if _, err := repos.Categories.DeleteCategory(id, categories.DeleteOptions{Version: 2}); !errors.Is(err, categories.ErrVersionMismatch) {
+			t.Fatalf("stale DeleteCategory error = %v, want %v", err, categories.ErrVersionMismatch)
+		}
+		if _, err := repos.Categories.DeleteCategory(id, categories.DeleteOptions{Version: 3}); err != nil {
+			t.Fatalf("DeleteCategory: %v", err)
+		}
+	})
+
+	t.Run("ListAfter", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		child := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		last := mustCreateCategory(t, repos.Categories, "Shoes", intPtr(root))
+
+		page := repos.Categories.GetCategoriesAfter(root, 1)
+		if len(page) != 1 || page[0].ID != child || page[0].ParentID == nil || *page[0].ParentID != root {
+			t.Fatalf("page after root = %+v, want [%d]", page, child)
+		}
+		if page = repos.Categories.GetCategoriesAfter(child, 10); len(page) != 1 || page[0].ID != last {
+			t.Fatalf("page after child = %+v, want [%d]", page, last)
+		}
+	})
+
+	t.Run("GetWithRelations", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		mustCreateCategory(t, repos.Categories, "Linen", intPtr(shirts))
+
+		got, err := repos.Categories.GetCategory(shirts)
+		if err != nil || got.Name != "Shirts" || got.ParentID == nil || *got.ParentID != root {
+			t.Fatalf("GetCategory = %+v, %v", got, err)
+		}
+		if _, err := repos.Categories.GetCategory(404); !errors.Is(err, categories.ErrNotFound) {
+			t.Errorf("GetCategory(404) error = %v, want %v", err, categories.ErrNotFound)
+		}
+
+		relations, err := repos.Categories.GetCategoryRelations(root, categories.Expand{Categories: true})
+		if err != nil || !slices.Equal(relations.Categories, []categories.Ref{{ID: shirts, Name: "Shirts"}}) {
+			t.Errorf("subcategories = %+v, %v, want only direct children", relations, err)
+		}
+		if relations, err = repos.Categories.GetCategoryRelations(root, categories.Expand{}); err != nil || relations.Categories != nil {
+			t.Errorf("unexpanded relations = %+v, %v", relations, err)
+		}
+	})
+
+	t.Run("Tree", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(root))
+		polos := mustCreateCategory(t, repos.Categories, "Polos", intPtr(shirts))
+		shoes := mustCreateCategory(t, repos.Categories, "Shoes", nil)
+		mustCreateProduct(t, repos.Products, "Tee", 10, shirts)
+		// Linked twice within the subtree, counted once.
+		mustCreateProduct(t, repos.Products, "Polo", 20, shirts, polos)
+
+		queries := categories.NewQueries(repos.Categories)
+		nodes, err := queries.Tree(nil)
+		if err != nil || len(nodes) != 2 || nodes[0].Category.ID != root || nodes[1].Category.ID != shoes {
+			t.Fatalf("Tree(nil) = %+v, %v, want the two roots", nodes, err)
+		}
+		if nodes[0].ProductCount != 2 || nodes[1].ProductCount != 0 {
+			t.Errorf("root product counts = %d, %d, want 2, 0", nodes[0].ProductCount, nodes[1].ProductCount)
+		}
+
+		nodes, err = queries.Tree(intPtr(shirts))
+		if err != nil || len(nodes) != 1 {
+			t.Fatalf("Tree(shirts) = %+v, %v", nodes, err)
+		}
+		node := nodes[0]
+		if node.Depth != 2 || !equalInts(node.Path, []int{root, shirts}) || node.ProductCount != 2 || len(node.Children) != 1 {
+			t.Errorf("shirts node = %+v", node)
+		}
+		if leaf := node.Children[0]; leaf.Category.ID != polos || leaf.Depth != 3 || leaf.ProductCount != 1 || len(leaf.Children) != 0 {
+			t.Errorf("polos node = %+v", leaf)
+		}
+
+		if _, err := queries.Tree(intPtr(404)); !errors.Is(err, categories.ErrNotFound) {
+			t.Errorf("Tree(404) error = %v, want %v", err, categories.ErrNotFound)
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCategory(t, repos.Categories, "Root", nil)
+		first := mustCreateCategory(t, repos.Categories, "First", intPtr(root))
+		second := mustCreateCategory(t, repos.Categories, "Second", intPtr(root))
+		third := mustCreateCategory(t, repos.Categories, "Third", intPtr(root))
+		positions := func() map[int]int {
+			result := make(map[int]int)
+			for _, item := range repos.Categories.GetCategories() {
+				result[item.ID] = item.Position
+			}
+			return result
+		}
+		if got := positions(); got[first] != 0 || got[second] != 1 || got[third] != 2 {
+			t.Fatalf("positions after create = %v, want siblings in creation order", got)
+		}
+
+		moved, err := repos.Categories.MoveCategory(categories.Move{ID: third, ParentID: intPtr(root), BeforeID: intPtr(first)}, categories.NewPlacement())
+		if err != nil || moved.Position != 0 {
+			t.Fatalf("MoveCategory before first = %+v, %v", moved, err)
+		}
+		if got := positions(); got[third] != 0 || got[first] != 1 || got[second] != 2 {
+			t.Errorf("positions after reorder = %v", got)
+		}
+
+		moved, err = repos.Categories.MoveCategory(categories.Move{ID: first, AfterID: intPtr(root)}, categories.NewPlacement())
+		if err != nil || moved.ParentID != nil || moved.Position != 1 {
+			t.Errorf("MoveCategory to roots = %+v, %v", moved, err)
+		}
+
+		if _, err := repos.Categories.MoveCategory(categories.Move{ID: second, ParentID: intPtr(root), BeforeID: intPtr(first)}, categories.NewPlacement()); !errors.Is(err, categories.ErrInvalidSibling) {
+			t.Errorf("MoveCategory next to a non-sibling error = %v, want %v", err, categories.ErrInvalidSibling)
+		}
+		if _, err := repos.Categories.MoveCategory(categories.Move{ID: 404}, categories.NewPlacement()); !errors.Is(err, categories.ErrNotFound) {
+			t.Errorf("MoveCategory(404) error = %v, want %v", err, categories.ErrNotFound)
+		}
+	})
+}
@@ -0,0 +1,370 @@
+package repotest
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/tree"
+)
+
+func RunCollections(t *testing.T, newRepos Factory) {
+	t.Run("CreateAndList", func(t *testing.T) {
+		repos := newRepos(t)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 9.5)
+		polo := mustCreateProduct(t, repos.Products, "Polo", 19)
+		root := mustCreateCollection(t, repos.Collections, "Summer", nil, polo, tee)
+		child := mustCreateCollection(t, repos.Collections, "Beach", intPtr(root))
+
+		got := repos.Collections.GetCollections()
+		if len(got) != 2 {
+			t.Fatalf("GetCollections returned %d collections, want 2", len(got))
+		}
+		if got[0].ID != root || got[0].Name != "Summer" || got[0].ParentID != nil || !equalInts(got[0].ProductIDs, []int{tee, polo}) {
+			t.Errorf("root = %+v", got[0])
+		}
+		if got[1].ID != child || got[1].ParentID == nil || *got[1].ParentID != root || len(got[1].ProductIDs) != 0 {
+			t.Errorf("child = %+v", got[1])
+		}
+	})
+
+	t.Run("InvalidReferences", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Collections.CreateCollection(&collections.Collection{Name: "Orphan", ParentID: intPtr(404)}, collections.NewPlacement())
+		if !errors.Is(err, collections.ErrInvalidParent) {
+			t.Errorf("CreateCollection with unknown parent error = %v, want %v", err, collections.ErrInvalidParent)
+		}
+		_, err = repos.Collections.CreateCollection(&collections.Collection{Name: "Ghost", ProductIDs: []int{404}}, collections.NewPlacement())
+		if !errors.Is(err, collections.ErrInvalidProduct) {
+			t.Errorf("CreateCollection with unknown product error = %v, want %v", err, collections.ErrInvalidProduct)
+		}
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10)
+		_, err = repos.Collections.CreateCollection(&collections.Collection{Name: "Twice", ProductIDs: []int{tee, tee}}, collections.NewPlacement())
+		if !errors.Is(err, collections.ErrDuplicateProduct) {
+			t.Errorf("CreateCollection with a repeated product error = %v, want %v", err, collections.ErrDuplicateProduct)
+		}
+		if got := repos.Collections.GetCollections(); len(got) != 0 {
+			t.Errorf("GetCollections = %+v, want no collections", got)
+		}
+	})
+
+	t.Run("Placement", func(t *testing.T) {
+		repos := newRepos(t)
+		placement := collections.NewPlacement(tree.WithMaxDepth(3))
+		root := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		child := mustCreateCollection(t, repos.Collections, "Beach", intPtr(root))
+		leaf := mustCreateCollection(t, repos.Collections, "Towels", intPtr(child))
+		other := mustCreateCollection(t, repos.Collections, "Winter", nil)
+		mustCreateCollection(t, repos.Collections, "Ski", intPtr(other))
+
+		if _, err := repos.Collections.MoveCollection(collections.Move{ID: root, ParentID: intPtr(leaf)}, placement); !errors.Is(err, collections.ErrCycle) {
+			t.Errorf("MoveCollection under a descendant: error = %v, want %v", err, collections.ErrCycle)
+		}
+		if _, err := repos.Collections.UpdateCollection(&collections.Collection{ID: root, Name: "Summer", ParentID: intPtr(leaf)}, placement); !errors.Is(err, collections.ErrCycle) {
+			t.Errorf("UpdateCollection under a descendant: error = %v, want %v", err, collections.ErrCycle)
+		}
+		if _, err := repos.Collections.CreateCollection(&collections.Collection{Name: "Deep", ParentID: intPtr(leaf)}, placement); !errors.Is(err, collections.ErrTooDeep) {
+			t.Errorf("CreateCollection below the limit: error = %v, want %v", err, collections.ErrTooDeep)
+		}
+		if _, err := repos.Collections.MoveCollection(collections.Move{ID: other, ParentID: intPtr(child)}, placement); !errors.Is(err, collections.ErrTooDeep) {
+			t.Errorf("MoveCollection with a subtree below the limit: error = %v, want %v", err, collections.ErrTooDeep)
+		}
+		if _, err := repos.Collections.MoveCollection(collections.Move{ID: other, ParentID: intPtr(root)}, placement); err != nil {
+			t.Errorf("MoveCollection within the limit: %v", err)
+		}
+	})
+
+	t.Run("UpdateReplacesLinks", func(t *testing.T) {
+		repos := newRepos(t)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 9.5)
+		polo := mustCreateProduct(t, repos.Products, "Polo", 19)
+		id := mustCreateCollection(t, repos.Collections, "Summer", nil, tee)
+
+		if _, err := repos.Collections.UpdateCollection(&collections.Collection{ID: id, Name: "Summer Sale", ProductIDs: []int{polo}}, collections.NewPlacement()); err != nil {
+			t.Fatalf("UpdateCollection: %v", err)
+		}
+		got := repos.Collections.GetCollections()
+		if got[0].Name != "Summer Sale" || !equalInts(got[0].ProductIDs, []int{polo}) {
+			t.Errorf("collection after update = %+v", got[0])
+		}
+
+		_, err := repos.Collections.UpdateCollection(&collections.Collection{ID: 404, Name: "Missing"}, collections.NewPlacement())
+		if !errors.Is(err, collections.ErrNotFound) {
+			t.Fatalf("UpdateCollection error = %v, want %v", err, collections.ErrNotFound)
+		}
+	})
+
+	t.Run("DeleteDetachesShops", func(t *testing.T) {
+		repos := newRepos(t)
+		id := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		shopID := mustCreateShop(t, repos.Shops, "Outlet", id)
+
+		if _, err := repos.Collections.DeleteCollection(id, collections.DeleteOptions{}); err != nil {
+			t.Fatalf("DeleteCollection: %v", err)
+		}
+		if got := repos.Collections.GetCollections(); len(got) != 0 {
+			t.Fatalf("GetCollections = %+v, want no collections", got)
+		}
+		shop, err := repos.Shops.GetShop(shopID)
+		if err != nil {
+			t.Fatalf("GetShop: %v", err)
+		}
+		if len(shop.CollectionIDs) != 0 {
+			t.Errorf("shop still links deleted collection: %v", shop.CollectionIDs)
+		}
+	})
+
+	t.Run("DeleteWithChildren", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		mustCreateCollection(t, repos.Collections, "Beach", intPtr(root))
+
+		if _, err := repos.Collections.DeleteCollection(root, collections.DeleteOptions{}); !errors.Is(err, collections.ErrHasChildren) {
+			t.Fatalf("DeleteCollection error = %v, want %v", err, collections.ErrHasChildren)
+		}
+	})
+
+	t.Run("DeleteCascade", func(t *testing.T) {
+		repos := newRepos(t)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		beach := mustCreateCollection(t, repos.Collections, "Beach", intPtr(summer))
+		towels := mustCreateCollection(t, repos.Collections, "Towels", intPtr(beach))
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil)
+		surf := mustCreateShop(t, repos.Shops, "Surf", towels, winter)
+		mustCreateShop(t, repos.Shops, "Ski", winter)
+
+		result, err := repos.Collections.DeleteCollection(summer, collections.DeleteOptions{Mode: collections.DeleteCascade})
+		if err != nil || !equalInts(result.DeletedCollectionIDs, []int{summer, beach, towels}) {
+			t.Fatalf("DeleteCollection = %+v, %v", result, err)
+		}
+		if !slices.Equal(result.AffectedShops, []collections.Ref{{ID: surf, Name: "Surf"}}) {
+			t.Errorf("affected shops = %+v, want only Surf", result.AffectedShops)
+		}
+		if got := repos.Collections.GetCollections(); len(got) != 1 || got[0].ID != winter {
+			t.Errorf("collections after cascade = %+v, want only %d", got, winter)
+		}
+		if shop, err := repos.Shops.GetShop(surf); err != nil || !equalInts(shop.CollectionIDs, []int{winter}) {
+			t.Errorf("shop after cascade = %+v, %v", shop, err)
+		}
+	})
+
+	t.Run("DeleteLastShopCollection", func(t *testing.T) {
+		repos := newRepos(t)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil)
+		surf := mustCreateShop(t, repos.Shops, "Surf", summer, winter)
+		beach := mustCreateShop(t, repos.Shops, "Beach", summer)
+
+		result, err := repos.Collections.DeleteCollection(summer, collections.DeleteOptions{})
+		if err != nil {
+			t.Fatalf("DeleteCollection: %v", err)
+		}
+		if want := []collections.Ref{{ID: surf, Name: "Surf"}, {ID: beach, Name: "Beach"}}; !slices.Equal(result.AffectedShops, want) {
+			t.Errorf("affected shops = %+v, want %+v", result.AffectedShops, want)
+		}
+		// Beach lost its only collection, so it now exposes every product.
+		if want := []collections.Ref{{ID: beach, Name: "Beach"}}; !slices.Equal(result.ShopsNowExposingAll, want) {
+			t.Errorf("shops now exposing all = %+v, want %+v", result.ShopsNowExposingAll, want)
+		}
+	})
+
+	t.Run("DeleteReparent", func(t *testing.T) {
+		repos := newRepos(t)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		beach := mustCreateCollection(t, repos.Collections, "Beach", nil)
+		towels := mustCreateCollection(t, repos.Collections, "Towels", intPtr(beach))
+		hats := mustCreateCollection(t, repos.Collections, "Hats", intPtr(beach))
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil)
+		mustCreateShop(t, repos.Shops, "Outlet", beach)
+
+		result, err := repos.Collections.DeleteCollection(beach, collections.DeleteOptions{Mode: collections.DeleteReparent})
+		if err != nil || !equalInts(result.DeletedCollectionIDs, []int{beach}) || !equalInts(result.ReparentedCollectionIDs, []int{towels, hats}) || len(result.AffectedShops) != 1 {
+			t.Fatalf("DeleteCollection = %+v, %v", result, err)
+		}
+		// The children take the deleted collection's place among the roots.
+		want := map[int]int{summer: 0, towels: 1, hats: 2, winter: 3}
+		for _, c := range repos.Collections.GetCollections() {
+			if c.ParentID != nil || c.Position != want[c.ID] {
+				t.Errorf("collection %d = %+v, want a root at %d", c.ID, c, want[c.ID])
+			}
+		}
+	})
+
+	t.Run("DeleteMissing", func(t *testing.T) {
+		repos := newRepos(t)
+		if _, err := repos.Collections.DeleteCollection(404, collections.DeleteOptions{}); !errors.Is(err, collections.ErrNotFound) {
+			t.Fatalf("DeleteCollection error = %v, want %v", err, collections.ErrNotFound)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCollection(t, repos.Collections, "Featured", nil)
+		id := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		child := mustCreateCollection(t, repos.Collections, "Beach", intPtr(id))
+
+		updated, err := repos.Collections.UpdateCollection(&collections.Collection{ID: id, Name: "Summer Sale", Version: 1}, collections.NewPlacement())
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("UpdateCollection = %+v, %v, want version 2", updated, err)
+		}
+		moved, err := repos.Collections.MoveCollection(collections.Move{ID: id, ParentID: intPtr(root), Version: 2}, collections.NewPlacement())
+		if err != nil || moved.Version != 3 {
+			t.Fatalf("MoveCollection = %+v, %v, want version 3", moved, err)
+		}
+		if _, err := repos.Collections.MoveCollection(collections.Move{ID: id, Version: 2}, collections.NewPlacement()); !errors.Is(err, collections.ErrVersionMismatch) {
+			t.Fatalf("stale MoveCollection error = %v, want %v", err, collections.ErrVersionMismatch)
+		}
+		_, err = repos.Collections.UpdateCollection(&collections.Collection{ID: id, Name: "Stale", ParentID: intPtr(root), Version: 2}, collections.NewPlacement())
+		if !errors.Is(err, collections.ErrVersionMismatch) {
+			t.Fatalf("stale UpdateCollection error = %v, want %v", err, collections.ErrVersionMismatch)
+		}
+		opts := collections.DeleteOptions{Mode: collections.DeleteReparent, Version: 2}
+		if _, err := repos.Collections.DeleteCollection(id, opts); !errors.Is(err, collections.ErrVersionMismatch) {
+			t.Fatalf("stale DeleteCollection error = %v, want %v", err, collections.ErrVersionMismatch)
+		}
+		opts.Version = 3
+		if _, err := repos.Collections.DeleteCollection(id, opts); err != nil {
+			t.Fatalf("DeleteCollection: %v", err)
+		}
+		// Reparenting changes the children, so they move to a new version too.
+		if got, err := repos.Collections.GetCollection(child); err != nil || got.Version != 2 {
+			t.Errorf("child after reparent = %+v, %v, want version 2", got, err)
+		}
+	})
+
+	t.Run("AddAndRemoveProducts", func(t *testing.T) {
+		repos := newRepos(t)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10)
+		polo := mustCreateProduct(t, repos.Products, "Polo", 20)
+		id := mustCreateCollection(t, repos.Collections, "Summer", nil, tee)
+
+		got, err := repos.Collections.UpdateCollectionProducts(collections.ProductLinks{CollectionID: id, Add: []int{polo, tee}, Version: 1})
+		if err != nil || !equalInts(got.ProductIDs, []int{tee, polo}) || got.Version != 2 {
+			t.Fatalf("UpdateCollectionProducts(add) = %+v, %v, want both products at version 2", got, err)
+		}
+		got, err = repos.Collections.UpdateCollectionProducts(collections.ProductLinks{CollectionID: id, Remove: []int{tee}})
+		if err != nil || !equalInts(got.ProductIDs, []int{polo}) {
+			t.Fatalf("UpdateCollectionProducts(remove) = %+v, %v, want only %d", got, err, polo)
+		}
+		if _, err := repos.Collections.UpdateCollectionProducts(collections.ProductLinks{CollectionID: id, Add: []int{404}}); !errors.Is(err, collections.ErrInvalidProduct) {
+			t.Errorf("adding an unknown product error = %v, want %v", err, collections.ErrInvalidProduct)
+		}
+		if _, err := repos.Collections.UpdateCollectionProducts(collections.ProductLinks{CollectionID: 404, Add: []int{tee}}); !errors.Is(err, collections.ErrNotFound) {
+			t.Errorf("adding to a missing collection error = %v, want %v", err, collections.ErrNotFound)
+		}
+	})
+
+	t.Run("ListAfter", func(t *testing.T) {
+		repos := newRepos(t)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		beach := mustCreateCollection(t, repos.Collections, "Beach", intPtr(summer), tee)
+		mustCreateCollection(t, repos.Collections, "Winter", nil)
+
+		page := repos.Collections.GetCollectionsAfter(summer, 1)
+		if len(page) != 1 || page[0].ID != beach {
+			t.Fatalf("page after summer = %+v, want [%d]", page, beach)
+		}
+		if !equalInts(page[0].ProductIDs, []int{tee}) {
+			t.Errorf("beach products = %v, want %v", page[0].ProductIDs, []int{tee})
+		}
+	})
+
+	t.Run("GetWithRelations", func(t *testing.T) {
+		repos := newRepos(t)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		beach := mustCreateCollection(t, repos.Collections, "Beach", intPtr(summer), tee)
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil)
+		outlet := mustCreateShop(t, repos.Shops, "Outlet", summer)
+		mustCreateShop(t, repos.Shops, "Ski", winter)
+		everything := mustCreateShop(t, repos.Shops, "Everything")
+
+		got, err := repos.Collections.GetCollection(beach)
+		if err != nil || got.Name != "Beach" || !equalInts(got.ProductIDs, []int{tee}) {
+			t.Fatalf("GetCollection = %+v, %v", got, err)
+		}
+		if _, err := repos.Collections.GetCollection(404); !errors.Is(err, collections.ErrNotFound) {
+			t.Errorf("GetCollection(404) error = %v, want %v", err, collections.ErrNotFound)
+		}
+
+		relations, err := repos.Collections.GetCollectionRelations(summer, collections.Expand{Collections: true})
+		if err != nil || !slices.Equal(relations.Collections, []collections.Ref{{ID: beach, Name: "Beach"}}) || relations.Shops != nil {
+			t.Errorf("subcollections = %+v, %v", relations, err)
+		}
+
+		// Shops expose the descendants of their collections.
+		relations, err = repos.Collections.GetCollectionRelations(beach, collections.Expand{Shops: true})
+		if want := []collections.Ref{{ID: outlet, Name: "Outlet"}, {ID: everything, Name: "Everything"}}; err != nil || !slices.Equal(relations.Shops, want) {
+			t.Errorf("shops = %+v, %v, want %+v", relations.Shops, err, want)
+		}
+	})
+
+	t.Run("Tree", func(t *testing.T) {
+		repos := newRepos(t)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10)
+		towel := mustCreateProduct(t, repos.Products, "Towel", 5)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil, tee)
+		beach := mustCreateCollection(t, repos.Collections, "Beach", intPtr(summer), tee, towel)
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil)
+
+		queries := collections.NewQueries(repos.Collections)
+		nodes, err := queries.Tree(nil)
+		if err != nil || len(nodes) != 2 || nodes[0].Collection.ID != summer || nodes[1].Collection.ID != winter {
+			t.Fatalf("Tree(nil) = %+v, %v, want the two roots", nodes, err)
+		}
+		// Only a collection's own products count.
+		if nodes[0].ProductCount != 1 || len(nodes[0].Children) != 1 {
+			t.Errorf("summer node = %+v", nodes[0])
+		}
+		if child := nodes[0].Children[0]; child.Collection.ID != beach || child.Depth != 2 || !equalInts(child.Path, []int{summer, beach}) || child.ProductCount != 2 {
+			t.Errorf("beach node = %+v", child)
+		}
+
+		if nodes, err = queries.Tree(intPtr(beach)); err != nil || len(nodes) != 1 || nodes[0].Collection.ID != beach {
+			t.Errorf("Tree(beach) = %+v, %v", nodes, err)
+		}
+		if _, err := queries.Tree(intPtr(404)); !errors.Is(err, collections.ErrNotFound) {
+			t.Errorf("Tree(404) error = %v, want %v", err, collections.ErrNotFound)
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		repos := newRepos(t)
+		root := mustCreateCollection(t, repos.Collections, "Root", nil)
+		first := mustCreateCollection(t, repos.Collections, "First", intPtr(root))
+		second := mustCreateCollection(t, repos.Collections, "Second", intPtr(root))
+		third := mustCreateCollection(t, repos.Collections, "Third", intPtr(root))
+		positions := func() map[int]int {
+			result := make(map[int]int)
+			for _, item := range repos.Collections.GetCollections() {
+				result[item.ID] = item.Position
+			}
+			return result
+		}
+		if got := positions(); got[first] != 0 || got[second] != 1 || got[third] != 2 {
+			t.Fatalf("positions after create = %v, want siblings in creation order", got)
+		}
+
+		moved, err := repos.Collections.MoveCollection(collections.Move{ID: third, ParentID: intPtr(root), BeforeID: intPtr(first)}, collections.NewPlacement())
+		if err != nil || moved.Position != 0 {
+			t.Fatalf("MoveCollection before first = %+v, %v", moved, err)
+		}
+		if got := positions(); got[third] != 0 || got[first] != 1 || got[second] != 2 {
+			t.Errorf("positions after reorder = %v", got)
+		}
+
+		moved, err = repos.Collections.MoveCollection(collections.Move{ID: first, AfterID: intPtr(root)}, collections.NewPlacement())
+		if err != nil || moved.ParentID != nil || moved.Position != 1 {
+			t.Errorf("MoveCollection to roots = %+v, %v", moved, err)
+		}
+
+		if _, err := repos.Collections.MoveCollection(collections.Move{ID: second, ParentID: intPtr(root), BeforeID: intPtr(first)}, collections.NewPlacement()); !errors.Is(err, collections.ErrInvalidSibling) {
+			t.Errorf("MoveCollection next to a non-sibling error = %v, want %v", err, collections.ErrInvalidSibling)
+		}
+		if _, err := repos.Collections.MoveCollection(collections.Move{ID: 404}, collections.NewPlacement()); !errors.Is(err, collections.ErrNotFound) {
+			t.Errorf("MoveCollection(404) error = %v, want %v", err, collections.ErrNotFound)
+		}
+	})
+}
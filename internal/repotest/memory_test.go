@@ -0,0 +1,25 @@
+package repotest
+
+import (
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+	"categories-test/internal/snapshot"
+)
+
+func TestMemoryRepositories(t *testing.T) {
+	Run(t, func(t *testing.T) Repositories {
+		store := memory.NewStore()
+		return Repositories{
+			Products:    products.NewMemoryRepository(store),
+			Categories:  categories.NewMemoryRepository(store),
+			Collections: collections.NewMemoryRepository(store),
+			Shops:       shops.NewMemoryRepository(store),
+			Snapshots:   snapshot.NewMemoryRepository(store),
+		}
+	})
+}
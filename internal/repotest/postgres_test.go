@@ -0,0 +1,65 @@
+package repotest
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/db"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+	"categories-test/internal/snapshot"
+)
+
+var postgresSchemaSeq atomic.Int64
+
+// TestPostgresRepositories runs the conformance suites against the database
+// named by POSTGRES_TEST_URL, which needs the pg_trgm extension. Every
+// factory call gets its own schema, which is dropped when the test finishes;
+// public stays on the search path for the extension's operators.
+func TestPostgresRepositories(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_URL")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_URL not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	Run(t, func(t *testing.T) Repositories {
+		schema := fmt.Sprintf("repotest_%d_%d", os.Getpid(), postgresSchemaSeq.Add(1))
+		if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+			t.Fatalf("create schema: %v", err)
+		}
+		t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatalf("parse POSTGRES_TEST_URL: %v", err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema+",public")
+		u.RawQuery = q.Encode()
+
+		client, err := db.OpenPostgres(u.String())
+		if err != nil {
+			t.Fatalf("open postgres: %v", err)
+		}
+		t.Cleanup(func() { client.Close() })
+		return Repositories{
+			Products:    products.NewPostgresRepository(client),
+			Categories:  categories.NewPostgresRepository(client),
+			Collections: collections.NewPostgresRepository(client),
+			Shops:       shops.NewPostgresRepository(client),
+			Snapshots:   snapshot.NewPostgresRepository(client),
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package repotest
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"categories-test/internal/products"
+)
+
+func RunProducts(t *testing.T, newRepos Factory) {
+	t.Run("CreateAndList", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		sale := mustCreateCategory(t, repos.Categories, "Sale", nil)
+
+		first := mustCreateProduct(t, repos.Products, "Tee", 9.5, sale, shirts)
+		second := mustCreateProduct(t, repos.Products, "Polo", 19)
+		if first == second {
+			t.Fatalf("products share ID %d", first)
+		}
+
+		got := repos.Products.GetProducts()
+		if len(got) != 2 {
+			t.Fatalf("GetProducts returned %d products, want 2", len(got))
+		}
+		if got[0].ID != first || got[0].Name != "Tee" || got[0].Description != "Tee description" || got[0].Price != 9.5 {
+			t.Errorf("first product = %+v", got[0])
+		}
+		if !equalInts(got[0].CategoryIDs, []int{shirts, sale}) {
+			t.Errorf("first product categories = %v, want %v", got[0].CategoryIDs, []int{shirts, sale})
+		}
+		if got[1].ID != second || got[1].CategoryIDs == nil || len(got[1].CategoryIDs) != 0 {
+			t.Errorf("second product = %+v, want empty non-nil categories", got[1])
+		}
+	})
+
+	t.Run("UpdateReplacesFieldsAndLinks", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		sale := mustCreateCategory(t, repos.Categories, "Sale", nil)
+		id := mustCreateProduct(t, repos.Products, "Tee", 9.5, shirts)
+
+		_, err := repos.Products.UpdateProduct(&products.Product{ID: id, Name: "Long Tee", Description: "updated", Price: 12, CategoryIDs: []int{sale}})
+		if err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+
+		got := repos.Products.GetProducts()
+		if len(got) != 1 || got[0].Name != "Long Tee" || got[0].Description != "updated" || got[0].Price != 12 {
+			t.Fatalf("products after update = %+v", got)
+		}
+		if !equalInts(got[0].CategoryIDs, []int{sale}) {
+			t.Errorf("categories after update = %v, want %v", got[0].CategoryIDs, []int{sale})
+		}
+	})
+
+	t.Run("UpdateMissing", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Products.UpdateProduct(&products.Product{ID: 404, Name: "Missing"})
+		if !errors.Is(err, products.ErrNotFound) {
+			t.Fatalf("UpdateProduct error = %v, want %v", err, products.ErrNotFound)
+		}
+	})
+
+	t.Run("UnknownCategory", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Products.CreateProduct(&products.Product{Name: "Tee", CategoryIDs: []int{404}})
+		if !errors.Is(err, products.ErrInvalidCategory) {
+			t.Fatalf("CreateProduct error = %v, want %v", err, products.ErrInvalidCategory)
+		}
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		_, err = repos.Products.CreateProduct(&products.Product{Name: "Tee", CategoryIDs: []int{shirts, shirts}})
+		if !errors.Is(err, products.ErrDuplicateCategory) {
+			t.Fatalf("CreateProduct with a repeated category error = %v, want %v", err, products.ErrDuplicateCategory)
+		}
+		if got := repos.Products.GetProducts(); len(got) != 0 {
+			t.Fatalf("GetProducts = %+v, want no products", got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repos := newRepos(t)
+		id := mustCreateProduct(t, repos.Products, "Tee", 9.5)
+		collectionID := mustCreateCollection(t, repos.Collections, "Summer", nil, id)
+
+		if err := repos.Products.DeleteProduct(id, 0); err != nil {
+			t.Fatalf("DeleteProduct: %v", err)
+		}
+		if got := repos.Products.GetProducts(); len(got) != 0 {
+			t.Fatalf("GetProducts = %+v, want no products", got)
+		}
+		for _, c := range repos.Collections.GetCollections() {
+			if c.ID == collectionID && len(c.ProductIDs) != 0 {
+				t.Errorf("collection still links deleted product: %v", c.ProductIDs)
+			}
+		}
+		if err := repos.Products.DeleteProduct(id, 0); !errors.Is(err, products.ErrNotFound) {
+			t.Fatalf("second DeleteProduct error = %v, want %v", err, products.ErrNotFound)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repos := newRepos(t)
+		id := mustCreateProduct(t, repos.Products, "Tee", 9.5)
+
+		updated, err := repos.Products.UpdateProduct(&products.Product{ID: id, Name: "Long Tee", Version: 1})
+		if err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+		if updated.Version != 2 {
+			t.Errorf("version after update = %d, want 2", updated.Version)
+		}
+		_, err = repos.Products.UpdateProduct(&products.Product{ID: id, Name: "Stale", Version: 1})
+		if !errors.Is(err, products.ErrVersionMismatch) {
+			t.Fatalf("stale UpdateProduct error = %v, want %v", err, products.ErrVersionMismatch)
+		}
+		if err := repos.Products.DeleteProduct(id, 1); !errors.Is(err, products.ErrVersionMismatch) {
+			t.Fatalf("stale DeleteProduct error = %v, want %v", err, products.ErrVersionMismatch)
+		}
+		got, err := repos.Products.GetProduct(id)
+		if err != nil || got.Name != "Long Tee" || got.Version != 2 {
+			t.Fatalf("GetProduct = %+v, %v, want Long Tee at version 2", got, err)
+		}
+		if err := repos.Products.DeleteProduct(id, 2); err != nil {
+			t.Fatalf("DeleteProduct: %v", err)
+		}
+	})
+
+	t.Run("LinkCategories", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		sale := mustCreateCategory(t, repos.Categories, "Sale", nil)
+		id := mustCreateProduct(t, repos.Products, "Tee", 9.5, shirts)
+
+		got, err := repos.Products.UpdateProductCategories(products.CategoryLinks{ProductID: id, Add: []int{sale, shirts}, Version: 1})
+		if err != nil || !equalInts(got.CategoryIDs, []int{shirts, sale}) || got.Version != 2 {
+			t.Fatalf("UpdateProductCategories(add) = %+v, %v, want both categories at version 2", got, err)
+		}
+		got, err = repos.Products.UpdateProductCategories(products.CategoryLinks{ProductID: id, Remove: []int{shirts, 404}})
+		if err != nil || !equalInts(got.CategoryIDs, []int{sale}) {
+			t.Fatalf("UpdateProductCategories(remove) = %+v, %v, want only %d", got, err, sale)
+		}
+		if _, err := repos.Products.UpdateProductCategories(products.CategoryLinks{ProductID: id, Add: []int{404}}); !errors.Is(err, products.ErrInvalidCategory) {
+			t.Errorf("linking an unknown category error = %v, want %v", err, products.ErrInvalidCategory)
+		}
+		if _, err := repos.Products.UpdateProductCategories(products.CategoryLinks{ProductID: id, Add: []int{shirts}, Version: 1}); !errors.Is(err, products.ErrVersionMismatch) {
+			t.Errorf("stale link error = %v, want %v", err, products.ErrVersionMismatch)
+		}
+		if got, _ := repos.Products.GetProduct(id); !equalInts(got.CategoryIDs, []int{sale}) {
+			t.Errorf("categories after failed links = %v, want %v", got.CategoryIDs, []int{sale})
+		}
+	})
+
+	t.Run("ListAfter", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		var all []int
+		for _, name := range []string{"A", "B", "C"} {
+			all = append(all, mustCreateProduct(t, repos.Products, name, 1, shirts))
+		}
+
+		page := repos.Products.GetProductsAfter(0, 2)
+		if len(page) != 2 || page[0].ID != all[0] || page[1].ID != all[1] {
+			t.Fatalf("first page = %+v, want %v", page, all[:2])
+		}
+		if !equalInts(page[1].CategoryIDs, []int{shirts}) {
+			t.Errorf("page categories = %v, want %v", page[1].CategoryIDs, []int{shirts})
+		}
+
+		// Rows created while paging must appear after the cursor, not shift it.
+		all = append(all, mustCreateProduct(t, repos.Products, "D", 1))
+		page = repos.Products.GetProductsAfter(page[1].ID, 2)
+		if len(page) != 2 || page[0].ID != all[2] || page[1].ID != all[3] {
+			t.Fatalf("second page = %+v, want %v", page, all[2:])
+		}
+		if page[1].CategoryIDs == nil || len(page[1].CategoryIDs) != 0 {
+			t.Errorf("unlinked product categories = %v, want empty", page[1].CategoryIDs)
+		}
+		if page = repos.Products.GetProductsAfter(all[3], 2); len(page) != 0 {
+			t.Errorf("page past the end = %+v, want none", page)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		repos := newRepos(t)
+		linen := mustCreateProduct(t, repos.Products, "Linen Shirt", 30)
+		polo, err := repos.Products.CreateProduct(&products.Product{Name: "Polo", Description: "A <b>shirt</b> with a collar", Price: 20})
+		if err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		mustCreateProduct(t, repos.Products, "Sandal", 15)
+
+		results := repos.Products.SearchProducts([]string{"shir"}, 10)
+		if len(results) != 2 || results[0].Product.ID != linen || results[1].Product.ID != polo.ID {
+			t.Fatalf("search results = %+v, want name match before description match", results)
+		}
+		if results[0].Score <= results[1].Score {
+			t.Errorf("scores = %v, %v, want descending", results[0].Score, results[1].Score)
+		}
+		if !strings.Contains(results[1].Snippet, "<mark>") || strings.Contains(results[1].Snippet, "<b>") {
+			t.Errorf("snippet = %q, want highlighted and escaped", results[1].Snippet)
+		}
+		if got := repos.Products.SearchProducts([]string{"shir", "coll"}, 10); len(got) != 1 || got[0].Product.ID != polo.ID {
+			t.Errorf("search requiring every term = %+v, want only the polo", got)
+		}
+
+		// The index follows updates and deletes.
+		if _, err := repos.Products.UpdateProduct(&products.Product{ID: linen, Name: "Linen Trousers", Price: 30}); err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+		if err := repos.Products.DeleteProduct(polo.ID, 0); err != nil {
+			t.Fatalf("DeleteProduct: %v", err)
+		}
+		if got := repos.Products.SearchProducts([]string{"shir"}, 10); len(got) != 0 {
+			t.Errorf("search after update and delete = %+v, want none", got)
+		}
+		if got := repos.Products.SearchProducts([]string{"trou"}, 10); len(got) != 1 || got[0].Product.ID != linen {
+			t.Errorf("search for updated name = %+v, want [%d]", got, linen)
+		}
+	})
+
+	t.Run("GetWithRelations", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10, shirts)
+		other := mustCreateProduct(t, repos.Products, "Polo", 20)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil, tee)
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil, other)
+		outlet := mustCreateShop(t, repos.Shops, "Outlet", summer)
+		mustCreateShop(t, repos.Shops, "Ski", winter)
+		everything := mustCreateShop(t, repos.Shops, "Everything")
+
+		got, err := repos.Products.GetProduct(tee)
+		if err != nil || got.Name != "Tee" || !equalInts(got.CategoryIDs, []int{shirts}) {
+			t.Fatalf("GetProduct = %+v, %v", got, err)
+		}
+		if _, err := repos.Products.GetProduct(404); !errors.Is(err, products.ErrNotFound) {
+			t.Errorf("GetProduct(404) error = %v, want %v", err, products.ErrNotFound)
+		}
+
+		relations, err := repos.Products.GetProductRelations(tee, products.Expand{Categories: true, Collections: true, Shops: true})
+		if err != nil {
+			t.Fatalf("GetProductRelations: %v", err)
+		}
+		if !slices.Equal(relations.Categories, []products.Ref{{ID: shirts, Name: "Shirts"}}) {
+			t.Errorf("categories = %+v", relations.Categories)
+		}
+		if !slices.Equal(relations.Collections, []products.Ref{{ID: summer, Name: "Summer"}}) {
+			t.Errorf("collections = %+v", relations.Collections)
+		}
+		if want := []products.Ref{{ID: outlet, Name: "Outlet"}, {ID: everything, Name: "Everything"}}; !slices.Equal(relations.Shops, want) {
+			t.Errorf("shops = %+v, want %+v", relations.Shops, want)
+		}
+
+		relations, err = repos.Products.GetProductRelations(other, products.Expand{Categories: true})
+		if err != nil || relations.Categories == nil || len(relations.Categories) != 0 || relations.Shops != nil {
+			t.Errorf("relations with categories only = %+v, %v", relations, err)
+		}
+	})
+}
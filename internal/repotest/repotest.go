@@ -0,0 +1,108 @@
+// Package repotest provides conformance suites that every repository backend
+// must pass. Each suite takes a Factory returning a fresh, empty set of
+// repositories that share the same underlying storage.
+package repotest
+
+import (
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+	"categories-test/internal/snapshot"
+)
+
+type ProductRepository interface {
+	products.CommandRepository
+	products.QueryRepository
+}
+
+type CategoryRepository interface {
+	categories.CommandRepository
+	categories.QueryRepository
+}
+
+type CollectionRepository interface {
+	collections.CommandRepository
+	collections.QueryRepository
+}
+
+type ShopRepository interface {
+	shops.CommandRepository
+	shops.QueryRepository
+}
+
+type Repositories struct {
+	Products    ProductRepository
+	Categories  CategoryRepository
+	Collections CollectionRepository
+	Shops       ShopRepository
+	Snapshots   snapshot.Repository
+}
+
+type Factory func(t *testing.T) Repositories
+
+func Run(t *testing.T, newRepos Factory) {
+	t.Run("Products", func(t *testing.T) { RunProducts(t, newRepos) })
+	t.Run("Categories", func(t *testing.T) { RunCategories(t, newRepos) })
+	t.Run("Collections", func(t *testing.T) { RunCollections(t, newRepos) })
+	t.Run("Shops", func(t *testing.T) { RunShops(t, newRepos) })
+	t.Run("Snapshots", func(t *testing.T) { RunSnapshots(t, newRepos) })
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func mustCreateCategory(t *testing.T, repo CategoryRepository, name string, parentID *int) int {
+	t.Helper()
+	c, err := repo.CreateCategory(&categories.Category{Name: name, ParentID: parentID}, categories.NewPlacement())
+	if err != nil {
+		t.Fatalf("CreateCategory(%q): %v", name, err)
+	}
+	return c.ID
+}
+
+func mustCreateProduct(t *testing.T, repo ProductRepository, name string, price float64, categoryIDs ...int) int {
+	t.Helper()
+	p, err := repo.CreateProduct(&products.Product{Name: name, Description: name + " description", Price: price, CategoryIDs: categoryIDs})
+	if err != nil {
+		t.Fatalf("CreateProduct(%q): %v", name, err)
+	}
+	return p.ID
+}
+
+func mustCreateCollection(t *testing.T, repo CollectionRepository, name string, parentID *int, productIDs ...int) int {
+	t.Helper()
+	c, err := repo.CreateCollection(&collections.Collection{Name: name, ParentID: parentID, ProductIDs: productIDs}, collections.NewPlacement())
+	if err != nil {
+		t.Fatalf("CreateCollection(%q): %v", name, err)
+	}
+	return c.ID
+}
+
+func mustCreateShop(t *testing.T, repo ShopRepository, name string, collectionIDs ...int) int {
+	t.Helper()
+	s, err := repo.CreateShop(&shops.Shop{Name: name, CollectionIDs: collectionIDs})
+	if err != nil {
+		t.Fatalf("CreateShop(%q): %v", name, err)
+	}
+	return s.ID
+}
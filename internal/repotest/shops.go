@@ -0,0 +1,408 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+)
+
+func RunShops(t *testing.T, newRepos Factory) {
+	t.Run("CRUD", func(t *testing.T) {
+		repos := newRepos(t)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil)
+		id := mustCreateShop(t, repos.Shops, "Outlet", winter, summer)
+
+		shop, err := repos.Shops.GetShop(id)
+		if err != nil {
+			t.Fatalf("GetShop: %v", err)
+		}
+		if shop.Name != "Outlet" || !equalInts(shop.CollectionIDs, []int{summer, winter}) {
+			t.Errorf("GetShop = %+v", shop)
+		}
+
+		updated, err := repos.Shops.UpdateShop(&shops.Shop{ID: id, Name: "Flagship", CollectionIDs: []int{winter}, Version: 1})
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("UpdateShop = %+v, %v, want version 2", updated, err)
+		}
+		list := repos.Shops.GetShops()
+		if len(list) != 1 || list[0].Name != "Flagship" || !equalInts(list[0].CollectionIDs, []int{winter}) {
+			t.Errorf("GetShops after update = %+v", list)
+		}
+
+		if _, err := repos.Shops.UpdateShop(&shops.Shop{ID: id, Name: "Stale", Version: 1}); !errors.Is(err, shops.ErrVersionMismatch) {
+			t.Fatalf("stale UpdateShop error = %v, want %v", err, shops.ErrVersionMismatch)
+		}
+		if err := repos.Shops.DeleteShop(id, 1); !errors.Is(err, shops.ErrVersionMismatch) {
+			t.Fatalf("stale DeleteShop error = %v, want %v", err, shops.ErrVersionMismatch)
+		}
+		if err := repos.Shops.DeleteShop(id, 2); err != nil {
+			t.Fatalf("DeleteShop: %v", err)
+		}
+		if _, err := repos.Shops.GetShop(id); !errors.Is(err, shops.ErrNotFound) {
+			t.Errorf("GetShop after delete error = %v, want %v", err, shops.ErrNotFound)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		repos := newRepos(t)
+		if _, err := repos.Shops.GetShop(404); !errors.Is(err, shops.ErrNotFound) {
+			t.Errorf("GetShop error = %v, want %v", err, shops.ErrNotFound)
+		}
+		if _, err := repos.Shops.UpdateShop(&shops.Shop{ID: 404, Name: "Missing", Version: 1}); !errors.Is(err, shops.ErrNotFound) {
+			t.Errorf("UpdateShop error = %v, want %v", err, shops.ErrNotFound)
+		}
+		if err := repos.Shops.DeleteShop(404, 0); !errors.Is(err, shops.ErrNotFound) {
+			t.Errorf("DeleteShop error = %v, want %v", err, shops.ErrNotFound)
+		}
+	})
+
+	t.Run("AddAndRemoveCollections", func(t *testing.T) {
+		repos := newRepos(t)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil)
+		id := mustCreateShop(t, repos.Shops, "Outlet", summer)
+
+		got, err := repos.Shops.UpdateShopCollections(shops.CollectionLinks{ShopID: id, Add: []int{winter, summer}, Version: 1})
+		if err != nil || !equalInts(got.CollectionIDs, []int{summer, winter}) || got.Version != 2 {
+			t.Fatalf("UpdateShopCollections(add) = %+v, %v, want both collections at version 2", got, err)
+		}
+		got, err = repos.Shops.UpdateShopCollections(shops.CollectionLinks{ShopID: id, Remove: []int{summer}})
+		if err != nil || !equalInts(got.CollectionIDs, []int{winter}) {
+			t.Fatalf("UpdateShopCollections(remove) = %+v, %v, want only %d", got, err, winter)
+		}
+		if _, err := repos.Shops.UpdateShopCollections(shops.CollectionLinks{ShopID: id, Add: []int{404}}); !errors.Is(err, shops.ErrInvalidCollection) {
+			t.Errorf("adding an unknown collection error = %v, want %v", err, shops.ErrInvalidCollection)
+		}
+	})
+
+	t.Run("UnknownCollection", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Shops.CreateShop(&shops.Shop{Name: "Outlet", CollectionIDs: []int{404}})
+		if !errors.Is(err, shops.ErrInvalidCollection) {
+			t.Fatalf("CreateShop error = %v, want %v", err, shops.ErrInvalidCollection)
+		}
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		_, err = repos.Shops.CreateShop(&shops.Shop{Name: "Outlet", CollectionIDs: []int{summer, summer}})
+		if !errors.Is(err, shops.ErrDuplicateCollection) {
+			t.Fatalf("CreateShop with a repeated collection error = %v, want %v", err, shops.ErrDuplicateCollection)
+		}
+		if got := repos.Shops.GetShops(); len(got) != 0 {
+			t.Fatalf("GetShops = %+v, want no shops", got)
+		}
+	})
+
+	t.Run("ProductsWithoutCollectionsShowAll", func(t *testing.T) {
+		repos := newRepos(t)
+		a := mustCreateProduct(t, repos.Products, "A", 1)
+		b := mustCreateProduct(t, repos.Products, "B", 2)
+		id := mustCreateShop(t, repos.Shops, "Outlet")
+
+		page := repos.Shops.GetShopProducts(id, shops.ProductFilter{}, 1, 10)
+		if ids := productIDs(page); !equalInts(ids, []int{a, b}) {
+			t.Fatalf("products = %v, want %v", ids, []int{a, b})
+		}
+	})
+
+	t.Run("ProductsScopedToShopCollections", func(t *testing.T) {
+		repos := newRepos(t)
+		a := mustCreateProduct(t, repos.Products, "A", 1)
+		b := mustCreateProduct(t, repos.Products, "B", 2)
+		mustCreateProduct(t, repos.Products, "C", 3)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil, b, a)
+		id := mustCreateShop(t, repos.Shops, "Outlet", summer)
+
+		page := repos.Shops.GetShopProducts(id, shops.ProductFilter{}, 1, 10)
+		if ids := productIDs(page); !equalInts(ids, []int{a, b}) {
+			t.Fatalf("products = %v, want %v", ids, []int{a, b})
+		}
+	})
+
+	t.Run("ProductsFilteredByCollectionAndCategory", func(t *testing.T) {
+		repos := newRepos(t)
+		clothing := mustCreateCategory(t, repos.Categories, "Clothing", nil)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", intPtr(clothing))
+		shoes := mustCreateCategory(t, repos.Categories, "Shoes", nil)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10, shirts)
+		sandal := mustCreateProduct(t, repos.Products, "Sandal", 20, shoes)
+		coat := mustCreateProduct(t, repos.Products, "Coat", 30, clothing)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil, tee)
+		beach := mustCreateCollection(t, repos.Collections, "Beach", intPtr(summer), sandal)
+		winter := mustCreateCollection(t, repos.Collections, "Winter", nil, coat)
+		id := mustCreateShop(t, repos.Shops, "Outlet", summer, winter)
+
+		page := repos.Shops.GetShopProducts(id, shops.ProductFilter{CollectionID: intPtr(summer)}, 1, 10)
+		if ids := productIDs(page); !equalInts(ids, []int{tee, sandal}) {
+			t.Errorf("summer products = %v, want %v (descendant collections included)", ids, []int{tee, sandal})
+		}
+		page = repos.Shops.GetShopProducts(id, shops.ProductFilter{CollectionID: intPtr(beach)}, 1, 10)
+		if ids := productIDs(page); !equalInts(ids, []int{sandal}) {
+			t.Errorf("beach products = %v, want %v", ids, []int{sandal})
+		}
+		page = repos.Shops.GetShopProducts(id, shops.ProductFilter{CategoryIDs: []int{clothing}}, 1, 10)
+		if ids := productIDs(page); !equalInts(ids, []int{tee, coat}) {
+			t.Errorf("clothing products = %v, want %v (descendant categories included)", ids, []int{tee, coat})
+		}
+		page = repos.Shops.GetShopProducts(id, shops.ProductFilter{CollectionID: intPtr(summer), CategoryIDs: []int{shoes}}, 1, 10)
+		if ids := productIDs(page); !equalInts(ids, []int{sandal}) {
+			t.Errorf("summer shoes = %v, want %v", ids, []int{sandal})
+		}
+		if !equalInts(page.Products[0].CategoryIDs, []int{shoes}) {
+			t.Errorf("sandal categories = %v, want %v", page.Products[0].CategoryIDs, []int{shoes})
+		}
+	})
+
+	t.Run("ProductsPagination", func(t *testing.T) {
+		repos := newRepos(t)
+		var all []int
+		for _, name := range []string{"A", "B", "C", "D", "E"} {
+			all = append(all, mustCreateProduct(t, repos.Products, name, 1))
+		}
+		id := mustCreateShop(t, repos.Shops, "Outlet")
+
+		page := repos.Shops.GetShopProducts(id, shops.ProductFilter{}, 2, 2)
+		if ids := productIDs(page); !equalInts(ids, all[2:4]) {
+			t.Errorf("page 2 = %v, want %v", ids, all[2:4])
+		}
+		if page.Page != 2 || page.Limit != 2 || page.TotalCount != 5 || page.TotalPages != 3 {
+			t.Errorf("page metadata = %+v", page)
+		}
+		page = repos.Shops.GetShopProducts(id, shops.ProductFilter{}, 3, 2)
+		if ids := productIDs(page); !equalInts(ids, all[4:]) {
+			t.Errorf("page 3 = %v, want %v", ids, all[4:])
+		}
+		page = repos.Shops.GetShopProducts(id, shops.ProductFilter{}, 4, 2)
+		if page.Products == nil || len(page.Products) != 0 || page.TotalCount != 5 {
+			t.Errorf("page past the end = %+v, want empty products with total count", page)
+		}
+	})
+
+	t.Run("ProductsForMissingShop", func(t *testing.T) {
+		repos := newRepos(t)
+		mustCreateProduct(t, repos.Products, "A", 1)
+
+		page := repos.Shops.GetShopProducts(404, shops.ProductFilter{}, 1, 10)
+		if page.Products == nil || len(page.Products) != 0 || page.TotalCount != 0 {
+			t.Fatalf("page = %+v, want empty", page)
+		}
+	})
+
+	t.Run("Categories", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		shoes := mustCreateCategory(t, repos.Categories, "Shoes", nil)
+		mustCreateCategory(t, repos.Categories, "Hats", nil)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 10, shirts)
+		sandal := mustCreateProduct(t, repos.Products, "Sandal", 20, shoes)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil, tee)
+		mustCreateCollection(t, repos.Collections, "Beach", intPtr(summer), sandal)
+		id := mustCreateShop(t, repos.Shops, "Outlet", summer)
+
+		if ids := categoryIDs(repos.Shops.GetShopCategories(id, nil, false)); !equalInts(ids, []int{shirts}) {
+			t.Errorf("shop categories = %v, want %v", ids, []int{shirts})
+		}
+		if ids := categoryIDs(repos.Shops.GetShopCategories(id, intPtr(summer), false)); !equalInts(ids, []int{shirts, shoes}) {
+			t.Errorf("summer categories = %v, want %v", ids, []int{shirts, shoes})
+		}
+		if ids := categoryIDs(repos.Shops.GetShopCategories(id, intPtr(summer), true)); !equalInts(ids, []int{shirts}) {
+			t.Errorf("summer direct categories = %v, want %v", ids, []int{shirts})
+		}
+		if got := repos.Shops.GetShopCategories(404, nil, false); len(got) != 0 {
+			t.Errorf("missing shop categories = %+v, want none", got)
+		}
+	})
+
+	t.Run("ListAfter", func(t *testing.T) {
+		repos := newRepos(t)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil)
+		first := mustCreateShop(t, repos.Shops, "Outlet")
+		second := mustCreateShop(t, repos.Shops, "Flagship", summer)
+
+		page := repos.Shops.GetShopsAfter(first, 10)
+		if len(page) != 1 || page[0].ID != second || !equalInts(page[0].CollectionIDs, []int{summer}) {
+			t.Fatalf("page after first shop = %+v, want [%d]", page, second)
+		}
+	})
+
+	t.Run("ProductsAfter", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		var linked []int
+		for _, name := range []string{"A", "B", "C"} {
+			linked = append(linked, mustCreateProduct(t, repos.Products, name, 1, shirts))
+		}
+		mustCreateProduct(t, repos.Products, "Unlinked", 1, shirts)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil, linked...)
+		id := mustCreateShop(t, repos.Shops, "Outlet", summer)
+
+		page := repos.Shops.GetShopProductsAfter(id, shops.ProductFilter{CategoryIDs: []int{shirts}}, linked[0], 10)
+		if ids := productIDs(&shops.PaginatedProducts{Products: page}); !equalInts(ids, linked[1:]) {
+			t.Errorf("products after first = %v, want %v", ids, linked[1:])
+		}
+		if len(page) > 0 && !equalInts(page[0].CategoryIDs, []int{shirts}) {
+			t.Errorf("product categories = %v, want %v", page[0].CategoryIDs, []int{shirts})
+		}
+		if page = repos.Shops.GetShopProductsAfter(id, shops.ProductFilter{}, 0, 1); len(page) != 1 || page[0].ID != linked[0] {
+			t.Errorf("first product = %+v, want [%d]", page, linked[0])
+		}
+		if page = repos.Shops.GetShopProductsAfter(404, shops.ProductFilter{}, 0, 10); page == nil || len(page) != 0 {
+			t.Errorf("missing shop products = %+v, want empty", page)
+		}
+	})
+
+	t.Run("ProductsSortedAndFiltered", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		polos := mustCreateCategory(t, repos.Categories, "Polos", intPtr(shirts))
+		sale := mustCreateCategory(t, repos.Categories, "Sale", nil)
+		tee := mustCreateProduct(t, repos.Products, "Tee", 30, shirts, sale)
+		polo := mustCreateProduct(t, repos.Products, "Polo", 5, polos)
+		hat := mustCreateProduct(t, repos.Products, "Hat", 120, sale)
+		id := mustCreateShop(t, repos.Shops, "Outlet")
+
+		for _, tc := range []struct {
+			name   string
+			filter shops.ProductFilter
+			want   []int
+		}{
+			{"price ascending", shops.ProductFilter{Sort: shops.SortPriceAsc}, []int{polo, tee, hat}},
+			{"price descending", shops.ProductFilter{Sort: shops.SortPriceDesc}, []int{hat, tee, polo}},
+			{"name", shops.ProductFilter{Sort: shops.SortName}, []int{hat, polo, tee}},
+			{"newest", shops.ProductFilter{Sort: shops.SortNewest}, []int{hat, polo, tee}},
+			{"price range", shops.ProductFilter{MinPrice: floatPtr(5), MaxPrice: floatPtr(30)}, []int{tee, polo}},
+			{"any category", shops.ProductFilter{CategoryIDs: []int{shirts, sale}}, []int{tee, polo, hat}},
+			{"all categories", shops.ProductFilter{CategoryIDs: []int{shirts, sale}, MatchAllCategories: true}, []int{tee}},
+		} {
+			page := repos.Shops.GetShopProducts(id, tc.filter, 1, 10)
+			if ids := productIDs(page); !equalInts(ids, tc.want) {
+				t.Errorf("%s = %v, want %v", tc.name, ids, tc.want)
+			}
+		}
+	})
+
+	t.Run("ProductFacets", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		sale := mustCreateCategory(t, repos.Categories, "Sale", nil)
+		mustCreateProduct(t, repos.Products, "Tee", 5, shirts, sale)
+		mustCreateProduct(t, repos.Products, "Polo", 40, shirts)
+		mustCreateProduct(t, repos.Products, "Coat", 300)
+		id := mustCreateShop(t, repos.Shops, "Outlet")
+
+		// Facets cover the whole result set, not just the single-item page.
+		facets := repos.Shops.GetShopProducts(id, shops.ProductFilter{}, 1, 1).Facets
+		want := []shops.CategoryFacet{{CategoryID: shirts, Name: "Shirts", Count: 2}, {CategoryID: sale, Name: "Sale", Count: 1}}
+		if len(facets.Categories) != len(want) {
+			t.Fatalf("category facets = %+v, want %+v", facets.Categories, want)
+		}
+		for i := range want {
+			if facets.Categories[i] != want[i] {
+				t.Errorf("category facet %d = %+v, want %+v", i, facets.Categories[i], want[i])
+			}
+		}
+		counts := make([]int, 0, len(facets.PriceBuckets))
+		for _, b := range facets.PriceBuckets {
+			counts = append(counts, b.Count)
+		}
+		if !equalInts(counts, []int{1, 0, 1, 0, 0, 1}) {
+			t.Errorf("price bucket counts = %v, want [1 0 1 0 0 1]", counts)
+		}
+		if last := facets.PriceBuckets[len(facets.PriceBuckets)-1]; last.Max != nil {
+			t.Errorf("last price bucket = %+v, want no upper bound", last)
+		}
+
+		facets = repos.Shops.GetShopProducts(404, shops.ProductFilter{}, 1, 1).Facets
+		if facets.Categories == nil || len(facets.Categories) != 0 || len(facets.PriceBuckets) != len(counts) {
+			t.Errorf("missing shop facets = %+v, want empty categories and zeroed buckets", facets)
+		}
+	})
+
+	t.Run("ProductsSearch", func(t *testing.T) {
+		repos := newRepos(t)
+		linen := mustCreateProduct(t, repos.Products, "Linen Shirt", 30)
+		polo, err := repos.Products.CreateProduct(&products.Product{Name: "Polo", Description: "A shirt with a collar", Price: 20})
+		if err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		outside := mustCreateProduct(t, repos.Products, "Oxford Shirt", 40)
+		summer := mustCreateCollection(t, repos.Collections, "Summer", nil, polo.ID, linen)
+		id := mustCreateShop(t, repos.Shops, "Outlet", summer)
+
+		page := repos.Shops.GetShopProducts(id, shops.ProductFilter{Query: "shirt"}, 1, 10)
+		if ids := productIDs(page); !equalInts(ids, []int{linen, polo.ID}) || page.TotalCount != 2 {
+			t.Errorf("ranked search = %v (total %d), want %v without %d", ids, page.TotalCount, []int{linen, polo.ID}, outside)
+		}
+		page = repos.Shops.GetShopProducts(id, shops.ProductFilter{Query: "shirt", Sort: shops.SortPriceAsc}, 1, 10)
+		if ids := productIDs(page); !equalInts(ids, []int{polo.ID, linen}) {
+			t.Errorf("search sorted by price = %v, want %v", ids, []int{polo.ID, linen})
+		}
+	})
+
+	t.Run("Suggestions", func(t *testing.T) {
+		repos := newRepos(t)
+		shirts := mustCreateCategory(t, repos.Categories, "Shirts", nil)
+		shoes := mustCreateCategory(t, repos.Categories, "Shoes", nil)
+		linen := mustCreateProduct(t, repos.Products, "Linen Shirt", 30, shirts)
+		oxford := mustCreateProduct(t, repos.Products, "Oxford Shirt", 40, shirts)
+		mustCreateProduct(t, repos.Products, "Shoe Polish", 5, shoes)
+		summer := mustCreateCollection(t, repos.Collections, "Summer Shirts", nil, linen, oxford)
+		sale := mustCreateCollection(t, repos.Collections, "Shirt Sale", intPtr(summer))
+		mustCreateCollection(t, repos.Collections, "Shoe Week", nil)
+		id := mustCreateShop(t, repos.Shops, "Outlet", summer)
+
+		got := repos.Shops.GetShopSuggestions(id, []string{"shi"}, 10)
+		if ids := suggestionIDs(got.Products); !equalInts(ids, []int{linen, oxford}) {
+			t.Errorf("product suggestions = %+v, want only the shop's shirts", got.Products)
+		}
+		if ids := suggestionIDs(got.Categories); !equalInts(ids, []int{shirts}) {
+			t.Errorf("category suggestions = %+v, want [%d]", got.Categories, shirts)
+		}
+		if ids := suggestionIDs(got.Collections); !equalInts(ids, []int{summer, sale}) {
+			t.Errorf("collection suggestions = %+v, want the shop's collections", got.Collections)
+		}
+
+		got = repos.Shops.GetShopSuggestions(id, []string{"shrit"}, 10)
+		if ids := suggestionIDs(got.Products); !equalInts(ids, []int{linen, oxford}) {
+			t.Errorf("product suggestions with a typo = %+v, want the shirts", got.Products)
+		}
+		if ids := suggestionIDs(got.Categories); !equalInts(ids, []int{shirts}) {
+			t.Errorf("category suggestions with a typo = %+v, want [%d]", got.Categories, shirts)
+		}
+
+		// A shop without collections exposes everything.
+		all := mustCreateShop(t, repos.Shops, "Everything")
+		got = repos.Shops.GetShopSuggestions(all, []string{"shoe"}, 10)
+		if len(got.Products) != 1 || len(got.Categories) != 1 || len(got.Collections) != 1 {
+			t.Errorf("suggestions for a shop without collections = %+v", got)
+		}
+		if got = repos.Shops.GetShopSuggestions(404, []string{"shi"}, 10); len(got.Products)+len(got.Categories)+len(got.Collections) != 0 {
+			t.Errorf("missing shop suggestions = %+v, want none", got)
+		}
+	})
+}
+
+func productIDs(page *shops.PaginatedProducts) []int {
+	ids := make([]int, 0, len(page.Products))
+	for _, p := range page.Products {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func categoryIDs(items []*shops.CategoryView) []int {
+	ids := make([]int, 0, len(items))
+	for _, c := range items {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func suggestionIDs(items []shops.Suggestion) []int {
+	ids := make([]int, 0, len(items))
+	for _, s := range items {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
@@ -0,0 +1,73 @@
+package repotest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/products"
+	"categories-test/internal/snapshot"
+)
+
+func RunSnapshots(t *testing.T, newRepos Factory) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		source := newRepos(t)
+		gone := mustCreateCategory(t, source.Categories, "Gone", nil)
+		clothing := mustCreateCategory(t, source.Categories, "Clothing", nil)
+		shirts := mustCreateCategory(t, source.Categories, "Shirts", &clothing)
+		sale := mustCreateCategory(t, source.Categories, "Sale", nil)
+		if _, err := source.Categories.DeleteCategory(gone, categories.DeleteOptions{}); err != nil {
+			t.Fatalf("DeleteCategory: %v", err)
+		}
+		if _, err := source.Categories.MoveCategory(categories.Move{ID: sale, BeforeID: &clothing}, categories.NewPlacement()); err != nil {
+			t.Fatalf("MoveCategory: %v", err)
+		}
+		tee := mustCreateProduct(t, source.Products, "Tee", 12.5, shirts, sale)
+		mug := mustCreateProduct(t, source.Products, "Mug", 4)
+		summer := mustCreateCollection(t, source.Collections, "Summer", nil, tee)
+		mustCreateCollection(t, source.Collections, "Beach", &summer, mug, tee)
+		mustCreateShop(t, source.Shops, "Outlet", summer)
+
+		snap := loadSnapshot(t, source.Snapshots)
+		if got := snap.Counts(); got != (snapshot.Counts{Categories: 3, Products: 2, Collections: 2, Shops: 1}) {
+			t.Fatalf("snapshot counts = %+v", got)
+		}
+
+		target := newRepos(t)
+		if err := target.Snapshots.RestoreSnapshot(snap); err != nil {
+			t.Fatalf("RestoreSnapshot: %v", err)
+		}
+		if restored := loadSnapshot(t, target.Snapshots); !reflect.DeepEqual(restored, snap) {
+			t.Errorf("restored snapshot differs from the original")
+		}
+
+		product, err := target.Products.GetProduct(tee)
+		if err != nil || product.Version != 1 {
+			t.Errorf("restored product = %+v, %v, want version 1", product, err)
+		}
+		if id := mustCreateCategory(t, target.Categories, "New", nil); id <= sale {
+			t.Errorf("new category ID = %d, want above the restored IDs", id)
+		}
+		if err := target.Snapshots.RestoreSnapshot(snap); !errors.Is(err, snapshot.ErrCatalogNotEmpty) {
+			t.Errorf("second RestoreSnapshot error = %v, want %v", err, snapshot.ErrCatalogNotEmpty)
+		}
+	})
+}
+
+// loadSnapshot reads the whole catalog through ExportSnapshot.
+func loadSnapshot(t *testing.T, repo snapshot.Repository) *snapshot.Snapshot {
+	t.Helper()
+	var s *snapshot.Snapshot
+	err := repo.ExportSnapshot(func(src *snapshot.Source) error {
+		s = &snapshot.Snapshot{Categories: src.Categories, Products: []*products.Product{}, Collections: src.Collections, Shops: src.Shops}
+		return src.EachProduct(func(p *products.Product) error {
+			s.Products = append(s.Products, p)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("ExportSnapshot: %v", err)
+	}
+	return s
+}
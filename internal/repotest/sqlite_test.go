@@ -0,0 +1,30 @@
+package repotest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/db"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+	"categories-test/internal/snapshot"
+)
+
+func TestSQLiteRepositories(t *testing.T) {
+	Run(t, func(t *testing.T) Repositories {
+		client, err := db.OpenSQLite(filepath.Join(t.TempDir(), "conformance.db"))
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { client.Close() })
+		return Repositories{
+			Products:    products.NewSQLiteRepository(client),
+			Categories:  categories.NewSQLiteRepository(client),
+			Collections: collections.NewSQLiteRepository(client),
+			Shops:       shops.NewSQLiteRepository(client),
+			Snapshots:   snapshot.NewSQLiteRepository(client),
+		}
+	})
+}
@@ -14,15 +14,21 @@ import (
 	"categories-test/internal/categories"
 	"categories-test/internal/collections"
 	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/productimport"
 	"categories-test/internal/products"
+	"categories-test/internal/shopify"
 	"categories-test/internal/shops"
+	"categories-test/internal/snapshot"
 )
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -31,53 +37,223 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+const (
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+type Config struct {
+	Addr        string
+	Backend     string
+	SQLitePath  string
+	PostgresURL string
+	// MaxTreeDepth limits category and collection nesting; zero keeps the
+	// default.
+	MaxTreeDepth int
+}
+
 type Server struct {
 	httpServer *http.Server
 	db         *db.Client
 }
 
-func New(addr, dbPath string) (*Server, error) {
-	dbClient, err := db.OpenSQLite(dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("initialize database: %w", err)
+type productRepository interface {
+	products.CommandRepository
+	products.QueryRepository
+}
+
+type categoryRepository interface {
+	categories.CommandRepository
+	categories.QueryRepository
+}
+
+type collectionRepository interface {
+	collections.CommandRepository
+	collections.QueryRepository
+}
+
+type shopRepository interface {
+	shops.CommandRepository
+	shops.QueryRepository
+}
+
+type repositories struct {
+	products    productRepository
+	categories  categoryRepository
+	collections collectionRepository
+	shops       shopRepository
+	snapshots   snapshot.Repository
+	// inTx runs fn with repositories sharing one transaction. The memory
+	// backend runs fn against a copy of its tables, kept only if fn succeeds.
+	inTx func(fn func(repos repositories) error) error
+	// inReadTx is inTx for a read-only transaction.
+	inReadTx func(fn func(repos repositories) error) error
+}
+
+func sqliteRepositories(client *db.Client) repositories {
+	return repositories{
+		products:    products.NewSQLiteRepository(client),
+		categories:  categories.NewSQLiteRepository(client),
+		collections: collections.NewSQLiteRepository(client),
+		shops:       shops.NewSQLiteRepository(client),
+		snapshots:   snapshot.NewSQLiteRepository(client),
+		inTx: func(fn func(repos repositories) error) error {
+			return client.WithTx(context.Background(), func(tx *db.Client) error {
+				return fn(sqliteRepositories(tx))
+			})
+		},
+		inReadTx: func(fn func(repos repositories) error) error {
+			return client.WithReadTx(context.Background(), func(tx *db.Client) error {
+				return fn(sqliteRepositories(tx))
+			})
+		},
+	}
+}
+
+func postgresRepositories(client *db.Client) repositories {
+	return repositories{
+		products:    products.NewPostgresRepository(client),
+		categories:  categories.NewPostgresRepository(client),
+		collections: collections.NewPostgresRepository(client),
+		shops:       shops.NewPostgresRepository(client),
+		snapshots:   snapshot.NewPostgresRepository(client),
+		inTx: func(fn func(repos repositories) error) error {
+			return client.WithTx(context.Background(), func(tx *db.Client) error {
+				return fn(postgresRepositories(tx))
+			})
+		},
+		inReadTx: func(fn func(repos repositories) error) error {
+			return client.WithReadTx(context.Background(), func(tx *db.Client) error {
+				return fn(postgresRepositories(tx))
+			})
+		},
+	}
+}
+
+func memoryRepositories(store *memory.Store) repositories {
+	repos := repositories{
+		products:    products.NewMemoryRepository(store),
+		categories:  categories.NewMemoryRepository(store),
+		collections: collections.NewMemoryRepository(store),
+		shops:       shops.NewMemoryRepository(store),
+		snapshots:   snapshot.NewMemoryRepository(store),
+		inTx: func(fn func(repos repositories) error) error {
+			return store.Tx(func(tx *memory.Store) error {
+				return fn(memoryRepositories(tx))
+			})
+		},
+	}
+	repos.inReadTx = func(fn func(repos repositories) error) error {
+		return fn(repos)
+	}
+	return repos
+}
+
+// importTransactor runs product imports through the transactions of repos.
+func importTransactor(repos repositories) productimport.Transactor {
+	within := func(inTx func(fn func(repos repositories) error) error) func(fn func(productimport.Repositories) error) error {
+		return func(fn func(productimport.Repositories) error) error {
+			return inTx(func(tx repositories) error {
+				return fn(productimport.Repositories{
+					Products:    tx.products,
+					Categories:  tx.categories,
+					Collections: tx.collections,
+				})
+			})
+		}
+	}
+	return productimport.TransactorFuncs{Tx: within(repos.inTx), ReadTx: within(repos.inReadTx)}
+}
+
+func New(cfg Config) (*Server, error) {
+	s := &Server{}
+
+	var repos repositories
+	switch cfg.Backend {
+	case BackendSQLite, "":
+		dbClient, err := db.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("initialize database: %w", err)
+		}
+		s.db = dbClient
+		repos = sqliteRepositories(dbClient)
+	case BackendPostgres:
+		dbClient, err := db.OpenPostgres(cfg.PostgresURL)
+		if err != nil {
+			return nil, fmt.Errorf("initialize database: %w", err)
+		}
+		s.db = dbClient
+		repos = postgresRepositories(dbClient)
+	case BackendMemory:
+		repos = memoryRepositories(memory.NewStore())
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
 	}
 
 	productHandler := products.NewHTTPHandler(
-		products.NewCommands(products.NewSQLiteRepository(dbClient)),
-		products.NewQueries(products.NewSQLiteRepository(dbClient)),
+		products.NewCommands(repos.products),
+		products.NewQueries(repos.products),
 	)
 	categoryHandler := categories.NewHTTPHandler(
-		categories.NewCommands(categories.NewSQLiteRepository(dbClient)),
-		categories.NewQueries(categories.NewSQLiteRepository(dbClient)),
+		categories.NewCommands(repos.categories, tree.WithMaxDepth(cfg.MaxTreeDepth)),
+		categories.NewQueries(repos.categories),
 	)
 	collectionHandler := collections.NewHTTPHandler(
-		collections.NewCommands(collections.NewSQLiteRepository(dbClient)),
-		collections.NewQueries(collections.NewSQLiteRepository(dbClient)),
+		collections.NewCommands(repos.collections, tree.WithMaxDepth(cfg.MaxTreeDepth)),
+		collections.NewQueries(repos.collections),
+	)
+	importHandler := productimport.NewHTTPHandler(
+		productimport.NewImporter(importTransactor(repos), productimport.WithMaxDepth(cfg.MaxTreeDepth)),
+		productimport.DefaultParsers,
 	)
+	shopifyImportHandler := productimport.NewHTTPHandler(
+		productimport.NewImporter(importTransactor(repos), productimport.WithMaxDepth(cfg.MaxTreeDepth), productimport.WithCollectionCreation()),
+		shopify.Parsers,
+	)
+	shopifyExportHandler := shopify.NewHTTPHandler(snapshot.NewQueries(repos.snapshots))
 	shopHandler := shops.NewHTTPHandler(
-		shops.NewCommands(shops.NewSQLiteRepository(dbClient)),
-		shops.NewQueries(shops.NewSQLiteRepository(dbClient)),
+		shops.NewCommands(repos.shops),
+		shops.NewQueries(repos.shops),
+	)
+	snapshotHandler := snapshot.NewHTTPHandler(
+		snapshot.NewCommands(repos.snapshots),
+		snapshot.NewQueries(repos.snapshots),
 	)
-
-	s := &Server{
-		db: dbClient,
-	}
 
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /api/products", productHandler.List)
+	mux.HandleFunc("GET /api/products/search", productHandler.Search)
 	mux.HandleFunc("POST /api/products", productHandler.Create)
+	mux.HandleFunc("POST /api/products/import", importHandler.Import)
+	mux.HandleFunc("POST /api/products/import/shopify", shopifyImportHandler.Import)
+	mux.HandleFunc("GET /api/products/export/shopify", shopifyExportHandler.Export)
+	mux.HandleFunc("GET /api/products/{id}", productHandler.Get)
 	mux.HandleFunc("PUT /api/products/{id}", productHandler.Update)
+	mux.HandleFunc("PATCH /api/products/{id}", productHandler.Patch)
+	mux.HandleFunc("POST /api/products/{id}/categories", productHandler.LinkCategories)
+	mux.HandleFunc("DELETE /api/products/{id}/categories/{categoryId}", productHandler.UnlinkCategory)
 	mux.HandleFunc("DELETE /api/products/{id}", productHandler.Delete)
 
 	mux.HandleFunc("GET /api/categories", categoryHandler.List)
+	mux.HandleFunc("GET /api/categories/tree", categoryHandler.Tree)
 	mux.HandleFunc("POST /api/categories", categoryHandler.Create)
+	mux.HandleFunc("GET /api/categories/{id}", categoryHandler.Get)
 	mux.HandleFunc("PUT /api/categories/{id}", categoryHandler.Update)
+	mux.HandleFunc("PATCH /api/categories/{id}", categoryHandler.Patch)
+	mux.HandleFunc("POST /api/categories/{id}/move", categoryHandler.Move)
 	mux.HandleFunc("DELETE /api/categories/{id}", categoryHandler.Delete)
 
 	mux.HandleFunc("GET /api/collections", collectionHandler.List)
+	mux.HandleFunc("GET /api/collections/tree", collectionHandler.Tree)
 	mux.HandleFunc("POST /api/collections", collectionHandler.Create)
+	mux.HandleFunc("GET /api/collections/{id}", collectionHandler.Get)
 	mux.HandleFunc("PUT /api/collections/{id}", collectionHandler.Update)
+	mux.HandleFunc("PATCH /api/collections/{id}", collectionHandler.Patch)
+	mux.HandleFunc("POST /api/collections/{id}/products", collectionHandler.AddProducts)
+	mux.HandleFunc("DELETE /api/collections/{id}/products/{productId}", collectionHandler.RemoveProduct)
+	mux.HandleFunc("POST /api/collections/{id}/move", collectionHandler.Move)
 	mux.HandleFunc("DELETE /api/collections/{id}", collectionHandler.Delete)
 
 	mux.HandleFunc("GET /api/shops", shopHandler.List)
@@ -85,13 +261,20 @@ func New(addr, dbPath string) (*Server, error) {
 	mux.HandleFunc("GET /api/shops/{id}", shopHandler.Get)
 	mux.HandleFunc("GET /api/shops/{id}/products", shopHandler.Products)
 	mux.HandleFunc("GET /api/shops/{id}/categories", shopHandler.Categories)
+	mux.HandleFunc("GET /api/shops/{id}/suggest", shopHandler.Suggest)
 	mux.HandleFunc("PUT /api/shops/{id}", shopHandler.Update)
+	mux.HandleFunc("PATCH /api/shops/{id}", shopHandler.Patch)
+	mux.HandleFunc("POST /api/shops/{id}/collections", shopHandler.AddCollections)
+	mux.HandleFunc("DELETE /api/shops/{id}/collections/{collectionId}", shopHandler.RemoveCollection)
 	mux.HandleFunc("DELETE /api/shops/{id}", shopHandler.Delete)
 
+	mux.HandleFunc("GET /api/export", snapshotHandler.Export)
+	mux.HandleFunc("POST /api/import", snapshotHandler.Restore)
+
 	handler := corsMiddleware(mux)
 
 	s.httpServer = &http.Server{
-		Addr:         addr,
+		Addr:         cfg.Addr,
 		Handler:      handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -129,6 +312,11 @@ func (s *Server) StartWithGracefulShutdown() error {
 		if err := s.httpServer.Shutdown(ctx); err != nil {
 			return err
 		}
+		if s.db != nil {
+			if err := s.db.Close(); err != nil {
+				return err
+			}
+		}
 		log.Println("Server gracefully stopped")
 		return nil
 	}
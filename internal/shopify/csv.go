@@ -0,0 +1,272 @@
+// Package shopify reads and writes Shopify's product CSV. Imports run through
+// productimport, so products, categories and collections are created by the
+// commands of their packages.
+package shopify
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/validate"
+	"categories-test/internal/productimport"
+	"categories-test/internal/products"
+	"categories-test/internal/snapshot"
+)
+
+// Columns written by WriteCSV, in Shopify's spelling. Imports read Handle,
+// Title, Body (HTML), Type, Tags, Variant Price and Collection, matched
+// case-insensitively, and ignore the others.
+var columns = []string{
+	"Handle", "Title", "Body (HTML)", "Vendor", "Type", "Tags", "Published",
+	"Option1 Name", "Option1 Value", "Variant Price", "Status", "Collection",
+}
+
+// tagSeparator separates the tags and collection names of a cell.
+const tagSeparator = ","
+
+// Parsers lets a productimport.HTTPHandler read Shopify's product CSV.
+var Parsers = productimport.Parsers{productimport.CSVContentType: ParseCSV}
+
+// ParseCSV reads products from Shopify's product CSV. Lines sharing a handle
+// are the variants of one product, which takes its title, body, type, tags
+// and collections from the first line and its price from the first variant
+// that has one. The type and tags become category paths; the comma-separated
+// names of the Collection column become collections.
+func ParseCSV(r io.Reader) ([]productimport.Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: missing header row", productimport.ErrInvalidFile)
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	indexes := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// Spreadsheets often save CSV with a byte order mark.
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := indexes[name]; !ok {
+			indexes[name] = i
+		}
+	}
+	for _, name := range []string{"handle", "title"} {
+		if _, ok := indexes[name]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", productimport.ErrInvalidFile, name)
+		}
+	}
+
+	var rows []productimport.Row
+	byHandle := make(map[string]int)
+	priced := make(map[int]bool)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		line, _ := reader.FieldPos(0)
+		cell := func(name string) string {
+			if i, ok := indexes[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		handle := cell("handle")
+		n, ok := byHandle[handle]
+		if handle == "" || !ok {
+			rows = append(rows, newRow(line, cell))
+			if len(rows) > productimport.MaxRows {
+				return nil, fmt.Errorf("%w: at most %d are allowed", productimport.ErrTooManyRows, productimport.MaxRows)
+			}
+			n = len(rows) - 1
+			if handle != "" {
+				byHandle[handle] = n
+			}
+		}
+		if price := cell("variant price"); price != "" && !priced[n] {
+			priced[n] = true
+			setPrice(&rows[n], price)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no products to import", productimport.ErrInvalidFile)
+	}
+	return rows, nil
+}
+
+func newRow(line int, cell func(name string) string) productimport.Row {
+	row := productimport.Row{
+		Line:        line,
+		Name:        cell("title"),
+		Description: plainText(cell("body (html)")),
+		Collections: splitList(cell("collection")),
+	}
+	if cell("handle") == "" {
+		row.Errors = append(row.Errors, validate.FieldError{Field: "handle", Code: "required", Message: "must not be empty"})
+	}
+	if productType := cell("type"); productType != "" {
+		row.Categories = append(row.Categories, productType)
+	}
+	for _, tag := range splitList(cell("tags")) {
+		if !slices.Contains(row.Categories, tag) {
+			row.Categories = append(row.Categories, tag)
+		}
+	}
+	return row
+}
+
+func setPrice(row *productimport.Row, price string) {
+	parsed, err := strconv.ParseFloat(price, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		row.Errors = append(row.Errors, validate.FieldError{Field: "price", Code: "invalid", Message: fmt.Sprintf("%q is not a number", price)})
+		return
+	}
+	row.Price = parsed
+}
+
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%w: %v", productimport.ErrInvalidFile, err)
+	}
+	return err
+}
+
+func splitList(cell string) []string {
+	var values []string
+	for _, value := range strings.Split(cell, tagSeparator) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// plainText drops the tags of an HTML body, decodes its entities and
+// collapses its whitespace.
+func plainText(body string) string {
+	var text strings.Builder
+	inTag := false
+	for _, r := range body {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			text.WriteByte(' ')
+		case !inTag:
+			text.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(text.String())), " ")
+}
+
+// WriteCSV writes one line per product of src in Shopify's product CSV, each
+// product having a single default variant. The first category path is the
+// type and the others are tags. Commas and ">" in category and collection
+// names become spaces, so that ParseCSV reads each name back as one.
+func WriteCSV(w io.Writer, src *snapshot.Source) error {
+	out := csv.NewWriter(w)
+	out.Write(columns)
+
+	listed := listedNames(src)
+	paths := listed.CategoryPaths()
+	collectionNames := listed.CollectionNames()
+	handles := make(map[string]bool)
+	err := src.EachProduct(func(p *products.Product) error {
+		handle := slug(p.Name)
+		if handle == "" {
+			handle = fmt.Sprintf("product-%d", p.ID)
+		}
+		if handles[handle] {
+			handle = fmt.Sprintf("%s-%d", handle, p.ID)
+		}
+		handles[handle] = true
+
+		var productType string
+		var tags []string
+		for n, id := range p.CategoryIDs {
+			if n == 0 {
+				productType = paths[id]
+			} else {
+				tags = append(tags, paths[id])
+			}
+		}
+		var body string
+		if p.Description != "" {
+			body = "<p>" + html.EscapeString(p.Description) + "</p>"
+		}
+
+		return out.Write([]string{
+			handle,
+			p.Name,
+			body,
+			"",
+			productType,
+			strings.Join(tags, tagSeparator+" "),
+			"TRUE",
+			"Title",
+			"Default Title",
+			strconv.FormatFloat(p.Price, 'f', 2, 64),
+			"active",
+			strings.Join(collectionNames[p.ID], tagSeparator+" "),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// listedNames returns src with its category and collection names fit for
+// the lists of a cell.
+func listedNames(src *snapshot.Source) *snapshot.Source {
+	listed := *src
+	listed.Categories = make([]*categories.Category, len(src.Categories))
+	for n, c := range src.Categories {
+		renamed := *c
+		renamed.Name = listName(c.Name)
+		listed.Categories[n] = &renamed
+	}
+	listed.Collections = make([]*collections.Collection, len(src.Collections))
+	for n, c := range src.Collections {
+		renamed := *c
+		renamed.Name = listName(c.Name)
+		listed.Collections[n] = &renamed
+	}
+	return &listed
+}
+
+var listSeparators = strings.NewReplacer(tagSeparator, " ", productimport.PathSeparator, " ")
+
+func listName(name string) string {
+	return strings.Join(strings.Fields(listSeparators.Replace(name)), " ")
+}
+
+// slug lowercases name and joins its letters and digits with hyphens, as
+// Shopify does for handles.
+func slug(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
@@ -0,0 +1,80 @@
+package shopify
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/validate"
+	"categories-test/internal/productimport"
+	"categories-test/internal/products"
+	"categories-test/internal/snapshot"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := "\ufeffHandle,Title,Body (HTML),Vendor,Type,Tags,Option1 Value,Variant Price,Collection\n" +
+		"tee,Tee,\"<p>Soft <b>cotton</b> &amp; linen</p>\",Acme,Shirts,\"Sale, Shirts, Summer\",S,,Summer\n" +
+		"tee,,,,,,M,12.50,\n" +
+		"tee,,,,,,L,14,\n" +
+		"mug,Mug,,,,,,abc,\n" +
+		",Cap,,,,,,3,\n"
+	rows, err := ParseCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	want := []productimport.Row{
+		{Line: 2, Name: "Tee", Description: "Soft cotton & linen", Price: 12.5, Categories: []string{"Shirts", "Sale", "Summer"}, Collections: []string{"Summer"}},
+		{Line: 5, Name: "Mug", Errors: []validate.FieldError{{Field: "price", Code: "invalid", Message: `"abc" is not a number`}}},
+		{Line: 6, Name: "Cap", Price: 3, Errors: []validate.FieldError{{Field: "handle", Code: "required", Message: "must not be empty"}}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %+v, want %+v", rows, want)
+	}
+}
+
+func TestParseCSVRequiresHandleAndTitle(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("Handle,Name\ntee,Tee\n"))
+	if !errors.Is(err, productimport.ErrInvalidFile) {
+		t.Errorf("err = %v, want ErrInvalidFile", err)
+	}
+}
+
+func TestWriteCSVRoundTrip(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	s := &snapshot.Snapshot{
+		Categories: []*categories.Category{
+			{ID: 1, Name: "Clothing"}, {ID: 2, Name: "Shirts", ParentID: intPtr(1)}, {ID: 3, Name: "Sale"},
+			{ID: 4, Name: "Tanks, Tees > Tops", ParentID: intPtr(1)},
+		},
+		Products: []*products.Product{
+			{ID: 1, Name: "Tee, white", Description: "Cotton <3", Price: 12.5, CategoryIDs: []int{2, 3}},
+			{ID: 2, Name: "Tee white", CategoryIDs: []int{}},
+			{ID: 3, Name: "☕", Price: 4, CategoryIDs: []int{3, 4}},
+		},
+		Collections: []*collections.Collection{{ID: 1, Name: "Summer", ProductIDs: []int{1, 3}}, {ID: 2, Name: "Hot, >90°", ProductIDs: []int{3}}},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, s.Source()); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\ntee-white-2,") || !strings.Contains(buf.String(), "\nproduct-3,") {
+		t.Errorf("handles not unique:\n%s", buf.String())
+	}
+
+	rows, err := ParseCSV(&buf)
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	want := []productimport.Row{
+		{Line: 2, Name: "Tee, white", Description: "Cotton <3", Price: 12.5, Categories: []string{"Clothing > Shirts", "Sale"}, Collections: []string{"Summer"}},
+		{Line: 3, Name: "Tee white"},
+		{Line: 4, Name: "☕", Price: 4, Categories: []string{"Sale", "Clothing > Tanks Tees Tops"}, Collections: []string{"Summer", "Hot 90°"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %+v, want %+v", rows, want)
+	}
+}
@@ -0,0 +1,39 @@
+package shopify
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"categories-test/internal/platform/httpx"
+	"categories-test/internal/productimport"
+	"categories-test/internal/snapshot"
+)
+
+type HTTPHandler struct {
+	queries *snapshot.Queries
+}
+
+func NewHTTPHandler(queries *snapshot.Queries) *HTTPHandler {
+	return &HTTPHandler{queries: queries}
+}
+
+// Export streams the products in Shopify's product CSV.
+func (h *HTTPHandler) Export(w http.ResponseWriter, r *http.Request) {
+	sending := false
+	err := h.queries.Export(func(src *snapshot.Source) error {
+		sending = true
+		// Large catalogs take longer to send than the server's write timeout.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", productimport.CSVContentType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="shopify-products.csv"`)
+		return WriteCSV(w, src)
+	})
+	if err != nil && !sending {
+		httpx.WriteError(w, r, err, nil)
+	} else if err != nil {
+		// The status is sent already, so the client only sees a truncated
+		// body.
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
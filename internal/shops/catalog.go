@@ -0,0 +1,285 @@
+package shops
+
+import (
+	"sort"
+
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/search"
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/products"
+)
+
+// catalog is a snapshot of everything a shop can expose, used by the memory
+// repository. The SQL repositories express the same rules in productScope.
+type catalog struct {
+	shop               *Shop
+	productsByID       map[int]*products.Product
+	collectionsByID    map[int]*collections.Collection
+	categoriesByID     map[int]*CategoryView
+	productCategoryIDs map[int][]int
+}
+
+func emptyPage(page, limit int) *PaginatedProducts {
+	return &PaginatedProducts{Products: []*products.Product{}, Page: page, Limit: limit, TotalCount: 0, TotalPages: 0, Facets: emptyFacets()}
+}
+
+// productIDs returns the products visible for the given collection scope.
+// Without an explicit collection a shop exposes its own collections, and a
+// shop without collections exposes every product.
+func (c *catalog) productIDs(collectionID *int, includeDescendants bool) map[int]bool {
+	productMap := make(map[int]bool)
+	addCollection := func(id int) {
+		if coll, ok := c.collectionsByID[id]; ok {
+			for _, pid := range coll.ProductIDs {
+				productMap[pid] = true
+			}
+		}
+	}
+
+	if collectionID != nil {
+		addCollection(*collectionID)
+		if includeDescendants {
+			for _, id := range getDescendantCollectionIDs(c.collectionsByID, *collectionID) {
+				addCollection(id)
+			}
+		}
+	} else if len(c.shop.CollectionIDs) > 0 {
+		for _, id := range c.shop.CollectionIDs {
+			addCollection(id)
+		}
+	} else {
+		for id := range c.productsByID {
+			productMap[id] = true
+		}
+	}
+	return productMap
+}
+
+// matchedProducts returns the products matching filter in the requested
+// order, ties broken by ID. Search scores only exist with a query, so the
+// default order falls back to ID without one.
+func (c *catalog) matchedProducts(filter ProductFilter) []*products.Product {
+	productMap := c.productIDs(filter.CollectionID, true)
+
+	categorySets := make([]map[int]bool, 0, len(filter.CategoryIDs))
+	for _, categoryID := range filter.CategoryIDs {
+		catSet := map[int]bool{categoryID: true}
+		for _, cid := range getDescendantCategoryIDs(c.categoriesByID, categoryID) {
+			catSet[cid] = true
+		}
+		categorySets = append(categorySets, catSet)
+	}
+
+	terms := search.Terms(filter.Query)
+	scores := make(map[int]float64)
+	matchedProducts := make([]*products.Product, 0, len(productMap))
+	for pid := range productMap {
+		p, ok := c.productsByID[pid]
+		if !ok || !c.matchesCategories(pid, categorySets, filter.MatchAllCategories) {
+			continue
+		}
+		if len(terms) > 0 {
+			score, ok := search.Score(terms, p.Name, p.Description)
+			if !ok {
+				continue
+			}
+			scores[pid] = score
+		}
+		if (filter.MinPrice != nil && p.Price < *filter.MinPrice) || (filter.MaxPrice != nil && p.Price > *filter.MaxPrice) {
+			continue
+		}
+		matched := *p
+		matched.CategoryIDs = append([]int{}, c.productCategoryIDs[pid]...)
+		sort.Ints(matched.CategoryIDs)
+		matchedProducts = append(matchedProducts, &matched)
+	}
+	sort.Slice(matchedProducts, func(i, j int) bool {
+		a, b := matchedProducts[i], matchedProducts[j]
+		switch filter.Sort {
+		case SortPriceAsc:
+			if a.Price != b.Price {
+				return a.Price < b.Price
+			}
+		case SortPriceDesc:
+			if a.Price != b.Price {
+				return a.Price > b.Price
+			}
+		case SortName:
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		case SortNewest:
+			return a.ID > b.ID
+		case SortByID:
+			if scores[a.ID] != scores[b.ID] {
+				return scores[a.ID] > scores[b.ID]
+			}
+		}
+		return a.ID < b.ID
+	})
+	return matchedProducts
+}
+
+func (c *catalog) matchesCategories(productID int, categorySets []map[int]bool, matchAll bool) bool {
+	if len(categorySets) == 0 {
+		return true
+	}
+	for _, catSet := range categorySets {
+		found := false
+		for _, cid := range c.productCategoryIDs[productID] {
+			if catSet[cid] {
+				found = true
+				break
+			}
+		}
+		if found && !matchAll {
+			return true
+		}
+		if !found && matchAll {
+			return false
+		}
+	}
+	return matchAll
+}
+
+func (c *catalog) products(filter ProductFilter, page, limit int) *PaginatedProducts {
+	matchedProducts := c.matchedProducts(filter)
+	totalCount := len(matchedProducts)
+	totalPages := (totalCount + limit - 1) / limit
+	start := (page - 1) * limit
+	end := start + limit
+	if start > totalCount {
+		start = totalCount
+	}
+	if end > totalCount {
+		end = totalCount
+	}
+
+	paged := matchedProducts
+	if start < end {
+		paged = matchedProducts[start:end]
+	} else {
+		paged = []*products.Product{}
+	}
+
+	return &PaginatedProducts{
+		Products:   paged,
+		Page:       page,
+		Limit:      limit,
+		TotalCount: totalCount,
+		TotalPages: totalPages,
+		Facets:     c.facets(matchedProducts),
+	}
+}
+
+func (c *catalog) facets(matched []*products.Product) Facets {
+	categoryCounts := make(map[int]int)
+	bucketCounts := make([]int, len(priceBucketBounds)+1)
+	for _, p := range matched {
+		for _, cid := range p.CategoryIDs {
+			categoryCounts[cid]++
+		}
+		bucketCounts[priceBucketIndex(p.Price)]++
+	}
+
+	facets := Facets{Categories: make([]CategoryFacet, 0, len(categoryCounts)), PriceBuckets: priceBuckets(bucketCounts)}
+	for cid, count := range categoryCounts {
+		if category, ok := c.categoriesByID[cid]; ok {
+			facets.Categories = append(facets.Categories, CategoryFacet{CategoryID: cid, Name: category.Name, Count: count})
+		}
+	}
+	sort.Slice(facets.Categories, func(i, j int) bool { return facets.Categories[i].CategoryID < facets.Categories[j].CategoryID })
+	return facets
+}
+
+func (c *catalog) categories(collectionID *int, directOnly bool) []*CategoryView {
+	productMap := c.productIDs(collectionID, !directOnly)
+
+	catMap := make(map[int]*CategoryView)
+	for pid := range productMap {
+		for _, cid := range c.productCategoryIDs[pid] {
+			if category, ok := c.categoriesByID[cid]; ok {
+				catMap[cid] = category
+			}
+		}
+	}
+
+	result := make([]*CategoryView, 0, len(catMap))
+	for _, category := range catMap {
+		result = append(result, category)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func getDescendantCollectionIDs(items map[int]*collections.Collection, parentID int) []int {
+	parents := make(tree.Parents, len(items))
+	for id, c := range items {
+		parents[id] = c.ParentID
+	}
+	return parents.Descendants(parentID)
+}
+
+func getDescendantCategoryIDs(items map[int]*CategoryView, parentID int) []int {
+	parents := make(tree.Parents, len(items))
+	for id, c := range items {
+		parents[id] = c.ParentID
+	}
+	return parents.Descendants(parentID)
+}
+
+// fuzzySuggestions returns up to limit candidates whose name matches every
+// term, exact prefix matches first, in candidate order otherwise.
+func fuzzySuggestions(candidates []Suggestion, terms []string, limit int) []Suggestion {
+	exact := make([]Suggestion, 0)
+	typos := make([]Suggestion, 0)
+	for _, candidate := range candidates {
+		if _, ok := search.Score(terms, candidate.Name, ""); ok {
+			exact = append(exact, candidate)
+		} else if search.Fuzzy(candidate.Name, terms) {
+			typos = append(typos, candidate)
+		}
+	}
+	result := append(exact, typos...)
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (c *catalog) suggestions(terms []string, limit int) *Suggestions {
+	result := emptySuggestions()
+
+	productCandidates := make([]Suggestion, 0)
+	for _, p := range c.matchedProducts(ProductFilter{}) {
+		productCandidates = append(productCandidates, Suggestion{ID: p.ID, Name: p.Name})
+	}
+	result.Products = fuzzySuggestions(productCandidates, terms, limit)
+
+	categoryCandidates := make([]Suggestion, 0)
+	for _, category := range c.categories(nil, false) {
+		categoryCandidates = append(categoryCandidates, Suggestion{ID: category.ID, Name: category.Name})
+	}
+	result.Categories = fuzzySuggestions(categoryCandidates, terms, limit)
+
+	collectionIDs := make([]int, 0)
+	if len(c.shop.CollectionIDs) > 0 {
+		for _, id := range c.shop.CollectionIDs {
+			collectionIDs = append(collectionIDs, id)
+			collectionIDs = append(collectionIDs, getDescendantCollectionIDs(c.collectionsByID, id)...)
+		}
+	} else {
+		for id := range c.collectionsByID {
+			collectionIDs = append(collectionIDs, id)
+		}
+	}
+	sort.Ints(collectionIDs)
+	collectionCandidates := make([]Suggestion, 0, len(collectionIDs))
+	for i, id := range collectionIDs {
+		if coll, ok := c.collectionsByID[id]; ok && (i == 0 || collectionIDs[i-1] != id) {
+			collectionCandidates = append(collectionCandidates, Suggestion{ID: id, Name: coll.Name})
+		}
+	}
+	result.Collections = fuzzySuggestions(collectionCandidates, terms, limit)
+	return result
+}
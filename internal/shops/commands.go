@@ -1,5 +1,7 @@
 package shops
 
+import "categories-test/internal/platform/validate"
+
 type Commands struct {
 	repo CommandRepository
 }
@@ -9,13 +11,44 @@ func NewCommands(repo CommandRepository) *Commands {
 }
 
 func (c *Commands) Create(shop *Shop) (*Shop, error) {
+	if err := c.validate(shop); err != nil {
+		return nil, err
+	}
 	return c.repo.CreateShop(shop)
 }
 
 func (c *Commands) Update(shop *Shop) (*Shop, error) {
+	if err := c.validate(shop); err != nil {
+		return nil, err
+	}
 	return c.repo.UpdateShop(shop)
 }
 
-func (c *Commands) Delete(id int) error {
-	return c.repo.DeleteShop(id)
+func (c *Commands) Delete(id, version int) error {
+	return c.repo.DeleteShop(id, version)
+}
+
+// AddCollections adds collections to the shop, keeping those it has.
+func (c *Commands) AddCollections(id, version int, collectionIDs []int) (*Shop, error) {
+	var errs validate.Errors
+	if err := errs.Links("collectionIds", collectionIDs, c.repo.MissingCollectionIDs); err != nil {
+		return nil, err
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return c.repo.UpdateShopCollections(CollectionLinks{ShopID: id, Add: collectionIDs, Version: version})
+}
+
+func (c *Commands) RemoveCollection(id, version, collectionID int) (*Shop, error) {
+	return c.repo.UpdateShopCollections(CollectionLinks{ShopID: id, Remove: []int{collectionID}, Version: version})
+}
+
+func (c *Commands) validate(shop *Shop) error {
+	var errs validate.Errors
+	errs.Name("name", shop.Name)
+	if err := errs.References("collectionIds", shop.CollectionIDs, c.repo.MissingCollectionIDs); err != nil {
+		return err
+	}
+	return errs.Err()
 }
@@ -0,0 +1,54 @@
+package shops
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/validate"
+)
+
+func TestCommandsValidate(t *testing.T) {
+	store := memory.NewStore()
+	store.Write(func(t *memory.Tables) error {
+		t.Collections[1] = &memory.CollectionRow{ID: 1, Name: "Summer"}
+		return nil
+	})
+	commands := NewCommands(NewMemoryRepository(store))
+
+	tests := []struct {
+		name string
+		shop Shop
+		want []string
+	}{
+		{"valid", Shop{Name: "Outlet", CollectionIDs: []int{1}}, nil},
+		{"without collections", Shop{Name: "Everything"}, nil},
+		{"blank name", Shop{}, []string{"name:required"}},
+		{"unknown collection", Shop{Name: "Outlet", CollectionIDs: []int{404}}, []string{"collectionIds:not_found"}},
+		{"repeated collection", Shop{Name: "Outlet", CollectionIDs: []int{1, 1}}, []string{"collectionIds:duplicate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shop := tt.shop
+			_, err := commands.Create(&shop)
+			if got := fieldCodes(err); !slices.Equal(got, tt.want) {
+				t.Errorf("Create error = %v, want fields %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// fieldCodes lists the "field:code" pairs of a validation error in order, or
+// nil when err is not one.
+func fieldCodes(err error) []string {
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	codes := make([]string, 0, len(invalid.Fields))
+	for _, field := range invalid.Fields {
+		codes = append(codes, field.Field+":"+field.Code)
+	}
+	return codes
+}
@@ -2,4 +2,9 @@ package shops
 
 import "errors"
 
-var ErrNotFound = errors.New("shop not found")
+var (
+	ErrNotFound            = errors.New("shop not found")
+	ErrInvalidCollection   = errors.New("shop references unknown collection")
+	ErrDuplicateCollection = errors.New("shop lists a collection more than once")
+	ErrVersionMismatch     = errors.New("shop was modified since it was read")
+)
@@ -1,8 +1,9 @@
 package shops
 
 import (
-	"errors"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -20,10 +21,23 @@ func NewHTTPHandler(commands *Commands, queries *Queries) *HTTPHandler {
 	return &HTTPHandler{commands: commands, queries: queries}
 }
 
+// errorMappings maps shop errors to problem responses.
+var errorMappings = []httpx.ErrorMapping{
+	{Err: ErrNotFound, Status: http.StatusNotFound, Code: "shop_not_found"},
+	{Err: ErrInvalidCollection, Status: http.StatusUnprocessableEntity, Code: "unknown_collection", Field: "collectionIds"},
+	{Err: ErrDuplicateCollection, Status: http.StatusUnprocessableEntity, Code: "duplicate_collection", Field: "collectionIds"},
+	{Err: ErrVersionMismatch, Status: http.StatusPreconditionFailed, Code: "version_mismatch"},
+}
+
 type shopDTO struct {
 	ID            int    `json:"id"`
 	Name          string `json:"name"`
 	CollectionIDs []int  `json:"collectionIds"`
+	Version       int    `json:"version"`
+}
+
+type collectionLinksDTO struct {
+	CollectionIDs []int `json:"collectionIds"`
 }
 
 type productDTO struct {
@@ -46,10 +60,47 @@ type paginatedProductsDTO struct {
 	Limit      int          `json:"limit"`
 	TotalCount int          `json:"totalCount"`
 	TotalPages int          `json:"totalPages"`
+	Facets     facetsDTO    `json:"facets"`
+}
+
+type facetsDTO struct {
+	Categories   []categoryFacetDTO `json:"categories"`
+	PriceBuckets []priceBucketDTO   `json:"priceBuckets"`
+}
+
+type categoryFacetDTO struct {
+	CategoryID int    `json:"categoryId"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+}
+
+type priceBucketDTO struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+}
+
+type suggestionDTO struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type suggestionsDTO struct {
+	Products    []suggestionDTO `json:"products"`
+	Categories  []suggestionDTO `json:"categories"`
+	Collections []suggestionDTO `json:"collections"`
+}
+
+func toSuggestionDTOs(items []Suggestion) []suggestionDTO {
+	dtos := make([]suggestionDTO, 0, len(items))
+	for _, s := range items {
+		dtos = append(dtos, suggestionDTO{ID: s.ID, Name: s.Name})
+	}
+	return dtos
 }
 
 func toShopDTO(s *Shop) shopDTO {
-	return shopDTO{ID: s.ID, Name: s.Name, CollectionIDs: s.CollectionIDs}
+	return shopDTO{ID: s.ID, Name: s.Name, CollectionIDs: s.CollectionIDs, Version: s.Version}
 }
 
 func fromShopDTO(dto shopDTO) Shop {
@@ -75,10 +126,30 @@ func toPaginatedProductsDTO(value *PaginatedProducts) paginatedProductsDTO {
 		Limit:      value.Limit,
 		TotalCount: value.TotalCount,
 		TotalPages: value.TotalPages,
+		Facets:     toFacetsDTO(value.Facets),
 	}
 }
 
+func toFacetsDTO(value Facets) facetsDTO {
+	dto := facetsDTO{
+		Categories:   make([]categoryFacetDTO, 0, len(value.Categories)),
+		PriceBuckets: make([]priceBucketDTO, 0, len(value.PriceBuckets)),
+	}
+	for _, f := range value.Categories {
+		dto.Categories = append(dto.Categories, categoryFacetDTO{CategoryID: f.CategoryID, Name: f.Name, Count: f.Count})
+	}
+	for _, b := range value.PriceBuckets {
+		dto.PriceBuckets = append(dto.PriceBuckets, priceBucketDTO{Min: b.Min, Max: b.Max, Count: b.Count})
+	}
+	return dto
+}
+
 func (h *HTTPHandler) List(w http.ResponseWriter, r *http.Request) {
+	if httpx.WantsCursor(r.URL.Query()) {
+		h.listAfter(w, r)
+		return
+	}
+
 	shops := h.queries.List()
 	response := make([]shopDTO, 0, len(shops))
 	for _, shop := range shops {
@@ -87,40 +158,56 @@ func (h *HTTPHandler) List(w http.ResponseWriter, r *http.Request) {
 	httpx.WriteJSON(w, response)
 }
 
+func (h *HTTPHandler) listAfter(w http.ResponseWriter, r *http.Request) {
+	cursor, err := httpx.ParseCursor(r.URL.Query())
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	shops, hasMore := h.queries.ListAfter(cursor.After, cursor.Limit)
+	response := httpx.CursorList[shopDTO]{Items: make([]shopDTO, 0, len(shops))}
+	for _, shop := range shops {
+		response.Items = append(response.Items, toShopDTO(shop))
+	}
+	if len(shops) > 0 {
+		response.NextCursor = httpx.NextCursor(shops[len(shops)-1].ID, hasMore)
+	}
+	httpx.WriteJSON(w, response)
+}
+
 func (h *HTTPHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	shop, err := h.queries.Get(id)
 	if err != nil {
-		if errors.Is(err, ErrNotFound) {
-			http.Error(w, "Shop not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to load shop", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, shop.Version)
 	httpx.WriteJSON(w, toShopDTO(shop))
 }
 
 func (h *HTTPHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var payload shopDTO
 	if err := httpx.ReadJSON(r, &payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	shop := fromShopDTO(payload)
 	created, err := h.commands.Create(&shop)
 	if err != nil {
-		http.Error(w, "Failed to persist shop", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, created.Version)
 	w.WriteHeader(http.StatusCreated)
 	httpx.WriteJSON(w, toShopDTO(created))
 }
@@ -128,40 +215,142 @@ func (h *HTTPHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	var payload shopDTO
 	if err := httpx.ReadJSON(r, &payload); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	shop := fromShopDTO(payload)
 	shop.ID = id
+	shop.Version = version
 	updated, err := h.commands.Update(&shop)
 	if err != nil {
-		http.Error(w, "Failed to persist shop", http.StatusInternalServerError)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
+	httpx.SetETag(w, updated.Version)
 	httpx.WriteJSON(w, toShopDTO(updated))
 }
 
+// Patch applies a JSON merge patch to the shop, so fields the patch leaves out
+// keep their values.
+func (h *HTTPHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	current, err := h.queries.Get(id)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	payload := toShopDTO(current)
+	if err := httpx.ReadMergePatch(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	shop := fromShopDTO(payload)
+	shop.ID = id
+	shop.Version = httpx.PatchVersion(version, current.Version)
+	updated, err := h.commands.Update(&shop)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, updated.Version)
+	httpx.WriteJSON(w, toShopDTO(updated))
+}
+
+// AddCollections adds collections to the shop, keeping the ones it has.
+func (h *HTTPHandler) AddCollections(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.OptionalIfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	var payload collectionLinksDTO
+	if err := httpx.ReadJSON(r, &payload); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	shop, err := h.commands.AddCollections(id, version, payload.CollectionIDs)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, shop.Version)
+	httpx.WriteJSON(w, toShopDTO(shop))
+}
+
+func (h *HTTPHandler) RemoveCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	collectionID, err := httpx.ParseLinkedID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.OptionalIfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	shop, err := h.commands.RemoveCollection(id, version, collectionID)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	httpx.SetETag(w, shop.Version)
+	httpx.WriteJSON(w, toShopDTO(shop))
+}
+
 func (h *HTTPHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	version, err := httpx.IfMatch(r, h.currentVersion(id))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
-	if err := h.commands.Delete(id); err != nil {
-		if errors.Is(err, ErrNotFound) {
-			http.Error(w, "Shop not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to persist shop", http.StatusInternalServerError)
+	if err := h.commands.Delete(id, version); err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
@@ -171,58 +360,74 @@ func (h *HTTPHandler) Delete(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) Products(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	if !strings.HasSuffix(r.URL.Path, "/products") {
-		http.Error(w, "Not found", http.StatusNotFound)
+		httpx.WriteError(w, r, httpx.ErrRouteNotFound, errorMappings)
 		return
 	}
 
-	collectionID := r.URL.Query().Get("collection")
-	categoryID := r.URL.Query().Get("category")
+	filter, err := parseProductFilter(r.URL.Query())
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
 	page := 1
-	limit := 10
+	limit := httpx.ParseLimit(r.URL.Query().Get("limit"))
 
 	if p := r.URL.Query().Get("page"); p != "" {
 		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
 			page = parsed
 		}
 	}
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
-			limit = parsed
-		}
-	}
 
-	var collID *int
-	if collectionID != "" {
-		if parsed, err := strconv.Atoi(collectionID); err == nil {
-			collID = &parsed
+	// Passing after, even empty, switches to cursor mode; page mode stays the
+	// default for existing clients.
+	if r.URL.Query().Has("after") {
+		if filter.Sort != SortByID {
+			httpx.WriteError(w, r, httpx.InvalidParam("sort", "sorting is not supported with cursor pagination"), errorMappings)
+			return
 		}
-	}
-
-	var catID *int
-	if categoryID != "" {
-		if parsed, err := strconv.Atoi(categoryID); err == nil {
-			catID = &parsed
+		cursor, err := httpx.ParseCursor(r.URL.Query())
+		if err != nil {
+			httpx.WriteError(w, r, err, errorMappings)
+			return
+		}
+		items, hasMore, err := h.queries.ProductsAfter(id, filter, cursor.After, cursor.Limit)
+		if err != nil {
+			httpx.WriteError(w, r, err, errorMappings)
+			return
 		}
+		response := httpx.CursorList[productDTO]{Items: make([]productDTO, 0, len(items))}
+		for _, product := range items {
+			response.Items = append(response.Items, toProductDTO(product))
+		}
+		if len(items) > 0 {
+			response.NextCursor = httpx.NextCursor(items[len(items)-1].ID, hasMore)
+		}
+		httpx.WriteJSON(w, response)
+		return
 	}
 
-	result := h.queries.Products(id, collID, catID, page, limit)
+	result, err := h.queries.Products(id, filter, page, limit)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
 	httpx.WriteJSON(w, toPaginatedProductsDTO(result))
 }
 
 func (h *HTTPHandler) Categories(w http.ResponseWriter, r *http.Request) {
 	id, err := httpx.ParseID(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpx.WriteError(w, r, err, errorMappings)
 		return
 	}
 
 	if !strings.HasSuffix(r.URL.Path, "/categories") {
-		http.Error(w, "Not found", http.StatusNotFound)
+		httpx.WriteError(w, r, httpx.ErrRouteNotFound, errorMappings)
 		return
 	}
 
@@ -236,10 +441,95 @@ func (h *HTTPHandler) Categories(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	categories := h.queries.Categories(id, collID, directOnly)
+	categories, err := h.queries.Categories(id, collID, directOnly)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
 	response := make([]categoryDTO, 0, len(categories))
 	for _, category := range categories {
 		response = append(response, toCategoryDTO(category))
 	}
 	httpx.WriteJSON(w, response)
 }
+
+func (h *HTTPHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.ParseID(r.URL.Path)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	result, err := h.queries.Suggest(id, r.URL.Query().Get("q"), httpx.ParseLimit(r.URL.Query().Get("limit")))
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+	httpx.WriteJSON(w, suggestionsDTO{
+		Products:    toSuggestionDTOs(result.Products),
+		Categories:  toSuggestionDTOs(result.Categories),
+		Collections: toSuggestionDTOs(result.Collections),
+	})
+}
+
+// parseProductFilter reads q, collection, category (repeatable), categoryMatch,
+// minPrice, maxPrice and sort. Unparsable collection and category IDs are
+// ignored, as they always have been.
+func parseProductFilter(query url.Values) (ProductFilter, error) {
+	filter := ProductFilter{Query: query.Get("q")}
+
+	if parsed, err := strconv.Atoi(query.Get("collection")); err == nil {
+		filter.CollectionID = &parsed
+	}
+	for _, value := range query["category"] {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			filter.CategoryIDs = append(filter.CategoryIDs, parsed)
+		}
+	}
+
+	switch query.Get("categoryMatch") {
+	case "", "any":
+	case "all":
+		filter.MatchAllCategories = true
+	default:
+		return ProductFilter{}, httpx.InvalidParam("categoryMatch", "must be any or all")
+	}
+
+	var err error
+	if filter.MinPrice, err = parsePrice(query, "minPrice"); err != nil {
+		return ProductFilter{}, err
+	}
+	if filter.MaxPrice, err = parsePrice(query, "maxPrice"); err != nil {
+		return ProductFilter{}, err
+	}
+
+	filter.Sort = ProductSort(query.Get("sort"))
+	if !filter.Sort.Valid() {
+		return ProductFilter{}, httpx.InvalidParam("sort", "must be price_asc, price_desc, name or newest")
+	}
+	return filter, nil
+}
+
+func parsePrice(query url.Values, name string) (*float64, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return nil, httpx.InvalidParam(name, "must be a number")
+	}
+	return &parsed, nil
+}
+
+// currentVersion reads the shop's version for an If-Match header listing
+// several entity tags.
+func (h *HTTPHandler) currentVersion(id int) func() (int, error) {
+	return func() (int, error) {
+		s, err := h.queries.Get(id)
+		if err != nil {
+			return 0, err
+		}
+		return s.Version, nil
+	}
+}
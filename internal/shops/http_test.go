@@ -0,0 +1,49 @@
+package shops
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"categories-test/internal/platform/httpx"
+	"categories-test/internal/platform/memory"
+)
+
+func TestHandlerReportsMissingShops(t *testing.T) {
+	repo := NewMemoryRepository(memory.NewStore())
+	handler := NewHTTPHandler(NewCommands(repo), NewQueries(repo))
+
+	tests := []struct {
+		name  string
+		path  string
+		serve http.HandlerFunc
+	}{
+		{"products", "/api/shops/404/products", handler.Products},
+		{"products after", "/api/shops/404/products?after=", handler.Products},
+		{"categories", "/api/shops/404/categories", handler.Categories},
+		{"suggest", "/api/shops/404/suggest?q=tee", handler.Suggest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.serve(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", tt.name, rec.Code)
+		}
+	}
+}
+
+func TestParseProductFilterRejectsNonFinitePrices(t *testing.T) {
+	for _, query := range []string{"minPrice=NaN", "maxPrice=Inf", "minPrice=-infinity", "maxPrice=abc"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseProductFilter(values); !errors.Is(err, httpx.ErrInvalidParam) {
+			t.Errorf("%s: err = %v, want an invalid parameter", query, err)
+		}
+	}
+	values, _ := url.ParseQuery("minPrice=1.5&maxPrice=20")
+	filter, err := parseProductFilter(values)
+	if err != nil || *filter.MinPrice != 1.5 || *filter.MaxPrice != 20 {
+		t.Errorf("parseProductFilter = %+v, %v", filter, err)
+	}
+}
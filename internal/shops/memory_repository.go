@@ -0,0 +1,222 @@
+package shops
+
+import (
+	"sort"
+
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/products"
+)
+
+type MemoryRepository struct {
+	store *memory.Store
+}
+
+func NewMemoryRepository(store *memory.Store) *MemoryRepository {
+	return &MemoryRepository{store: store}
+}
+
+func (r *MemoryRepository) GetShops() []*Shop {
+	var items []*Shop
+	r.store.Read(func(t *memory.Tables) {
+		items = make([]*Shop, 0, len(t.Shops))
+		for _, row := range t.Shops {
+			items = append(items, toShop(row))
+		}
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func (r *MemoryRepository) GetShopsAfter(after, limit int) []*Shop {
+	return memory.PageAfter(r.GetShops(), func(s *Shop) int { return s.ID }, after, limit)
+}
+
+func (r *MemoryRepository) GetShop(id int) (*Shop, error) {
+	var shop *Shop
+	r.store.Read(func(t *memory.Tables) {
+		if row, ok := t.Shops[id]; ok {
+			shop = toShop(row)
+		}
+	})
+	if shop == nil {
+		return nil, ErrNotFound
+	}
+	if shop.CollectionIDs == nil {
+		shop.CollectionIDs = []int{}
+	}
+	return shop, nil
+}
+
+func (r *MemoryRepository) CreateShop(s *Shop) (*Shop, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		if err := validateCollectionLinks(t, s.CollectionIDs); err != nil {
+			return err
+		}
+		s.ID = t.NextID("shops")
+		s.Version = 1
+		t.Shops[s.ID] = fromShop(s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *MemoryRepository) UpdateShop(s *Shop) (*Shop, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Shops[s.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, s.Version) {
+			return ErrVersionMismatch
+		}
+		if err := validateCollectionLinks(t, s.CollectionIDs); err != nil {
+			return err
+		}
+		s.Version = row.Version + 1
+		t.Shops[s.ID] = fromShop(s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *MemoryRepository) DeleteShop(id, version int) error {
+	return r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Shops[id]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, version) {
+			return ErrVersionMismatch
+		}
+		delete(t.Shops, id)
+		return nil
+	})
+}
+
+func (r *MemoryRepository) UpdateShopCollections(links CollectionLinks) (*Shop, error) {
+	err := r.store.Write(func(t *memory.Tables) error {
+		row, ok := t.Shops[links.ShopID]
+		if !ok {
+			return ErrNotFound
+		}
+		if !memory.VersionMatches(row.Version, links.Version) {
+			return ErrVersionMismatch
+		}
+		for _, collectionID := range links.Add {
+			if _, ok := t.Collections[collectionID]; !ok {
+				return ErrInvalidCollection
+			}
+		}
+		for _, collectionID := range links.Add {
+			if !memory.ContainsInt(row.CollectionIDs, collectionID) {
+				row.CollectionIDs = append(row.CollectionIDs, collectionID)
+			}
+		}
+		for _, collectionID := range links.Remove {
+			row.CollectionIDs = memory.RemoveInt(row.CollectionIDs, collectionID)
+		}
+		row.Version++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetShop(links.ShopID)
+}
+
+func (r *MemoryRepository) MissingCollectionIDs(ids []int) ([]int, error) {
+	var missing []int
+	r.store.Read(func(t *memory.Tables) {
+		missing = memory.MissingIDs(t.Collections, ids)
+	})
+	return missing, nil
+}
+
+func (r *MemoryRepository) GetShopProducts(shopID int, filter ProductFilter, page, limit int) *PaginatedProducts {
+	shop, err := r.GetShop(shopID)
+	if err != nil {
+		return emptyPage(page, limit)
+	}
+	return r.loadCatalog(shop).products(filter, page, limit)
+}
+
+func (r *MemoryRepository) GetShopProductsAfter(shopID int, filter ProductFilter, after, limit int) []*products.Product {
+	shop, err := r.GetShop(shopID)
+	if err != nil {
+		return []*products.Product{}
+	}
+	filter.Sort = SortByID
+	matched := r.loadCatalog(shop).matchedProducts(filter)
+	return memory.PageAfter(matched, func(p *products.Product) int { return p.ID }, after, limit)
+}
+
+func (r *MemoryRepository) GetShopCategories(shopID int, collectionID *int, directOnly bool) []*CategoryView {
+	shop, err := r.GetShop(shopID)
+	if err != nil {
+		return []*CategoryView{}
+	}
+	return r.loadCatalog(shop).categories(collectionID, directOnly)
+}
+
+func (r *MemoryRepository) GetShopSuggestions(shopID int, terms []string, limit int) *Suggestions {
+	shop, err := r.GetShop(shopID)
+	if err != nil {
+		return emptySuggestions()
+	}
+	return r.loadCatalog(shop).suggestions(terms, limit)
+}
+
+func (r *MemoryRepository) loadCatalog(shop *Shop) *catalog {
+	c := &catalog{
+		shop:               shop,
+		productsByID:       make(map[int]*products.Product),
+		collectionsByID:    make(map[int]*collections.Collection),
+		categoriesByID:     make(map[int]*CategoryView),
+		productCategoryIDs: make(map[int][]int),
+	}
+	r.store.Read(func(t *memory.Tables) {
+		for id, row := range t.Products {
+			c.productsByID[id] = &products.Product{ID: row.ID, Name: row.Name, Description: row.Description, Price: row.Price}
+			c.productCategoryIDs[id] = append([]int{}, row.CategoryIDs...)
+		}
+		for id, row := range t.Collections {
+			c.collectionsByID[id] = &collections.Collection{
+				ID:         row.ID,
+				Name:       row.Name,
+				ParentID:   memory.CopyIntPtr(row.ParentID),
+				ProductIDs: append([]int{}, row.ProductIDs...),
+			}
+		}
+		for id, row := range t.Categories {
+			c.categoriesByID[id] = &CategoryView{ID: row.ID, Name: row.Name, ParentID: memory.CopyIntPtr(row.ParentID)}
+		}
+	})
+	return c
+}
+
+func validateCollectionLinks(t *memory.Tables, collectionIDs []int) error {
+	if memory.HasDuplicates(collectionIDs) {
+		return ErrDuplicateCollection
+	}
+	for _, id := range collectionIDs {
+		if _, ok := t.Collections[id]; !ok {
+			return ErrInvalidCollection
+		}
+	}
+	return nil
+}
+
+func toShop(row *memory.ShopRow) *Shop {
+	return &Shop{ID: row.ID, Name: row.Name, CollectionIDs: memory.SortedInts(row.CollectionIDs), Version: row.Version}
+}
+
+func fromShop(s *Shop) *memory.ShopRow {
+	return &memory.ShopRow{ID: s.ID, Name: s.Name, CollectionIDs: append([]int{}, s.CollectionIDs...), Version: s.Version}
+}
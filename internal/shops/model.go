@@ -2,13 +2,65 @@ package shops
 
 import (
 	"categories-test/internal/categories"
+	"categories-test/internal/platform/search"
 	"categories-test/internal/products"
 )
 
+// Shop is a storefront over some collections. Version counts its updates;
+// when updating, it holds the version the caller read, or zero to skip the
+// check.
 type Shop struct {
 	ID            int
 	Name          string
 	CollectionIDs []int
+	Version       int
+}
+
+// CollectionLinks adds collections to a shop and removes others from it,
+// leaving its remaining collections alone. A non-zero Version must match the
+// shop's.
+type CollectionLinks struct {
+	ShopID  int
+	Add     []int
+	Remove  []int
+	Version int
+}
+
+type ProductSort string
+
+const (
+	SortByID      ProductSort = ""
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortName      ProductSort = "name"
+	SortNewest    ProductSort = "newest"
+)
+
+func (s ProductSort) Valid() bool {
+	switch s {
+	case SortByID, SortPriceAsc, SortPriceDesc, SortName, SortNewest:
+		return true
+	}
+	return false
+}
+
+// ProductFilter narrows the products a shop lists. A category matches products
+// linked to it or to one of its descendants; with several categories a
+// product must match any of them, or all of them when MatchAllCategories is
+// set. Query matches products containing every word as a prefix, and ranks
+// them by relevance unless Sort says otherwise.
+type ProductFilter struct {
+	Query              string
+	CollectionID       *int
+	CategoryIDs        []int
+	MatchAllCategories bool
+	MinPrice           *float64
+	MaxPrice           *float64
+	Sort               ProductSort
+}
+
+func (f ProductFilter) ranked() bool {
+	return f.Sort == SortByID && len(search.Terms(f.Query)) > 0
 }
 
 type PaginatedProducts struct {
@@ -17,6 +69,74 @@ type PaginatedProducts struct {
 	Limit      int
 	TotalCount int
 	TotalPages int
+	Facets     Facets
+}
+
+// Facets summarise the whole filtered result set, not just the current page.
+type Facets struct {
+	Categories   []CategoryFacet
+	PriceBuckets []PriceBucket
+}
+
+// CategoryFacet counts the matching products linked directly to a category.
+type CategoryFacet struct {
+	CategoryID int
+	Name       string
+	Count      int
+}
+
+// PriceBucket counts the matching products priced in [Min, Max). The last
+// bucket has no upper bound.
+type PriceBucket struct {
+	Min   float64
+	Max   *float64
+	Count int
+}
+
+// priceBucketBounds are the upper bounds of every price bucket but the last.
+var priceBucketBounds = []float64{10, 25, 50, 100, 250}
+
+func emptyFacets() Facets {
+	return Facets{Categories: []CategoryFacet{}, PriceBuckets: priceBuckets(make([]int, len(priceBucketBounds)+1))}
+}
+
+// priceBuckets pairs per-bucket counts, indexed like priceBucketBounds, with
+// their bounds.
+func priceBuckets(counts []int) []PriceBucket {
+	buckets := make([]PriceBucket, 0, len(priceBucketBounds)+1)
+	lower := 0.0
+	for i, upper := range priceBucketBounds {
+		max := upper
+		buckets = append(buckets, PriceBucket{Min: lower, Max: &max, Count: counts[i]})
+		lower = upper
+	}
+	return append(buckets, PriceBucket{Min: lower, Count: counts[len(priceBucketBounds)]})
+}
+
+func priceBucketIndex(price float64) int {
+	for i, upper := range priceBucketBounds {
+		if price < upper {
+			return i
+		}
+	}
+	return len(priceBucketBounds)
+}
+
+// Suggestions are type-ahead matches among the products, categories and
+// collections a shop exposes.
+type Suggestions struct {
+	Products    []Suggestion
+	Categories  []Suggestion
+	Collections []Suggestion
+}
+
+type Suggestion struct {
+	ID   int
+	Name string
+}
+
+func emptySuggestions() *Suggestions {
+	return &Suggestions{Products: []Suggestion{}, Categories: []Suggestion{}, Collections: []Suggestion{}}
 }
 
 type CategoryView = categories.Category
@@ -0,0 +1,39 @@
+package shops
+
+import (
+	"strings"
+
+	"categories-test/internal/platform/db"
+)
+
+// PostgresRepository stores shops in PostgreSQL.
+type PostgresRepository struct {
+	SQLRepository
+}
+
+func NewPostgresRepository(client *db.Client) *PostgresRepository {
+	return &PostgresRepository{SQLRepository{db: client}}
+}
+
+func (r *PostgresRepository) GetShopSuggestions(shopID int, terms []string, limit int) *Suggestions {
+	return r.shopSuggestions(shopID, terms, limit, r.suggestProducts)
+}
+
+// suggestProducts relies on pg_trgm word similarity, which tolerates typos
+// and partial words without a separate correction step.
+func (r *PostgresRepository) suggestProducts(shop *Shop, terms []string, limit int) ([]Suggestion, error) {
+	query := strings.Join(terms, " ")
+	scope, args := productScope(r.db.Dialect(), shop, ProductFilter{}, true)
+	rows, err := r.db.QueryDynamic(
+		scope+`SELECT p.id, p.name
+		FROM products p JOIN matched m ON m.id = p.id
+		WHERE ? <% p.name
+		ORDER BY word_similarity(?, p.name) DESC, p.id
+		LIMIT ?;`,
+		append(args, query, query, limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSuggestions(rows)
+}
@@ -1,5 +1,10 @@
 package shops
 
+import (
+	"categories-test/internal/platform/search"
+	"categories-test/internal/products"
+)
+
 type Queries struct {
 	repo QueryRepository
 }
@@ -12,14 +17,59 @@ func (q *Queries) List() []*Shop {
 	return q.repo.GetShops()
 }
 
+// ListAfter returns up to limit shops following the given ID and whether more
+// shops follow them.
+func (q *Queries) ListAfter(after, limit int) ([]*Shop, bool) {
+	items := q.repo.GetShopsAfter(after, limit+1)
+	if len(items) > limit {
+		return items[:limit], true
+	}
+	return items, false
+}
+
 func (q *Queries) Get(id int) (*Shop, error) {
 	return q.repo.GetShop(id)
 }
 
-func (q *Queries) Products(shopID int, collectionID *int, categoryID *int, page, limit int) *PaginatedProducts {
-	return q.repo.GetShopProducts(shopID, collectionID, categoryID, page, limit)
+// Products returns a page of the shop's products. Like ProductsAfter,
+// Categories and Suggest, it returns ErrNotFound for a missing shop rather
+// than an empty result.
+func (q *Queries) Products(shopID int, filter ProductFilter, page, limit int) (*PaginatedProducts, error) {
+	if _, err := q.repo.GetShop(shopID); err != nil {
+		return nil, err
+	}
+	return q.repo.GetShopProducts(shopID, filter, page, limit), nil
+}
+
+// ProductsAfter returns up to limit shop products following the given ID and
+// whether more products follow them.
+func (q *Queries) ProductsAfter(shopID int, filter ProductFilter, after, limit int) ([]*products.Product, bool, error) {
+	if _, err := q.repo.GetShop(shopID); err != nil {
+		return nil, false, err
+	}
+	items := q.repo.GetShopProductsAfter(shopID, filter, after, limit+1)
+	if len(items) > limit {
+		return items[:limit], true, nil
+	}
+	return items, false, nil
+}
+
+func (q *Queries) Categories(shopID int, collectionID *int, directOnly bool) ([]*CategoryView, error) {
+	if _, err := q.repo.GetShop(shopID); err != nil {
+		return nil, err
+	}
+	return q.repo.GetShopCategories(shopID, collectionID, directOnly), nil
 }
 
-func (q *Queries) Categories(shopID int, collectionID *int, directOnly bool) []*CategoryView {
-	return q.repo.GetShopCategories(shopID, collectionID, directOnly)
+// Suggest returns up to limit names of each kind matching q, tolerating a few
+// typos per word.
+func (q *Queries) Suggest(shopID int, query string, limit int) (*Suggestions, error) {
+	if _, err := q.repo.GetShop(shopID); err != nil {
+		return nil, err
+	}
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		return emptySuggestions(), nil
+	}
+	return q.repo.GetShopSuggestions(shopID, terms, limit), nil
 }
@@ -1,14 +1,27 @@
 package shops
 
+import "categories-test/internal/products"
+
 type CommandRepository interface {
 	CreateShop(s *Shop) (*Shop, error)
 	UpdateShop(s *Shop) (*Shop, error)
-	DeleteShop(id int) error
+	// DeleteShop deletes the shop provided it is still at version, or whatever
+	// its version when version is zero.
+	DeleteShop(id, version int) error
+	// UpdateShopCollections applies the link changes and returns the shop.
+	// Adding a collection twice or removing one the shop does not have
+	// changes nothing.
+	UpdateShopCollections(links CollectionLinks) (*Shop, error)
+	MissingCollectionIDs(ids []int) ([]int, error)
 }
 
 type QueryRepository interface {
 	GetShops() []*Shop
+	GetShopsAfter(after, limit int) []*Shop
 	GetShop(id int) (*Shop, error)
-	GetShopProducts(shopID int, collectionID *int, categoryID *int, page, limit int) *PaginatedProducts
+	GetShopProducts(shopID int, filter ProductFilter, page, limit int) *PaginatedProducts
+	// GetShopProductsAfter pages by ID and ignores filter.Sort.
+	GetShopProductsAfter(shopID int, filter ProductFilter, after, limit int) []*products.Product
 	GetShopCategories(shopID int, collectionID *int, directOnly bool) []*CategoryView
+	GetShopSuggestions(shopID int, terms []string, limit int) *Suggestions
 }
@@ -0,0 +1,577 @@
+package shops
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/search"
+	"categories-test/internal/products"
+)
+
+var versionErrors = db.VersionErrors{NotFound: ErrNotFound, Mismatch: ErrVersionMismatch}
+
+type SQLRepository struct {
+	db *db.Client
+}
+
+func (r *SQLRepository) GetShops() []*Shop {
+	return r.queryShops(`SELECT id FROM shops`)
+}
+
+func (r *SQLRepository) GetShopsAfter(after, limit int) []*Shop {
+	return r.queryShops(`SELECT id FROM shops WHERE id > ? ORDER BY id LIMIT ?`, after, limit)
+}
+
+// queryShops loads the shops whose IDs are selected by ids, along with their
+// collection links.
+func (r *SQLRepository) queryShops(ids string, args ...any) []*Shop {
+	collectionsByShop, err := r.getShopCollectionMap(ids, args...)
+	if err != nil {
+		collectionsByShop = map[int][]int{}
+	}
+
+	rows, err := r.db.Query(`SELECT id, name, version FROM shops WHERE id IN (`+ids+`) ORDER BY id;`, args...)
+	if err != nil {
+		return []*Shop{}
+	}
+	defer rows.Close()
+
+	items := make([]*Shop, 0)
+	for rows.Next() {
+		s := &Shop{}
+		if err := rows.Scan(&s.ID, &s.Name, &s.Version); err != nil {
+			return []*Shop{}
+		}
+		s.CollectionIDs = collectionsByShop[s.ID]
+		items = append(items, s)
+	}
+	if err := rows.Err(); err != nil {
+		return []*Shop{}
+	}
+	return items
+}
+
+func (r *SQLRepository) GetShop(id int) (*Shop, error) {
+	shop := &Shop{}
+	err := r.db.QueryRow(`SELECT id, name, version FROM shops WHERE id = ?;`, id).Scan(&shop.ID, &shop.Name, &shop.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	shop.CollectionIDs = r.getCollectionIDsForShop(shop.ID)
+	return shop, nil
+}
+
+func (r *SQLRepository) CreateShop(s *Shop) (*Shop, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := tx.QueryRow(`INSERT INTO shops(name) VALUES (?) RETURNING id, version;`, s.Name).Scan(&s.ID, &s.Version); err != nil {
+			return err
+		}
+		return insertShopCollections(tx, s.ID, s.CollectionIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *SQLRepository) UpdateShop(s *Shop) (*Shop, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		version, err := tx.BumpVersion("shops", s.ID, s.Version, versionErrors)
+		if err != nil {
+			return err
+		}
+		s.Version = version
+		if _, err := tx.Exec(`UPDATE shops SET name = ? WHERE id = ?;`, s.Name, s.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM shop_collections WHERE shop_id = ?;`, s.ID); err != nil {
+			return err
+		}
+		return insertShopCollections(tx, s.ID, s.CollectionIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *SQLRepository) DeleteShop(id, version int) error {
+	return r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if _, err := tx.BumpVersion("shops", id, version, versionErrors); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM shops WHERE id = ?;`, id)
+		return err
+	})
+}
+
+func (r *SQLRepository) UpdateShopCollections(links CollectionLinks) (*Shop, error) {
+	err := r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if _, err := tx.BumpVersion("shops", links.ShopID, links.Version, versionErrors); err != nil {
+			return err
+		}
+		for _, collectionID := range links.Add {
+			if _, err := tx.Exec(
+				`INSERT INTO shop_collections(shop_id, collection_id) VALUES (?, ?) ON CONFLICT DO NOTHING;`,
+				links.ShopID, collectionID,
+			); err != nil {
+				if db.IsForeignKeyViolation(err) {
+					return ErrInvalidCollection
+				}
+				return err
+			}
+		}
+		if len(links.Remove) == 0 {
+			return nil
+		}
+		in, args := db.Placeholders(links.Remove)
+		_, err := tx.ExecDynamic(
+			`DELETE FROM shop_collections WHERE shop_id = ? AND collection_id IN (`+in+`);`,
+			append([]any{links.ShopID}, args...)...,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetShop(links.ShopID)
+}
+
+func (r *SQLRepository) MissingCollectionIDs(ids []int) ([]int, error) {
+	return r.db.MissingIDs("collections", ids)
+}
+
+func (r *SQLRepository) GetShopProducts(shopID int, filter ProductFilter, page, limit int) *PaginatedProducts {
+	shop, err := r.GetShop(shopID)
+	if err != nil {
+		return emptyPage(page, limit)
+	}
+
+	scope, args := productScope(r.db.Dialect(), shop, filter, true)
+	var totalCount int
+	if err := r.db.QueryRowDynamic(scope+`SELECT COUNT(*) FROM matched;`, args...).Scan(&totalCount); err != nil {
+		return emptyPage(page, limit)
+	}
+
+	paged, err := r.scopedProducts(scope, args, filter.Sort, filter.ranked(), 0, limit, (page-1)*limit)
+	if err != nil {
+		return emptyPage(page, limit)
+	}
+
+	facets, err := r.facets(scope, args)
+	if err != nil {
+		return emptyPage(page, limit)
+	}
+
+	totalPages := (totalCount + limit - 1) / limit
+	return &PaginatedProducts{Products: paged, Page: page, Limit: limit, TotalCount: totalCount, TotalPages: totalPages, Facets: facets}
+}
+
+func (r *SQLRepository) GetShopProductsAfter(shopID int, filter ProductFilter, after, limit int) []*products.Product {
+	shop, err := r.GetShop(shopID)
+	if err != nil {
+		return []*products.Product{}
+	}
+
+	scope, args := productScope(r.db.Dialect(), shop, filter, true)
+	items, err := r.scopedProducts(scope, args, SortByID, false, after, limit, 0)
+	if err != nil {
+		return []*products.Product{}
+	}
+	return items
+}
+
+// scopedProducts pages through matched. ranked orders the default sort by
+// search relevance.
+func (r *SQLRepository) scopedProducts(scope string, args []any, order ProductSort, ranked bool, after, limit, offset int) ([]*products.Product, error) {
+	rows, err := r.db.QueryDynamic(
+		scope+`SELECT p.id, p.name, p.description, p.price
+		FROM products p JOIN matched m ON m.id = p.id
+		WHERE p.id > ?
+		ORDER BY `+productOrder(order, ranked)+` LIMIT ? OFFSET ?;`,
+		append(args, after, limit, offset)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*products.Product, 0, limit)
+	for rows.Next() {
+		p := &products.Product{}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadProductCategoryIDs(items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func productOrder(order ProductSort, ranked bool) string {
+	switch order {
+	case SortPriceAsc:
+		return `p.price, p.id`
+	case SortPriceDesc:
+		return `p.price DESC, p.id`
+	case SortName:
+		return `p.name, p.id`
+	case SortNewest:
+		return `p.id DESC`
+	}
+	if ranked {
+		return `m.search_rank, p.id`
+	}
+	return `p.id`
+}
+
+// facets counts the matched products per directly linked category and per
+// price bucket.
+func (r *SQLRepository) facets(scope string, args []any) (Facets, error) {
+	facets := Facets{Categories: make([]CategoryFacet, 0)}
+
+	rows, err := r.db.QueryDynamic(
+		scope+`SELECT c.id, c.name, COUNT(*)
+		FROM product_categories pc
+		JOIN matched m ON m.id = pc.product_id
+		JOIN categories c ON c.id = pc.category_id
+		GROUP BY c.id, c.name
+		ORDER BY c.id;`,
+		args...,
+	)
+	if err != nil {
+		return Facets{}, err
+	}
+	for rows.Next() {
+		var f CategoryFacet
+		if err := rows.Scan(&f.CategoryID, &f.Name, &f.Count); err != nil {
+			rows.Close()
+			return Facets{}, err
+		}
+		facets.Categories = append(facets.Categories, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Facets{}, err
+	}
+
+	bucket := make([]string, 0, len(priceBucketBounds))
+	bucketArgs := append([]any{}, args...)
+	for i, upper := range priceBucketBounds {
+		bucket = append(bucket, fmt.Sprintf(`WHEN p.price < ? THEN %d`, i))
+		bucketArgs = append(bucketArgs, upper)
+	}
+	rows, err = r.db.QueryDynamic(
+		scope+`SELECT CASE `+strings.Join(bucket, ` `)+fmt.Sprintf(` ELSE %d END AS bucket, COUNT(*)`, len(priceBucketBounds))+`
+		FROM products p JOIN matched m ON m.id = p.id
+		GROUP BY bucket;`,
+		bucketArgs...,
+	)
+	if err != nil {
+		return Facets{}, err
+	}
+	defer rows.Close()
+	counts := make([]int, len(priceBucketBounds)+1)
+	for rows.Next() {
+		var index, count int
+		if err := rows.Scan(&index, &count); err != nil {
+			return Facets{}, err
+		}
+		counts[index] = count
+	}
+	facets.PriceBuckets = priceBuckets(counts)
+	return facets, rows.Err()
+}
+
+func (r *SQLRepository) GetShopCategories(shopID int, collectionID *int, directOnly bool) []*CategoryView {
+	shop, err := r.GetShop(shopID)
+	if err != nil {
+		return []*CategoryView{}
+	}
+
+	scope, args := productScope(r.db.Dialect(), shop, ProductFilter{CollectionID: collectionID}, !directOnly)
+	rows, err := r.db.QueryDynamic(
+		scope+`SELECT DISTINCT c.id, c.name, c.parent_id
+		FROM categories c
+		JOIN product_categories pc ON pc.category_id = c.id
+		JOIN matched m ON m.id = pc.product_id
+		ORDER BY c.id;`,
+		args...,
+	)
+	if err != nil {
+		return []*CategoryView{}
+	}
+	defer rows.Close()
+
+	result := make([]*CategoryView, 0)
+	for rows.Next() {
+		c := &CategoryView{}
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Name, &parentID); err != nil {
+			return []*CategoryView{}
+		}
+		c.ParentID = db.IntPtr(parentID)
+		result = append(result, c)
+	}
+	return result
+}
+
+// shopSuggestions matches categories and collections in Go, since a shop
+// exposes few of them, and leaves products to the backend's suggestProducts.
+func (r *SQLRepository) shopSuggestions(shopID int, terms []string, limit int, suggestProducts func(*Shop, []string, int) ([]Suggestion, error)) *Suggestions {
+	shop, err := r.GetShop(shopID)
+	if err != nil {
+		return emptySuggestions()
+	}
+
+	result := emptySuggestions()
+	if result.Products, err = suggestProducts(shop, terms, limit); err != nil {
+		return emptySuggestions()
+	}
+
+	categoryCandidates := make([]Suggestion, 0)
+	for _, category := range r.GetShopCategories(shopID, nil, false) {
+		categoryCandidates = append(categoryCandidates, Suggestion{ID: category.ID, Name: category.Name})
+	}
+	result.Categories = fuzzySuggestions(categoryCandidates, terms, limit)
+
+	collectionCandidates, err := r.exposedCollections(shop)
+	if err != nil {
+		return emptySuggestions()
+	}
+	result.Collections = fuzzySuggestions(collectionCandidates, terms, limit)
+	return result
+}
+
+// exposedCollections returns the shop's collections and their descendants,
+// or every collection for a shop without collections.
+func (r *SQLRepository) exposedCollections(shop *Shop) ([]Suggestion, error) {
+	query := `SELECT id, name FROM collections ORDER BY id;`
+	args := []any{}
+	if len(shop.CollectionIDs) > 0 {
+		query = `WITH RECURSIVE exposed(id) AS (
+			SELECT collection_id FROM shop_collections WHERE shop_id = ?
+			UNION
+			SELECT c.id FROM collections c JOIN exposed e ON c.parent_id = e.id
+		)
+		SELECT c.id, c.name FROM collections c JOIN exposed e ON e.id = c.id ORDER BY c.id;`
+		args = append(args, shop.ID)
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanSuggestions(rows)
+}
+
+func scanSuggestions(rows *sql.Rows) ([]Suggestion, error) {
+	defer rows.Close()
+	items := make([]Suggestion, 0)
+	for rows.Next() {
+		var s Suggestion
+		if err := rows.Scan(&s.ID, &s.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	return items, rows.Err()
+}
+
+// productScope builds a WITH clause defining matched(id), the products a shop
+// exposes for filter, following the same rules as catalog: an explicit
+// collection (plus its descendants when requested) wins over the shop's own
+// collections, and a shop without collections exposes every product.
+// Descendants are resolved with recursive CTEs; UNION keeps them finite even
+// if the hierarchy contains a cycle. matched also carries search_rank, lower
+// being more relevant, which is zero without a search query.
+func productScope(dialect db.Dialect, shop *Shop, filter ProductFilter, includeDescendants bool) (string, []any) {
+	ctes := make([]string, 0, 2+len(filter.CategoryIDs))
+	args := make([]any, 0, 2+len(filter.CategoryIDs))
+	conditions := make([]string, 0, 3+len(filter.CategoryIDs))
+	conditionArgs := make([]any, 0, 2)
+
+	switch {
+	case filter.CollectionID != nil && includeDescendants:
+		ctes = append(ctes, `scoped_collections(id) AS (
+			SELECT CAST(? AS INTEGER)
+			UNION
+			SELECT c.id FROM collections c JOIN scoped_collections s ON c.parent_id = s.id
+		)`)
+		args = append(args, *filter.CollectionID)
+	case filter.CollectionID != nil:
+		ctes = append(ctes, `scoped_collections(id) AS (SELECT CAST(? AS INTEGER))`)
+		args = append(args, *filter.CollectionID)
+	case len(shop.CollectionIDs) > 0:
+		ctes = append(ctes, `scoped_collections(id) AS (SELECT collection_id FROM shop_collections WHERE shop_id = ?)`)
+		args = append(args, shop.ID)
+	}
+	if len(ctes) > 0 {
+		conditions = append(conditions, `p.id IN (
+			SELECT cp.product_id FROM collection_products cp JOIN scoped_collections s ON s.id = cp.collection_id
+		)`)
+	}
+
+	// Each category group gets its own descendant CTE and a correlated EXISTS,
+	// so the cost follows the collection scope instead of the whole catalog.
+	// Matching any category needs one group; matching all needs one per ID.
+	groups := [][]int{}
+	if filter.MatchAllCategories {
+		for _, id := range filter.CategoryIDs {
+			groups = append(groups, []int{id})
+		}
+	} else if len(filter.CategoryIDs) > 0 {
+		groups = append(groups, filter.CategoryIDs)
+	}
+	for i, group := range groups {
+		name := fmt.Sprintf("scoped_categories_%d", i)
+		placeholders := make([]string, 0, len(group))
+		for _, id := range group {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		ctes = append(ctes, name+`(id) AS (
+			SELECT id FROM categories WHERE id IN (`+strings.Join(placeholders, ", ")+`)
+			UNION
+			SELECT c.id FROM categories c JOIN `+name+` s ON c.parent_id = s.id
+		)`)
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM product_categories pc JOIN `+name+` sc ON sc.id = pc.category_id
+			WHERE pc.product_id = p.id
+		)`)
+	}
+
+	if filter.MinPrice != nil {
+		conditions = append(conditions, `p.price >= ?`)
+		conditionArgs = append(conditionArgs, *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		conditions = append(conditions, `p.price <= ?`)
+		conditionArgs = append(conditionArgs, *filter.MaxPrice)
+	}
+
+	matched := `SELECT p.id, 0 AS search_rank FROM products p`
+	if terms := search.Terms(filter.Query); len(terms) > 0 {
+		if dialect == db.DialectPostgres {
+			matched = `SELECT p.id, -ts_rank(p.search_vector, q) AS search_rank
+				FROM products p, to_tsquery('simple', ?) q`
+			conditions = append(conditions, `p.search_vector @@ q`)
+			args = append(args, search.TSQuery(terms))
+		} else {
+			matched = `SELECT p.id, fts.search_rank
+				FROM products p JOIN (
+					SELECT rowid AS id, bm25(products_fts, 10.0, 1.0) AS search_rank
+					FROM products_fts WHERE products_fts MATCH ?
+				) fts ON fts.id = p.id`
+			args = append(args, search.FTS5Query(terms))
+		}
+	}
+	if len(conditions) > 0 {
+		matched += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	ctes = append(ctes, `matched(id, search_rank) AS (`+matched+`)`)
+
+	return `WITH RECURSIVE ` + strings.Join(ctes, `, `) + ` `, append(args, conditionArgs...)
+}
+
+func (r *SQLRepository) loadProductCategoryIDs(items []*products.Product) error {
+	if len(items) == 0 {
+		return nil
+	}
+	byID := make(map[int]*products.Product, len(items))
+	placeholders := make([]string, 0, len(items))
+	args := make([]any, 0, len(items))
+	for _, p := range items {
+		p.CategoryIDs = []int{}
+		byID[p.ID] = p
+		placeholders = append(placeholders, "?")
+		args = append(args, p.ID)
+	}
+
+	rows, err := r.db.QueryDynamic(
+		`SELECT product_id, category_id FROM product_categories
+		WHERE product_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY product_id, category_id;`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pid, cid int
+		if err := rows.Scan(&pid, &cid); err != nil {
+			return err
+		}
+		byID[pid].CategoryIDs = append(byID[pid].CategoryIDs, cid)
+	}
+	return rows.Err()
+}
+
+func insertShopCollections(tx *db.Client, shopID int, collectionIDs []int) error {
+	for _, cid := range collectionIDs {
+		if _, err := tx.Exec(`INSERT INTO shop_collections(shop_id, collection_id) VALUES (?, ?);`, shopID, cid); err != nil {
+			if db.IsForeignKeyViolation(err) {
+				return ErrInvalidCollection
+			}
+			if db.IsUniqueViolation(err) {
+				return ErrDuplicateCollection
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLRepository) getCollectionIDsForShop(shopID int) []int {
+	rows, err := r.db.Query(`SELECT collection_id FROM shop_collections WHERE shop_id = ? ORDER BY collection_id;`, shopID)
+	if err != nil {
+		return []int{}
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return []int{}
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (r *SQLRepository) getShopCollectionMap(ids string, args ...any) (map[int][]int, error) {
+	rows, err := r.db.Query(
+		`SELECT shop_id, collection_id FROM shop_collections
+		WHERE shop_id IN (`+ids+`)
+		ORDER BY shop_id, collection_id;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[int][]int)
+	for rows.Next() {
+		var sid, cid int
+		if err := rows.Scan(&sid, &cid); err != nil {
+			return nil, err
+		}
+		m[sid] = append(m[sid], cid)
+	}
+	return m, rows.Err()
+}
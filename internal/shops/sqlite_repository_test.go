@@ -0,0 +1,87 @@
+package shops
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"categories-test/internal/platform/db"
+)
+
+// BenchmarkGetShopProducts lists a fixed-size shop scope out of catalogs of
+// increasing size. Latency should stay flat as the catalog grows.
+func BenchmarkGetShopProducts(b *testing.B) {
+	const scopeSize = 500
+	for _, catalogSize := range []int{1_000, 10_000, 100_000} {
+		b.Run(fmt.Sprintf("catalog=%d", catalogSize), func(b *testing.B) {
+			client, err := db.OpenSQLite(filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatalf("open sqlite: %v", err)
+			}
+			defer client.Close()
+
+			shopID, categoryID := seedCatalog(b, client, catalogSize, scopeSize)
+			repo := NewSQLiteRepository(client)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				page := repo.GetShopProducts(shopID, ProductFilter{CategoryIDs: []int{categoryID}}, 3, 20)
+				if len(page.Products) != 20 {
+					b.Fatalf("got %d products, want 20", len(page.Products))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetShopSuggestions measures type-ahead with a typo in a fixed-size
+// shop scope out of catalogs of increasing size. Latency should stay flat as
+// the catalog grows.
+func BenchmarkGetShopSuggestions(b *testing.B) {
+	for _, catalogSize := range []int{1_000, 10_000, 100_000} {
+		b.Run(fmt.Sprintf("catalog=%d", catalogSize), func(b *testing.B) {
+			client, err := db.OpenSQLite(filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatalf("open sqlite: %v", err)
+			}
+			defer client.Close()
+
+			shopID, _ := seedCatalog(b, client, catalogSize, 500)
+			repo := NewSQLiteRepository(client)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result := repo.GetShopSuggestions(shopID, []string{"prodcut", "12"}, 10)
+				if len(result.Products) == 0 {
+					b.Fatal("got no product suggestions")
+				}
+			}
+		})
+	}
+}
+
+// seedCatalog inserts catalogSize products, each linked to one of two sibling
+// categories under a root, and a shop whose only collection holds the first
+// scopeSize products. It returns the shop and the root category.
+func seedCatalog(b *testing.B, client *db.Client, catalogSize, scopeSize int) (int, int) {
+	b.Helper()
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`INSERT INTO categories(id, name, parent_id) VALUES (1, 'Root', NULL), (2, 'Even', 1), (3, 'Odd', 1);`, nil},
+		{`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < ?)
+			INSERT INTO products(id, name, description, price) SELECT n, 'Product ' || n, '', n FROM seq;`, []any{catalogSize}},
+		{`INSERT INTO product_categories(product_id, category_id) SELECT id, 2 + id % 2 FROM products;`, nil},
+		{`INSERT INTO collections(id, name, parent_id) VALUES (1, 'Featured', NULL);`, nil},
+		{`INSERT INTO collection_products(collection_id, product_id) SELECT 1, id FROM products WHERE id <= ?;`, []any{scopeSize}},
+		{`INSERT INTO shops(id, name) VALUES (1, 'Shop');`, nil},
+		{`INSERT INTO shop_collections(shop_id, collection_id) VALUES (1, 1);`, nil},
+	}
+	for _, s := range statements {
+		if _, err := client.Exec(s.query, s.args...); err != nil {
+			b.Fatalf("seed catalog: %v", err)
+		}
+	}
+	return 1, 1
+}
@@ -0,0 +1,122 @@
+package snapshot
+
+import (
+	"fmt"
+
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/platform/validate"
+)
+
+type Commands struct {
+	repo Repository
+}
+
+func NewCommands(repo Repository) *Commands {
+	return &Commands{repo: repo}
+}
+
+// Restore writes s into an empty catalog, keeping its IDs.
+func (c *Commands) Restore(s *Snapshot) (Counts, error) {
+	if err := validateSnapshot(s); err != nil {
+		return Counts{}, err
+	}
+	if err := c.repo.RestoreSnapshot(s); err != nil {
+		return Counts{}, err
+	}
+	return s.Counts(), nil
+}
+
+// validateSnapshot checks what the repositories would otherwise reject, or
+// could not restore, such as parent cycles. Fields are named after the JSON
+// of the snapshot format.
+func validateSnapshot(s *Snapshot) error {
+	var errs validate.Errors
+
+	categoryIDs := make(map[int]bool, len(s.Categories))
+	categoryParents := make(tree.Parents, len(s.Categories))
+	for n, category := range s.Categories {
+		field := fmt.Sprintf("categories[%d]", n)
+		checkID(&errs, field, category.ID, categoryIDs)
+		errs.Name(field+".name", category.Name)
+		categoryParents[category.ID] = category.ParentID
+	}
+	for n, category := range s.Categories {
+		checkParent(&errs, fmt.Sprintf("categories[%d]", n), category.ParentID, categoryIDs)
+	}
+	checkCycles(&errs, "categories", categoryParents)
+
+	productIDs := make(map[int]bool, len(s.Products))
+	for n, product := range s.Products {
+		field := fmt.Sprintf("products[%d]", n)
+		checkID(&errs, field, product.ID, productIDs)
+		errs.Name(field+".name", product.Name)
+		if product.Price < 0 {
+			errs.Add(field+".price", "negative", "must not be negative")
+		}
+		checkLinks(&errs, field+".categoryIds", product.CategoryIDs, categoryIDs)
+	}
+
+	collectionIDs := make(map[int]bool, len(s.Collections))
+	collectionParents := make(tree.Parents, len(s.Collections))
+	for n, collection := range s.Collections {
+		field := fmt.Sprintf("collections[%d]", n)
+		checkID(&errs, field, collection.ID, collectionIDs)
+		errs.Name(field+".name", collection.Name)
+		checkLinks(&errs, field+".productIds", collection.ProductIDs, productIDs)
+		collectionParents[collection.ID] = collection.ParentID
+	}
+	for n, collection := range s.Collections {
+		checkParent(&errs, fmt.Sprintf("collections[%d]", n), collection.ParentID, collectionIDs)
+	}
+	checkCycles(&errs, "collections", collectionParents)
+
+	shopIDs := make(map[int]bool, len(s.Shops))
+	for n, shop := range s.Shops {
+		field := fmt.Sprintf("shops[%d]", n)
+		checkID(&errs, field, shop.ID, shopIDs)
+		errs.Name(field+".name", shop.Name)
+		checkLinks(&errs, field+".collectionIds", shop.CollectionIDs, collectionIDs)
+	}
+
+	return errs.Err()
+}
+
+func checkID(errs *validate.Errors, field string, id int, seen map[int]bool) {
+	switch {
+	case id <= 0:
+		errs.Add(field+".id", "invalid", "must be positive")
+	case seen[id]:
+		errs.Add(field+".id", "duplicate", fmt.Sprintf("%d is used more than once", id))
+	}
+	seen[id] = true
+}
+
+func checkParent(errs *validate.Errors, field string, parentID *int, known map[int]bool) {
+	if parentID != nil && !known[*parentID] {
+		errs.Add(field+".parentId", "not_found", fmt.Sprintf("references unknown ID %d", *parentID))
+	}
+}
+
+func checkCycles(errs *validate.Errors, field string, parents tree.Parents) {
+	for _, cycle := range parents.Cycles() {
+		errs.Add(field, "cycle", fmt.Sprintf("IDs %v are each other's ancestors", cycle))
+	}
+}
+
+func checkLinks(errs *validate.Errors, field string, ids []int, known map[int]bool) {
+	seen := make(map[int]bool, len(ids))
+	var missing []int
+	for _, id := range ids {
+		if seen[id] {
+			errs.Add(field, "duplicate", fmt.Sprintf("lists %d more than once", id))
+			return
+		}
+		seen[id] = true
+		if !known[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		errs.Add(field, "not_found", fmt.Sprintf("references unknown IDs %v", missing))
+	}
+}
@@ -0,0 +1,69 @@
+package snapshot
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/platform/validate"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestCommandsRestoreValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot Snapshot
+		want     []string
+	}{
+		{"valid", Snapshot{
+			Categories:  []*categories.Category{{ID: 1, Name: "Clothing"}, {ID: 3, Name: "Shirts", ParentID: intPtr(1)}},
+			Products:    []*products.Product{{ID: 2, Name: "Tee", CategoryIDs: []int{3}}},
+			Collections: []*collections.Collection{{ID: 1, Name: "Summer", ProductIDs: []int{2}}},
+			Shops:       []*shops.Shop{{ID: 1, Name: "Outlet", CollectionIDs: []int{1}}},
+		}, nil},
+		{"bad IDs", Snapshot{
+			Categories: []*categories.Category{{ID: 0, Name: "Clothing"}, {ID: 2, Name: "Shirts"}, {ID: 2, Name: "Socks"}},
+		}, []string{"categories[0].id:invalid", "categories[2].id:duplicate"}},
+		{"unknown parent", Snapshot{
+			Collections: []*collections.Collection{{ID: 1, Name: "Summer", ParentID: intPtr(9)}},
+		}, []string{"collections[0].parentId:not_found"}},
+		{"cycle", Snapshot{
+			Categories: []*categories.Category{{ID: 1, Name: "A", ParentID: intPtr(2)}, {ID: 2, Name: "B", ParentID: intPtr(1)}},
+		}, []string{"categories:cycle"}},
+		{"bad links", Snapshot{
+			Products: []*products.Product{{ID: 1, Name: "Tee", Price: -1, CategoryIDs: []int{4}}},
+			Shops:    []*shops.Shop{{ID: 1, Name: " ", CollectionIDs: []int{1, 1}}},
+		}, []string{"products[0].price:negative", "products[0].categoryIds:not_found", "shops[0].name:required", "shops[0].collectionIds:duplicate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := NewCommands(NewMemoryRepository(memory.NewStore()))
+			_, err := commands.Restore(&tt.snapshot)
+			if got := fieldCodes(err); !slices.Equal(got, tt.want) {
+				t.Errorf("error = %v, want fields %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// fieldCodes lists the "field:code" pairs of a validation error in order, or
+// nil when err is not one.
+func fieldCodes(err error) []string {
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	codes := make([]string, 0, len(invalid.Fields))
+	for _, field := range invalid.Fields {
+		codes = append(codes, field.Field+":"+field.Code)
+	}
+	return codes
+}
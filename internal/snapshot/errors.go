@@ -0,0 +1,8 @@
+package snapshot
+
+import "errors"
+
+var (
+	ErrCatalogNotEmpty = errors.New("catalog is not empty")
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
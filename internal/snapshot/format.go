@@ -0,0 +1,245 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/productimport"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+)
+
+const (
+	// SnapshotFormat and SnapshotVersion identify snapshot documents.
+	SnapshotFormat  = "catalog-snapshot"
+	SnapshotVersion = 1
+)
+
+type snapshotDTO struct {
+	Format      string          `json:"format"`
+	Version     int             `json:"version"`
+	Categories  []categoryDTO   `json:"categories"`
+	Products    []productDTO    `json:"products"`
+	Collections []collectionDTO `json:"collections"`
+	Shops       []shopDTO       `json:"shops"`
+}
+
+type categoryDTO struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parentId"`
+	Position int    `json:"position"`
+}
+
+type productDTO struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	CategoryIDs []int   `json:"categoryIds"`
+}
+
+type collectionDTO struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	ParentID   *int   `json:"parentId"`
+	Position   int    `json:"position"`
+	ProductIDs []int  `json:"productIds"`
+}
+
+type shopDTO struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	CollectionIDs []int  `json:"collectionIds"`
+}
+
+func toCategoryDTO(c *categories.Category) categoryDTO {
+	return categoryDTO{ID: c.ID, Name: c.Name, ParentID: c.ParentID, Position: c.Position}
+}
+
+func toProductDTO(p *products.Product) productDTO {
+	return productDTO{ID: p.ID, Name: p.Name, Description: p.Description, Price: p.Price, CategoryIDs: ensureInts(p.CategoryIDs)}
+}
+
+func toCollectionDTO(c *collections.Collection) collectionDTO {
+	return collectionDTO{ID: c.ID, Name: c.Name, ParentID: c.ParentID, Position: c.Position, ProductIDs: ensureInts(c.ProductIDs)}
+}
+
+func toShopDTO(s *shops.Shop) shopDTO {
+	return shopDTO{ID: s.ID, Name: s.Name, CollectionIDs: ensureInts(s.CollectionIDs)}
+}
+
+// Write encodes src in format, writing each entity as soon as it is encoded,
+// so products are sent while they are read.
+func Write(w io.Writer, src *Source, format Format) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, src)
+	case FormatNDJSON:
+		return writeNDJSON(w, src)
+	case FormatSnapshot:
+		return writeSnapshot(w, src)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// writeSnapshot writes the snapshot document one entity at a time rather
+// than marshalling it whole.
+func writeSnapshot(w io.Writer, src *Source) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `{"format":%q,"version":%d`, SnapshotFormat, SnapshotVersion)
+	if err := writeArray(out, "categories", eachOf(src.Categories), toCategoryDTO); err != nil {
+		return err
+	}
+	if err := writeArray(out, "products", src.EachProduct, toProductDTO); err != nil {
+		return err
+	}
+	if err := writeArray(out, "collections", eachOf(src.Collections), toCollectionDTO); err != nil {
+		return err
+	}
+	if err := writeArray(out, "shops", eachOf(src.Shops), toShopDTO); err != nil {
+		return err
+	}
+	out.WriteString("}\n")
+	return out.Flush()
+}
+
+// writeArray stops at the first failed write, so an export to a client that
+// went away stops reading the catalog.
+func writeArray[T, DTO any](out *bufio.Writer, name string, each func(fn func(T) error) error, toDTO func(T) DTO) error {
+	fmt.Fprintf(out, `,%q:[`, name)
+	n := 0
+	err := each(func(item T) error {
+		if n > 0 {
+			out.WriteByte(',')
+		}
+		n++
+		data, err := json.Marshal(toDTO(item))
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	})
+	out.WriteByte(']')
+	return err
+}
+
+type productLineDTO struct {
+	Type string `json:"type"`
+	productDTO
+	Categories []string `json:"categories"`
+}
+
+type collectionLineDTO struct {
+	Type string `json:"type"`
+	collectionDTO
+}
+
+type shopLineDTO struct {
+	Type string `json:"type"`
+	shopDTO
+}
+
+// writeNDJSON writes products, then collections, then shops, each line
+// telling its kind in a type member.
+func writeNDJSON(w io.Writer, src *Source) error {
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	paths := src.CategoryPaths()
+	err := src.EachProduct(func(p *products.Product) error {
+		return encoder.Encode(productLineDTO{Type: "product", productDTO: toProductDTO(p), Categories: lookup(paths, p.CategoryIDs)})
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range src.Collections {
+		if err := encoder.Encode(collectionLineDTO{Type: "collection", collectionDTO: toCollectionDTO(c)}); err != nil {
+			return err
+		}
+	}
+	for _, shop := range src.Shops {
+		if err := encoder.Encode(shopLineDTO{Type: "shop", shopDTO: toShopDTO(shop)}); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// writeCSV writes one line per product in the layout productimport.ParseCSV
+// reads.
+func writeCSV(w io.Writer, src *Source) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"name", "description", "price", "categories", "collections"})
+
+	paths := src.CategoryPaths()
+	collectionNames := src.CollectionNames()
+	listSeparator := " " + productimport.ListSeparator + " "
+	err := src.EachProduct(func(p *products.Product) error {
+		return out.Write([]string{
+			p.Name,
+			p.Description,
+			strconv.FormatFloat(p.Price, 'f', -1, 64),
+			strings.Join(lookup(paths, p.CategoryIDs), listSeparator),
+			strings.Join(collectionNames[p.ID], listSeparator),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func lookup(values map[int]string, ids []int) []string {
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, values[id])
+	}
+	return result
+}
+
+// ReadSnapshot decodes a document written in FormatSnapshot.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	var dto snapshotDTO
+	if err := decoder.Decode(&dto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: unexpected data after the snapshot", ErrInvalidSnapshot)
+	}
+	if dto.Format != SnapshotFormat || dto.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%w: format %q version %d, want %q version %d", ErrInvalidSnapshot, dto.Format, dto.Version, SnapshotFormat, SnapshotVersion)
+	}
+
+	s := &Snapshot{
+		Categories:  make([]*categories.Category, 0, len(dto.Categories)),
+		Products:    make([]*products.Product, 0, len(dto.Products)),
+		Collections: make([]*collections.Collection, 0, len(dto.Collections)),
+		Shops:       make([]*shops.Shop, 0, len(dto.Shops)),
+	}
+	for _, c := range dto.Categories {
+		s.Categories = append(s.Categories, &categories.Category{ID: c.ID, Name: c.Name, ParentID: c.ParentID, Position: c.Position})
+	}
+	for _, p := range dto.Products {
+		s.Products = append(s.Products, &products.Product{ID: p.ID, Name: p.Name, Description: p.Description, Price: p.Price, CategoryIDs: ensureInts(p.CategoryIDs)})
+	}
+	for _, c := range dto.Collections {
+		s.Collections = append(s.Collections, &collections.Collection{ID: c.ID, Name: c.Name, ParentID: c.ParentID, Position: c.Position, ProductIDs: ensureInts(c.ProductIDs)})
+	}
+	for _, shop := range dto.Shops {
+		s.Shops = append(s.Shops, &shops.Shop{ID: shop.ID, Name: shop.Name, CollectionIDs: ensureInts(shop.CollectionIDs)})
+	}
+	return s, nil
+}
@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/productimport"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+)
+
+func testSnapshot() *Snapshot {
+	return &Snapshot{
+		Categories:  []*categories.Category{{ID: 1, Name: "Clothing"}, {ID: 2, Name: "Shirts", ParentID: intPtr(1)}, {ID: 4, Name: "Sale", Position: 1}},
+		Products:    []*products.Product{{ID: 1, Name: "Tee, white", Description: "Cotton", Price: 12.5, CategoryIDs: []int{2, 4}}, {ID: 3, Name: "Mug", CategoryIDs: []int{}}},
+		Collections: []*collections.Collection{{ID: 1, Name: "Summer", ProductIDs: []int{1}}, {ID: 2, Name: "Beach", ParentID: intPtr(1), ProductIDs: []int{1, 3}}},
+		Shops:       []*shops.Shop{{ID: 5, Name: "Outlet", CollectionIDs: []int{1}}},
+	}
+}
+
+func TestWriteSnapshotRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testSnapshot().Source(), FormatSnapshot); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if want := testSnapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadSnapshot = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadSnapshotRejectsTrailingData(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testSnapshot().Source(), FormatSnapshot); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	buf.WriteString("}")
+	if _, err := ReadSnapshot(&buf); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("ReadSnapshot = %v, want %v", err, ErrInvalidSnapshot)
+	}
+}
+
+func TestWriteCSVReadsAsImport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testSnapshot().Source(), FormatCSV); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	rows, err := productimport.ParseCSV(&buf)
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	want := []productimport.Row{
+		{Line: 2, Name: "Tee, white", Description: "Cotton", Price: 12.5, Categories: []string{"Clothing > Shirts", "Sale"}, Collections: []string{"Summer", "Beach"}},
+		{Line: 3, Name: "Mug", Collections: []string{"Beach"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %+v, want %+v", rows, want)
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testSnapshot().Source(), FormatNDJSON); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		`{"type":"product","id":1,"name":"Tee, white","description":"Cotton","price":12.5,"categoryIds":[2,4],"categories":["Clothing > Shirts","Sale"]}`,
+		`{"type":"product","id":3,"name":"Mug","description":"","price":0,"categoryIds":[],"categories":[]}`,
+		`{"type":"collection","id":1,"name":"Summer","parentId":null,"position":0,"productIds":[1]}`,
+		`{"type":"collection","id":2,"name":"Beach","parentId":1,"position":0,"productIds":[1,3]}`,
+		`{"type":"shop","id":5,"name":"Outlet","collectionIds":[1]}`,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
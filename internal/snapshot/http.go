@@ -0,0 +1,106 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"categories-test/internal/platform/httpx"
+	"categories-test/internal/productimport"
+)
+
+// MaxSnapshotBytes caps the size of restored snapshots.
+const MaxSnapshotBytes = 256 << 20
+
+type HTTPHandler struct {
+	commands *Commands
+	queries  *Queries
+}
+
+func NewHTTPHandler(commands *Commands, queries *Queries) *HTTPHandler {
+	return &HTTPHandler{commands: commands, queries: queries}
+}
+
+// errorMappings maps snapshot errors to problem responses.
+var errorMappings = []httpx.ErrorMapping{
+	{Err: ErrCatalogNotEmpty, Status: http.StatusConflict, Code: "catalog_not_empty"},
+	{Err: ErrInvalidSnapshot, Status: http.StatusBadRequest, Code: "invalid_snapshot"},
+}
+
+var contentTypes = map[Format]string{
+	FormatCSV:      productimport.CSVContentType + "; charset=utf-8",
+	FormatNDJSON:   productimport.NDJSONContentType,
+	FormatSnapshot: "application/json",
+}
+
+var fileNames = map[Format]string{
+	FormatCSV:      "catalog.csv",
+	FormatNDJSON:   "catalog.ndjson",
+	FormatSnapshot: "catalog-snapshot.json",
+}
+
+type countsDTO struct {
+	Categories  int `json:"categories"`
+	Products    int `json:"products"`
+	Collections int `json:"collections"`
+	Shops       int `json:"shops"`
+}
+
+// Export streams the catalog in the format named by ?format, csv, ndjson or
+// snapshot, read at a single point in time.
+func (h *HTTPHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format := Format(r.URL.Query().Get("format"))
+	if !format.Valid() {
+		httpx.WriteError(w, r, httpx.InvalidParam("format", "must be csv, ndjson or snapshot"), errorMappings)
+		return
+	}
+
+	sending := false
+	err := h.queries.Export(func(src *Source) error {
+		sending = true
+		// Large catalogs take longer to send than the server's write timeout.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", contentTypes[format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileNames[format]))
+		return Write(w, src, format)
+	})
+	if err != nil && !sending {
+		httpx.WriteError(w, r, err, errorMappings)
+	} else if err != nil {
+		// The status is sent already, so the client only sees a truncated
+		// body.
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+// Restore loads a snapshot exported in the snapshot format into an empty
+// catalog.
+func (h *HTTPHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	// Large snapshots take longer to receive than the server's read timeout.
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+	s, err := ReadSnapshot(http.MaxBytesReader(w, r.Body, MaxSnapshotBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = fmt.Errorf("%w: limit is %d bytes", httpx.ErrBodyTooLarge, tooLarge.Limit)
+	}
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	counts, err := h.commands.Restore(s)
+	if err != nil {
+		httpx.WriteError(w, r, err, errorMappings)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	httpx.WriteJSON(w, countsDTO{
+		Categories:  counts.Categories,
+		Products:    counts.Products,
+		Collections: counts.Collections,
+		Shops:       counts.Shops,
+	})
+}
@@ -0,0 +1,92 @@
+package snapshot
+
+import (
+	"sort"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/memory"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+)
+
+type MemoryRepository struct {
+	store *memory.Store
+}
+
+func NewMemoryRepository(store *memory.Store) *MemoryRepository {
+	return &MemoryRepository{store: store}
+}
+
+// ExportSnapshot copies the catalog under the store's read lock and calls fn
+// after releasing it, so a slow export does not hold up writes.
+func (r *MemoryRepository) ExportSnapshot(fn func(*Source) error) error {
+	return fn(r.loadSnapshot().Source())
+}
+
+func (r *MemoryRepository) loadSnapshot() *Snapshot {
+	s := &Snapshot{
+		Categories:  make([]*categories.Category, 0),
+		Products:    make([]*products.Product, 0),
+		Collections: make([]*collections.Collection, 0),
+		Shops:       make([]*shops.Shop, 0),
+	}
+	r.store.Read(func(t *memory.Tables) {
+		for _, row := range t.Categories {
+			s.Categories = append(s.Categories, &categories.Category{
+				ID: row.ID, Name: row.Name, ParentID: memory.CopyIntPtr(row.ParentID), Position: row.Position,
+			})
+		}
+		for _, row := range t.Products {
+			s.Products = append(s.Products, &products.Product{
+				ID: row.ID, Name: row.Name, Description: row.Description, Price: row.Price, CategoryIDs: sortedInts(row.CategoryIDs),
+			})
+		}
+		for _, row := range t.Collections {
+			s.Collections = append(s.Collections, &collections.Collection{
+				ID: row.ID, Name: row.Name, ParentID: memory.CopyIntPtr(row.ParentID), Position: row.Position, ProductIDs: sortedInts(row.ProductIDs),
+			})
+		}
+		for _, row := range t.Shops {
+			s.Shops = append(s.Shops, &shops.Shop{ID: row.ID, Name: row.Name, CollectionIDs: sortedInts(row.CollectionIDs)})
+		}
+	})
+	sort.Slice(s.Categories, func(i, j int) bool { return s.Categories[i].ID < s.Categories[j].ID })
+	sort.Slice(s.Products, func(i, j int) bool { return s.Products[i].ID < s.Products[j].ID })
+	sort.Slice(s.Collections, func(i, j int) bool { return s.Collections[i].ID < s.Collections[j].ID })
+	sort.Slice(s.Shops, func(i, j int) bool { return s.Shops[i].ID < s.Shops[j].ID })
+	return s
+}
+
+func (r *MemoryRepository) RestoreSnapshot(s *Snapshot) error {
+	return r.store.Write(func(t *memory.Tables) error {
+		if len(t.Categories)+len(t.Products)+len(t.Collections)+len(t.Shops) > 0 {
+			return ErrCatalogNotEmpty
+		}
+		for _, c := range s.Categories {
+			t.Categories[c.ID] = &memory.CategoryRow{ID: c.ID, Name: c.Name, ParentID: memory.CopyIntPtr(c.ParentID), Position: c.Position, Version: 1}
+			t.ReserveID("categories", c.ID)
+		}
+		for _, p := range s.Products {
+			t.Products[p.ID] = &memory.ProductRow{
+				ID: p.ID, Name: p.Name, Description: p.Description, Price: p.Price, CategoryIDs: append([]int{}, p.CategoryIDs...), Version: 1,
+			}
+			t.ReserveID("products", p.ID)
+		}
+		for _, c := range s.Collections {
+			t.Collections[c.ID] = &memory.CollectionRow{
+				ID: c.ID, Name: c.Name, ParentID: memory.CopyIntPtr(c.ParentID), Position: c.Position, ProductIDs: append([]int{}, c.ProductIDs...), Version: 1,
+			}
+			t.ReserveID("collections", c.ID)
+		}
+		for _, shop := range s.Shops {
+			t.Shops[shop.ID] = &memory.ShopRow{ID: shop.ID, Name: shop.Name, CollectionIDs: append([]int{}, shop.CollectionIDs...), Version: 1}
+			t.ReserveID("shops", shop.ID)
+		}
+		return nil
+	})
+}
+
+func sortedInts(values []int) []int {
+	return ensureInts(memory.SortedInts(values))
+}
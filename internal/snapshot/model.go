@@ -0,0 +1,130 @@
+// Package snapshot exports the whole catalog and restores exported snapshots,
+// so an environment can be cloned into another.
+package snapshot
+
+import (
+	"strings"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/productimport"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+)
+
+// Snapshot is the whole catalog at one point in time, each list ordered by
+// ID. It keeps IDs, sibling positions and links; versions are not kept, so
+// restored entities start over at version 1.
+type Snapshot struct {
+	Categories  []*categories.Category
+	Products    []*products.Product
+	Collections []*collections.Collection
+	Shops       []*shops.Shop
+}
+
+// Source exports s.
+func (s *Snapshot) Source() *Source {
+	return &Source{
+		Categories:  s.Categories,
+		Collections: s.Collections,
+		Shops:       s.Shops,
+		EachProduct: eachOf(s.Products),
+	}
+}
+
+// Source is the catalog being exported, at one point in time. Products, which
+// make up most of it, are walked in ID order by EachProduct rather than held
+// in memory; the other lists are ordered by ID.
+type Source struct {
+	Categories  []*categories.Category
+	Collections []*collections.Collection
+	Shops       []*shops.Shop
+	// EachProduct calls fn with each product in turn and stops at the first
+	// error fn returns.
+	EachProduct func(fn func(*products.Product) error) error
+}
+
+// CategoryPaths spells out the path of every category the way product
+// imports read them, such as "Clothing > Shirts".
+func (src *Source) CategoryPaths() map[int]string {
+	parents := make(tree.Parents, len(src.Categories))
+	names := make(map[int]string, len(src.Categories))
+	for _, c := range src.Categories {
+		parents[c.ID] = c.ParentID
+		names[c.ID] = c.Name
+	}
+	paths := make(map[int]string, len(src.Categories))
+	for _, c := range src.Categories {
+		levels := make([]string, 0)
+		for _, id := range parents.Path(c.ID) {
+			levels = append(levels, names[id])
+		}
+		paths[c.ID] = strings.Join(levels, " "+productimport.PathSeparator+" ")
+	}
+	return paths
+}
+
+// CollectionNames lists the names of the collections holding each product,
+// in collection order.
+func (src *Source) CollectionNames() map[int][]string {
+	names := make(map[int][]string)
+	for _, c := range src.Collections {
+		for _, productID := range c.ProductIDs {
+			names[productID] = append(names[productID], c.Name)
+		}
+	}
+	return names
+}
+
+func eachOf[T any](items []T) func(fn func(T) error) error {
+	return func(fn func(T) error) error {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Counts reports how many entities a snapshot holds.
+type Counts struct {
+	Categories  int
+	Products    int
+	Collections int
+	Shops       int
+}
+
+func (s *Snapshot) Counts() Counts {
+	return Counts{
+		Categories:  len(s.Categories),
+		Products:    len(s.Products),
+		Collections: len(s.Collections),
+		Shops:       len(s.Shops),
+	}
+}
+
+// Format is a representation of an exported catalog.
+type Format string
+
+const (
+	// FormatCSV lists products with the columns of a product import, so the
+	// file can be imported as is, provided no name contains the separators
+	// of category paths and lists.
+	FormatCSV Format = "csv"
+	// FormatNDJSON lists products with their category paths, collections with
+	// their product IDs and shops with their collection IDs, one per line.
+	FormatNDJSON Format = "ndjson"
+	// FormatSnapshot is a single JSON document holding the whole snapshot,
+	// which Restore accepts.
+	FormatSnapshot Format = "snapshot"
+)
+
+func (f Format) Valid() bool {
+	switch f {
+	case FormatCSV, FormatNDJSON, FormatSnapshot:
+		return true
+	}
+	return false
+}
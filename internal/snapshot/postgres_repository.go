@@ -0,0 +1,32 @@
+package snapshot
+
+import (
+	"context"
+
+	"categories-test/internal/platform/db"
+)
+
+// PostgresRepository stores catalog snapshots in PostgreSQL.
+type PostgresRepository struct {
+	SQLRepository
+}
+
+func NewPostgresRepository(client *db.Client) *PostgresRepository {
+	return &PostgresRepository{SQLRepository{db: client}}
+}
+
+// RestoreSnapshot also moves the ID sequences past the restored IDs, which
+// SQLite's AUTOINCREMENT does by itself.
+func (r *PostgresRepository) RestoreSnapshot(s *Snapshot) error {
+	return r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		if err := (&SQLRepository{db: tx}).RestoreSnapshot(s); err != nil {
+			return err
+		}
+		for _, table := range []string{"categories", "products", "collections", "shops"} {
+			if _, err := tx.Exec(`SELECT setval(pg_get_serial_sequence('` + table + `', 'id'), MAX(id)) FROM ` + table + `;`); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,14 @@
+package snapshot
+
+type Queries struct {
+	repo Repository
+}
+
+func NewQueries(repo Repository) *Queries {
+	return &Queries{repo: repo}
+}
+
+// Export calls fn with the catalog as of a single point in time.
+func (q *Queries) Export(fn func(*Source) error) error {
+	return q.repo.ExportSnapshot(fn)
+}
@@ -0,0 +1,12 @@
+package snapshot
+
+type Repository interface {
+	// ExportSnapshot calls fn with the catalog as of a single point in time.
+	// Products are read while fn walks them, and the read-only transaction
+	// of a database backend lasts until fn returns.
+	ExportSnapshot(fn func(*Source) error) error
+	// RestoreSnapshot writes s, keeping its IDs, and makes new entities get
+	// IDs above them. It fails with ErrCatalogNotEmpty unless the catalog is
+	// empty, and expects s to be valid.
+	RestoreSnapshot(s *Snapshot) error
+}
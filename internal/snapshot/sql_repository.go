@@ -0,0 +1,245 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+
+	"categories-test/internal/categories"
+	"categories-test/internal/collections"
+	"categories-test/internal/platform/db"
+	"categories-test/internal/platform/tree"
+	"categories-test/internal/products"
+	"categories-test/internal/shops"
+)
+
+type SQLRepository struct {
+	db *db.Client
+}
+
+// ExportSnapshot reads in a read-only transaction, so a long export holds no
+// write lock.
+func (r *SQLRepository) ExportSnapshot(fn func(*Source) error) error {
+	return r.db.WithReadTx(context.Background(), func(tx *db.Client) error {
+		src := &Source{EachProduct: func(fn func(*products.Product) error) error {
+			return eachProduct(tx, fn)
+		}}
+		var err error
+		if src.Categories, err = loadCategories(tx); err != nil {
+			return err
+		}
+		if src.Collections, err = loadCollections(tx); err != nil {
+			return err
+		}
+		if src.Shops, err = loadShops(tx); err != nil {
+			return err
+		}
+		return fn(src)
+	})
+}
+
+func loadCategories(tx *db.Client) ([]*categories.Category, error) {
+	rows, err := tx.Query(`SELECT id, name, parent_id, position FROM categories ORDER BY id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*categories.Category, 0)
+	for rows.Next() {
+		c := &categories.Category{}
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Name, &parentID, &c.Position); err != nil {
+			return nil, err
+		}
+		c.ParentID = db.IntPtr(parentID)
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// eachProduct reads the products with their categories in one query, one
+// row per link, and passes each product to fn once its rows are read.
+func eachProduct(tx *db.Client, fn func(*products.Product) error) error {
+	rows, err := tx.Query(
+		`SELECT p.id, p.name, p.description, p.price, pc.category_id
+		FROM products p LEFT JOIN product_categories pc ON pc.product_id = p.id
+		ORDER BY p.id, pc.category_id;`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *products.Product
+	for rows.Next() {
+		p := &products.Product{CategoryIDs: []int{}}
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &categoryID); err != nil {
+			return err
+		}
+		if current != nil && current.ID != p.ID {
+			if err := fn(current); err != nil {
+				return err
+			}
+			current = nil
+		}
+		if current == nil {
+			current = p
+		}
+		if categoryID.Valid {
+			current.CategoryIDs = append(current.CategoryIDs, int(categoryID.Int64))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return fn(current)
+	}
+	return nil
+}
+
+func loadCollections(tx *db.Client) ([]*collections.Collection, error) {
+	links, err := loadLinks(tx, `SELECT collection_id, product_id FROM collection_products ORDER BY collection_id, product_id;`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(`SELECT id, name, parent_id, position FROM collections ORDER BY id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*collections.Collection, 0)
+	for rows.Next() {
+		c := &collections.Collection{}
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Name, &parentID, &c.Position); err != nil {
+			return nil, err
+		}
+		c.ParentID = db.IntPtr(parentID)
+		c.ProductIDs = ensureInts(links[c.ID])
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+func loadShops(tx *db.Client) ([]*shops.Shop, error) {
+	links, err := loadLinks(tx, `SELECT shop_id, collection_id FROM shop_collections ORDER BY shop_id, collection_id;`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(`SELECT id, name FROM shops ORDER BY id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*shops.Shop, 0)
+	for rows.Next() {
+		s := &shops.Shop{}
+		if err := rows.Scan(&s.ID, &s.Name); err != nil {
+			return nil, err
+		}
+		s.CollectionIDs = ensureInts(links[s.ID])
+		items = append(items, s)
+	}
+	return items, rows.Err()
+}
+
+// loadLinks groups the rows of a link table by their first column.
+func loadLinks(tx *db.Client, query string) (map[int][]int, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make(map[int][]int)
+	for rows.Next() {
+		var ownerID, linkedID int
+		if err := rows.Scan(&ownerID, &linkedID); err != nil {
+			return nil, err
+		}
+		links[ownerID] = append(links[ownerID], linkedID)
+	}
+	return links, rows.Err()
+}
+
+func (r *SQLRepository) RestoreSnapshot(s *Snapshot) error {
+	return r.db.WithTx(context.Background(), func(tx *db.Client) error {
+		var empty bool
+		err := tx.QueryRow(`SELECT NOT (EXISTS (SELECT 1 FROM categories) OR EXISTS (SELECT 1 FROM products)
+			OR EXISTS (SELECT 1 FROM collections) OR EXISTS (SELECT 1 FROM shops));`).Scan(&empty)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return ErrCatalogNotEmpty
+		}
+
+		categoriesByID := make(map[int]*categories.Category, len(s.Categories))
+		categoryParents := make(tree.Parents, len(s.Categories))
+		for _, c := range s.Categories {
+			categoriesByID[c.ID] = c
+			categoryParents[c.ID] = c.ParentID
+		}
+		for _, id := range categoryParents.TopDown() {
+			c := categoriesByID[id]
+			if _, err := tx.Exec(`INSERT INTO categories (id, name, parent_id, position) VALUES (?, ?, ?, ?);`,
+				c.ID, c.Name, db.NullableInt(c.ParentID), c.Position); err != nil {
+				return err
+			}
+		}
+
+		for _, p := range s.Products {
+			if _, err := tx.Exec(`INSERT INTO products (id, name, description, price) VALUES (?, ?, ?, ?);`,
+				p.ID, p.Name, p.Description, p.Price); err != nil {
+				return err
+			}
+			for _, categoryID := range p.CategoryIDs {
+				if _, err := tx.Exec(`INSERT INTO product_categories (product_id, category_id) VALUES (?, ?);`, p.ID, categoryID); err != nil {
+					return err
+				}
+			}
+		}
+
+		collectionsByID := make(map[int]*collections.Collection, len(s.Collections))
+		collectionParents := make(tree.Parents, len(s.Collections))
+		for _, c := range s.Collections {
+			collectionsByID[c.ID] = c
+			collectionParents[c.ID] = c.ParentID
+		}
+		for _, id := range collectionParents.TopDown() {
+			c := collectionsByID[id]
+			if _, err := tx.Exec(`INSERT INTO collections (id, name, parent_id, position) VALUES (?, ?, ?, ?);`,
+				c.ID, c.Name, db.NullableInt(c.ParentID), c.Position); err != nil {
+				return err
+			}
+			for _, productID := range c.ProductIDs {
+				if _, err := tx.Exec(`INSERT INTO collection_products (collection_id, product_id) VALUES (?, ?);`, c.ID, productID); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, shop := range s.Shops {
+			if _, err := tx.Exec(`INSERT INTO shops (id, name) VALUES (?, ?);`, shop.ID, shop.Name); err != nil {
+				return err
+			}
+			for _, collectionID := range shop.CollectionIDs {
+				if _, err := tx.Exec(`INSERT INTO shop_collections (shop_id, collection_id) VALUES (?, ?);`, shop.ID, collectionID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func ensureInts(values []int) []int {
+	if values == nil {
+		return []int{}
+	}
+	return values
+}
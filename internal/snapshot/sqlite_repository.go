@@ -0,0 +1,12 @@
+package snapshot
+
+import "categories-test/internal/platform/db"
+
+// SQLiteRepository stores catalog snapshots in SQLite.
+type SQLiteRepository struct {
+	SQLRepository
+}
+
+func NewSQLiteRepository(client *db.Client) *SQLiteRepository {
+	return &SQLiteRepository{SQLRepository{db: client}}
+}
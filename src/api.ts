@@ -27,6 +27,17 @@ async function request<T>(endpoint: string, options?: RequestInit): Promise<T> {
   return res.json()
 }
 
+// ifMatch guards a write with the version the entity was read at, so the
+// server rejects it with 412 when someone else changed the entity since.
+function ifMatch(version: number | undefined): Record<string, string> {
+  if (!version) {
+    throw new Error('Cannot change an entity without the version it was read at')
+  }
+  return { 'If-Match': `"${version}"` }
+}
+
+type Versioned = { id: number; version?: number }
+
 function buildQuery(params: Record<string, number | undefined>): string {
   const queryParts: string[] = []
   for (const [key, value] of Object.entries(params)) {
@@ -40,49 +51,49 @@ function buildQuery(params: Record<string, number | undefined>): string {
 export const api = {
   getProducts: () => request<Product[]>('/products'),
 
-  createProduct: (product: Omit<Product, 'id'>) =>
+  createProduct: (product: Omit<Product, 'id' | 'version'>) =>
     request<Product>('/products', { method: 'POST', body: JSON.stringify(product) }),
 
   updateProduct: (product: Product) =>
-    request<Product>(`/products/${product.id}`, { method: 'PUT', body: JSON.stringify(product) }),
+    request<Product>(`/products/${product.id}`, { method: 'PUT', headers: ifMatch(product.version), body: JSON.stringify(product) }),
 
-  deleteProduct: (productId: number) =>
-    request<void>(`/products/${productId}`, { method: 'DELETE' }),
+  deleteProduct: (product: Versioned) =>
+    request<void>(`/products/${product.id}`, { method: 'DELETE', headers: ifMatch(product.version) }),
 
   getCategories: () => request<Category[]>('/categories'),
 
-  createCategory: (category: Omit<Category, 'id'>) =>
+  createCategory: (category: Omit<Category, 'id' | 'version'>) =>
     request<Category>('/categories', { method: 'POST', body: JSON.stringify(category) }),
 
   updateCategory: (category: Category) =>
-    request<Category>(`/categories/${category.id}`, { method: 'PUT', body: JSON.stringify(category) }),
+    request<Category>(`/categories/${category.id}`, { method: 'PUT', headers: ifMatch(category.version), body: JSON.stringify(category) }),
 
-  deleteCategory: (categoryId: number) =>
-    request<void>(`/categories/${categoryId}`, { method: 'DELETE' }),
+  deleteCategory: (category: Versioned) =>
+    request<void>(`/categories/${category.id}`, { method: 'DELETE', headers: ifMatch(category.version) }),
 
   getCollections: () => request<Collection[]>('/collections'),
 
-  createCollection: (collection: Omit<Collection, 'id'>) =>
+  createCollection: (collection: Omit<Collection, 'id' | 'version'>) =>
     request<Collection>('/collections', { method: 'POST', body: JSON.stringify(collection) }),
 
   updateCollection: (collection: Collection) =>
-    request<Collection>(`/collections/${collection.id}`, { method: 'PUT', body: JSON.stringify(collection) }),
+    request<Collection>(`/collections/${collection.id}`, { method: 'PUT', headers: ifMatch(collection.version), body: JSON.stringify(collection) }),
 
-  deleteCollection: (collectionId: number) =>
-    request<void>(`/collections/${collectionId}`, { method: 'DELETE' }),
+  deleteCollection: (collection: Versioned) =>
+    request<void>(`/collections/${collection.id}`, { method: 'DELETE', headers: ifMatch(collection.version) }),
 
   getShops: () => request<Shop[]>('/shops'),
 
   getShop: (shopId: number) => request<Shop>(`/shops/${shopId}`),
 
-  createShop: (shop: Omit<Shop, 'id'>) =>
+  createShop: (shop: Omit<Shop, 'id' | 'version'>) =>
     request<Shop>('/shops', { method: 'POST', body: JSON.stringify(shop) }),
 
   updateShop: (shop: Shop) =>
-    request<Shop>(`/shops/${shop.id}`, { method: 'PUT', body: JSON.stringify(shop) }),
+    request<Shop>(`/shops/${shop.id}`, { method: 'PUT', headers: ifMatch(shop.version), body: JSON.stringify(shop) }),
 
-  deleteShop: (shopId: number) =>
-    request<void>(`/shops/${shopId}`, { method: 'DELETE' }),
+  deleteShop: (shop: Versioned) =>
+    request<void>(`/shops/${shop.id}`, { method: 'DELETE', headers: ifMatch(shop.version) }),
 
   getShopProducts: (shopId: number, params?: ShopProductsParams) => {
     const query = buildQuery({
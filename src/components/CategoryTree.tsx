@@ -32,7 +32,7 @@ interface CategoryTreeProps {
   onCreateNameChange: (name: string) => void
   onCreate: (parentId: number | null) => void
   onCancelCreate: () => void
-  onDelete: (categoryId: number) => void
+  onDelete: (category: Category) => void
 }
 
 export function CategoryTree({
@@ -163,7 +163,7 @@ export function CategoryTree({
             onCreateNameChange={onCreateNameChange}
             onCreate={() => onCreate(category.id)}
             onCancelCreate={onCancelCreate}
-            onDelete={() => onDelete(category.id)}
+            onDelete={() => onDelete(category)}
           />
         )
       })}
@@ -171,7 +171,7 @@ export function CategoryTreeManager({ categories }: CategoryTreeManagerProps) {
             onCreateNameChange={setNewCategoryName}
             onCreate={handleCreateCategory}
             onCancelCreate={() => { setCreatingAtId(null); setNewCategoryName('') }}
-            onDelete={(id) => deleteMutation.mutate(id)}
+            onDelete={(category) => deleteMutation.mutate(category)}
           />
         </div>
         <div className="tree-help">
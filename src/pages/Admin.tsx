@@ -139,7 +139,7 @@ function ProductsTab({ products, categories }: { products: Product[]; categories
     }
 
     if (editingProduct) {
-      updateMutation.mutate({ ...product, id: editingProduct.id })
+      updateMutation.mutate({ ...product, id: editingProduct.id, version: editingProduct.version })
     } else {
       createMutation.mutate(product)
     }
@@ -231,7 +231,7 @@ function ProductsTab({ products, categories }: { products: Product[]; categories
               </div>
               <div className="grid-cell actions">
                 <button onClick={() => handleEdit(product)} className="btn-secondary btn-sm" disabled={isMutating}>Edit</button>
-                <button onClick={() => deleteMutation.mutate(product.id)} className="btn-danger btn-sm" disabled={isMutating}>Delete</button>
+                <button onClick={() => deleteMutation.mutate(product)} className="btn-danger btn-sm" disabled={isMutating}>Delete</button>
               </div>
             </div>
           ))}
@@ -280,7 +280,7 @@ function CollectionCard({
   collections: Collection[]
   products: Product[]
   onEdit: (c: Collection) => void
-  onDelete: (id: number) => void
+  onDelete: (c: Collection) => void
   isMutating: boolean
   depth?: number
 }) {
@@ -304,7 +304,7 @@ function CollectionCard({
         </div>
         <div className="collection-actions">
           <button onClick={() => onEdit(collection)} className="btn-secondary btn-sm" disabled={isMutating}>Edit</button>
-          <button onClick={() => onDelete(collection.id)} className="btn-danger btn-sm" disabled={isMutating}>Delete</button>
+          <button onClick={() => onDelete(collection)} className="btn-danger btn-sm" disabled={isMutating}>Delete</button>
         </div>
       </div>
       {expanded && collection.children.length > 0 && (
@@ -396,7 +396,7 @@ function CollectionsTab({ collections, products }: { collections: Collection[];
     }
 
     if (editingCollection) {
-      updateMutation.mutate({ ...collection, id: editingCollection.id })
+      updateMutation.mutate({ ...collection, id: editingCollection.id, version: editingCollection.version })
     } else {
       createMutation.mutate(collection)
     }
@@ -475,7 +475,7 @@ function CollectionsTab({ collections, products }: { collections: Collection[];
               collections={filteredCollections}
               products={products}
               onEdit={handleEdit}
-              onDelete={(id: number) => deleteMutation.mutate(id)}
+              onDelete={(collection: Collection) => deleteMutation.mutate(collection)}
               isMutating={isMutating}
             />
           ))}
@@ -543,7 +543,7 @@ function ShopsTab({ shops, collections }: { shops: Shop[]; collections: Collecti
     }
 
     if (editingShop) {
-      updateMutation.mutate({ ...shop, id: editingShop.id })
+      updateMutation.mutate({ ...shop, id: editingShop.id, version: editingShop.version })
     } else {
       createMutation.mutate(shop)
     }
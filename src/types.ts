@@ -4,6 +4,7 @@ export interface Product {
   description: string
   price: number
   categoryIds: number[]
+  version?: number
 }
 
 export interface PaginatedProducts {
@@ -18,6 +19,7 @@ export interface Category {
   id: number
   name: string
   parentId: number | null
+  version?: number
 }
 
 export interface Collection {
@@ -25,12 +27,14 @@ export interface Collection {
   name: string
   parentId: number | null
   productIds: number[]
+  version?: number
 }
 
 export interface Shop {
   id: number
   name: string
   collectionIds: number[]
+  version?: number
 }
 
 export interface TreeNode<T> {